@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"airline-voucher-backend/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MinSecretLength is the shortest JWT signing secret accepted, matching the
+// 256-bit output of HS256
+const MinSecretLength = 32
+
+// MinAPIKeyLength is the shortest API key accepted
+const MinAPIKeyLength = 24
+
+// leeway tolerates clock skew between the token issuer and this server
+const leeway = 30 * time.Second
+
+// Authentication errors
+var (
+	ErrMissingCredentials = errors.New("missing credentials")
+	ErrInvalidToken       = errors.New("invalid bearer token")
+	ErrInvalidAPIKey      = errors.New("invalid API key")
+)
+
+// Claims are the claims of a crew bearer token. The subject is the crew ID;
+// a token without a role is a crew token.
+type Claims struct {
+	Name    string   `json:"name,omitempty"`
+	Role    string   `json:"role,omitempty"`
+	Flights []string `json:"flights,omitempty"` // flight numbers the crew member works
+	jwt.RegisteredClaims
+}
+
+// Authenticator verifies crew bearer tokens, HS256 JWTs signed with a local
+// secret, and the static API keys of service accounts
+type Authenticator struct {
+	secret []byte
+	// apiKeys maps each service account name to its key
+	apiKeys map[string]string
+	// apiKeyRoles maps each service account name to its role
+	apiKeyRoles map[string]string
+}
+
+// NewAuthenticator creates an Authenticator. An empty secret disables bearer
+// tokens; apiKeys maps service account names to their keys, and apiKeyRoles
+// to their roles, crew by default.
+func NewAuthenticator(secret string, apiKeys, apiKeyRoles map[string]string) (*Authenticator, error) {
+	if secret != "" && len(secret) < MinSecretLength {
+		return nil, fmt.Errorf("JWT secret must be at least %d characters", MinSecretLength)
+	}
+	keys := make(map[string]string, len(apiKeys))
+	for name, key := range apiKeys {
+		if name == "" || len(key) < MinAPIKeyLength {
+			return nil, fmt.Errorf("API key %q must have a name and at least %d characters", name, MinAPIKeyLength)
+		}
+		keys[name] = key
+	}
+	roles := make(map[string]string, len(apiKeys))
+	for name, role := range apiKeyRoles {
+		if _, ok := keys[name]; !ok || !models.ValidRole(role) {
+			return nil, fmt.Errorf("API key role %q for %q must name an API key and be one of %s", role, name, strings.Join(models.Roles, ", "))
+		}
+		roles[name] = role
+	}
+	if secret == "" && len(keys) == 0 {
+		return nil, errors.New("a JWT secret or at least one API key is required")
+	}
+
+	return &Authenticator{secret: []byte(secret), apiKeys: keys, apiKeyRoles: roles}, nil
+}
+
+// Authenticate identifies the caller from the value of an Authorization
+// header ("Bearer <token>") or an X-API-Key header. A bearer token takes
+// precedence when both are sent.
+func (a *Authenticator) Authenticate(authorization, apiKey string) (*models.Principal, error) {
+	if authorization != "" {
+		scheme, token, _ := strings.Cut(strings.TrimSpace(authorization), " ")
+		if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return nil, fmt.Errorf("%w: Authorization must use the Bearer scheme", ErrInvalidToken)
+		}
+		return a.verifyToken(strings.TrimSpace(token))
+	}
+	if apiKey != "" {
+		return a.verifyAPIKey(apiKey)
+	}
+	return nil, ErrMissingCredentials
+}
+
+// IssueToken signs a bearer token for a crew member that expires after ttl
+func (a *Authenticator) IssueToken(crew *models.Principal, ttl time.Duration) (string, error) {
+	if len(a.secret) == 0 {
+		return "", errors.New("no JWT secret is configured")
+	}
+	if strings.TrimSpace(crew.ID) == "" || ttl <= 0 {
+		return "", errors.New("a crew ID and a positive lifetime are required")
+	}
+	if crew.Role != "" && !models.ValidRole(crew.Role) {
+		return "", fmt.Errorf("role must be one of %s, got %q", strings.Join(models.Roles, ", "), crew.Role)
+	}
+
+	now := time.Now()
+	claims := Claims{
+		Name:    crew.Name,
+		Role:    crew.Role,
+		Flights: crew.Flights,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   crew.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.secret)
+}
+
+// verifyToken checks the signature and expiry of a bearer token. Only HS256
+// is accepted, so a token cannot pick its own algorithm.
+func (a *Authenticator) verifyToken(token string) (*models.Principal, error) {
+	if len(a.secret) == 0 {
+		return nil, fmt.Errorf("%w: bearer tokens are not enabled", ErrInvalidToken)
+	}
+
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return a.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired(), jwt.WithLeeway(leeway))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if strings.TrimSpace(claims.Subject) == "" {
+		return nil, fmt.Errorf("%w: the token has no subject", ErrInvalidToken)
+	}
+	role := claims.Role
+	if role == "" {
+		role = models.RoleCrew
+	}
+	if !models.ValidRole(role) {
+		return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidToken, claims.Role)
+	}
+
+	return &models.Principal{
+		ID:      strings.TrimSpace(claims.Subject),
+		Name:    strings.TrimSpace(claims.Name),
+		Role:    role,
+		Flights: claims.Flights,
+	}, nil
+}
+
+// verifyAPIKey looks up the service account of an API key, comparing every
+// key in constant time
+func (a *Authenticator) verifyAPIKey(key string) (*models.Principal, error) {
+	var account string
+	for name, candidate := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+			account = name
+		}
+	}
+	if account == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	role := a.apiKeyRoles[account]
+	if role == "" {
+		role = models.RoleCrew
+	}
+	return &models.Principal{ID: account, Role: role, ServiceAccount: true}, nil
+}
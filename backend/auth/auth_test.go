@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"airline-voucher-backend/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSecret = "0123456789abcdef0123456789abcdef"
+	testAPIKey = "reporting-key-0123456789abcdef"
+)
+
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	authenticator, err := NewAuthenticator(testSecret, map[string]string{"reporting": testAPIKey, "scheduler": "scheduler-key-0123456789abcdef"},
+		map[string]string{"reporting": models.RoleAdmin})
+	require.NoError(t, err)
+	return authenticator
+}
+
+func TestNewAuthenticator_Invalid(t *testing.T) {
+	_, err := NewAuthenticator("", nil, nil)
+	assert.Error(t, err, "without credentials nobody could authenticate")
+	_, err = NewAuthenticator("too-short", nil, nil)
+	assert.Error(t, err)
+	_, err = NewAuthenticator("", map[string]string{"reporting": "short"}, nil)
+	assert.Error(t, err)
+	_, err = NewAuthenticator("", map[string]string{"": testAPIKey}, nil)
+	assert.Error(t, err)
+	_, err = NewAuthenticator("", map[string]string{"reporting": testAPIKey}, map[string]string{"reporting": "pilot"})
+	assert.Error(t, err)
+	_, err = NewAuthenticator("", map[string]string{"reporting": testAPIKey}, map[string]string{"billing": models.RoleAdmin})
+	assert.Error(t, err, "roles must belong to a configured key")
+}
+
+func TestAuthenticator_BearerToken(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+
+	token, err := authenticator.IssueToken(&models.Principal{ID: "98123", Name: "Sarah"}, time.Hour)
+	require.NoError(t, err)
+
+	principal, err := authenticator.Authenticate("Bearer "+token, "")
+	require.NoError(t, err)
+	assert.Equal(t, &models.Principal{ID: "98123", Name: "Sarah", Role: models.RoleCrew}, principal, "tokens without a role are crew tokens")
+
+	// The scheme is case-insensitive, and a token wins over an API key
+	principal, err = authenticator.Authenticate("bearer "+token, testAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, "98123", principal.ID)
+}
+
+func TestAuthenticator_TokenRoleAndFlights(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	supervisor := &models.Principal{ID: "77001", Name: "Budi", Role: models.RoleSupervisor, Flights: []string{"GA102", "QZ200"}}
+
+	token, err := authenticator.IssueToken(supervisor, time.Hour)
+	require.NoError(t, err)
+	principal, err := authenticator.Authenticate("Bearer "+token, "")
+	require.NoError(t, err)
+	assert.Equal(t, supervisor, principal)
+
+	_, err = authenticator.IssueToken(&models.Principal{ID: "77001", Role: "captain"}, time.Hour)
+	assert.Error(t, err)
+}
+
+func TestAuthenticator_RejectsBadTokens(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	sign := func(method jwt.SigningMethod, key interface{}, claims jwt.Claims) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		require.NoError(t, err)
+		return token
+	}
+	valid := jwt.RegisteredClaims{Subject: "98123", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+
+	tests := []struct {
+		name          string
+		authorization string
+	}{
+		{"Wrong scheme", "Basic dXNlcjpwYXNz"},
+		{"Empty token", "Bearer "},
+		{"Malformed", "Bearer not-a-jwt"},
+		{"Other secret", "Bearer " + sign(jwt.SigningMethodHS256, []byte("fedcba9876543210fedcba9876543210"), valid)},
+		{"Other algorithm", "Bearer " + sign(jwt.SigningMethodHS512, []byte(testSecret), valid)},
+		{"Unsigned", "Bearer " + sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid)},
+		{"Expired", "Bearer " + sign(jwt.SigningMethodHS256, []byte(testSecret), jwt.RegisteredClaims{
+			Subject: "98123", ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		})},
+		{"No expiry", "Bearer " + sign(jwt.SigningMethodHS256, []byte(testSecret), jwt.RegisteredClaims{Subject: "98123"})},
+		{"Unknown role", "Bearer " + sign(jwt.SigningMethodHS256, []byte(testSecret), Claims{Role: "captain", RegisteredClaims: valid})},
+		{"No subject", "Bearer " + sign(jwt.SigningMethodHS256, []byte(testSecret), jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := authenticator.Authenticate(tt.authorization, "")
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestAuthenticator_APIKey(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+
+	principal, err := authenticator.Authenticate("", testAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, &models.Principal{ID: "reporting", Role: models.RoleAdmin, ServiceAccount: true}, principal)
+
+	principal, err = authenticator.Authenticate("", "scheduler-key-0123456789abcdef")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleCrew, principal.Role, "service accounts without a role are crew")
+
+	_, err = authenticator.Authenticate("", "reporting-key-wrong")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	_, err = authenticator.Authenticate("", "")
+	assert.ErrorIs(t, err, ErrMissingCredentials)
+}
+
+func TestAuthenticator_APIKeysOnly(t *testing.T) {
+	authenticator, err := NewAuthenticator("", map[string]string{"reporting": testAPIKey}, nil)
+	require.NoError(t, err)
+
+	_, err = authenticator.IssueToken(&models.Principal{ID: "98123", Name: "Sarah"}, time.Hour)
+	assert.Error(t, err)
+
+	token, err := newTestAuthenticator(t).IssueToken(&models.Principal{ID: "98123", Name: "Sarah"}, time.Hour)
+	require.NoError(t, err)
+	_, err = authenticator.Authenticate("Bearer "+token, "")
+	assert.ErrorIs(t, err, ErrInvalidToken, "tokens are rejected when no secret is configured")
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearEnv unsets every configuration variable for the duration of a test
+func clearEnv(t *testing.T) {
+	for _, key := range append([]string{"CONFIG_FILE"}, settingEnvs()...) {
+		t.Setenv(key, "")
+	}
+}
+
+func settingEnvs() []string {
+	keys := make([]string, len(settings))
+	for i, s := range settings {
+		keys[i] = s.env
+	}
+	return keys
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	clearEnv(t)
+
+	cfg, args, err := Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, NewConfig(), cfg)
+	assert.Empty(t, args)
+}
+
+func TestLoad_Layering(t *testing.T) {
+	clearEnv(t)
+	path := writeConfigFile(t, "config.yaml", `
+port: "9000"
+dbPath: /data/file.db
+logLevel: warn
+corsOrigins:
+  - https://crew.example.com
+readTimeout: 5s
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("DB_PATH", "/data/env.db")
+	t.Setenv("LOG_LEVEL", "debug")
+
+	cfg, _, err := Load([]string{"-log-level", "error"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "9000", cfg.Port, "file overrides default")
+	assert.Equal(t, "/data/env.db", cfg.DBPath, "env overrides file")
+	assert.Equal(t, "error", cfg.LogLevel, "flag overrides env")
+	assert.Equal(t, []string{"https://crew.example.com"}, cfg.CORSOrigins)
+	assert.Equal(t, 5*time.Second, cfg.ReadTimeout)
+	assert.Equal(t, 15*time.Second, cfg.WriteTimeout, "unset values keep their default")
+}
+
+func TestLoad_JSONFileAndFlags(t *testing.T) {
+	clearEnv(t)
+	path := writeConfigFile(t, "config.json", `{"port": "9100", "idleTimeout": "2m"}`)
+
+	cfg, args, err := Load([]string{
+		"-config", path,
+		"-cors-origins", "https://a.example.com, https://b.example.com",
+		"-seat-count", "5",
+		"-seat-expiry", "72h",
+		"-idempotency-key-ttl", "0",
+		"-max-regenerations", "0",
+		"-api-keys", "reporting:reporting-key-0123456789abcdef, ops:ops-key-0123456789abcdef0123",
+		"-api-key-roles", "reporting:admin",
+		"-auth-disabled",
+		"migrate", "status",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "9100", cfg.Port)
+	assert.Equal(t, 2*time.Minute, cfg.IdleTimeout)
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.CORSOrigins)
+	assert.Equal(t, 5, cfg.DefaultSeatCount)
+	assert.Equal(t, 72*time.Hour, cfg.SeatExpiry)
+	assert.Zero(t, cfg.IdempotencyKeyTTL)
+	assert.Equal(t, 0, cfg.MaxVoucherRegenerations)
+	assert.Equal(t, 2, cfg.MaxSeatRegenerations, "unset values keep their default")
+	assert.Equal(t, map[string]string{"reporting": "reporting-key-0123456789abcdef", "ops": "ops-key-0123456789abcdef0123"}, cfg.APIKeys)
+	assert.Equal(t, map[string]string{"reporting": "admin"}, cfg.APIKeyRoles)
+	assert.True(t, cfg.AuthDisabled)
+	assert.Equal(t, []string{"migrate", "status"}, args)
+}
+
+func TestLoad_BoolFlags(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("AUTH_DISABLED", "true")
+
+	// An unset flag keeps the environment value
+	cfg, _, err := Load(nil)
+	require.NoError(t, err)
+	assert.True(t, cfg.AuthDisabled)
+
+	// An explicit false overrides it
+	cfg, args, err := Load([]string{"-auth-disabled=false", "serve"})
+	require.NoError(t, err)
+	assert.False(t, cfg.AuthDisabled)
+	assert.Equal(t, []string{"serve"}, args)
+}
+
+func TestLoad_InvalidInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		file    string
+		wantErr string
+	}{
+		{name: "bad env number", env: map[string]string{"VOUCHER_SEAT_COUNT": "many"}, wantErr: "invalid VOUCHER_SEAT_COUNT"},
+		{name: "bad flag duration", args: []string{"-read-timeout", "soon"}, wantErr: "invalid -read-timeout"},
+		{name: "unknown flag", args: []string{"-colour", "blue"}, wantErr: "flag provided but not defined"},
+		{name: "unknown file key", file: "prot: 9000\n", wantErr: "field prot not found"},
+		{name: "bad env bool", env: map[string]string{"AUTH_DISABLED": "maybe"}, wantErr: "invalid AUTH_DISABLED"},
+		{name: "bad flag bool", args: []string{"-auth-disabled=maybe"}, wantErr: "invalid -auth-disabled"},
+		{name: "bad api key pair", args: []string{"-api-keys", "reporting"}, wantErr: "invalid -api-keys"},
+		{name: "validation", env: map[string]string{"PORT": "0"}, wantErr: "port must be a number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeConfigFile(t, "config.yaml", tt.file)}, args...)
+			}
+
+			_, _, err := Load(args)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	certFile := writeConfigFile(t, "cert.pem", "cert")
+
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{name: "defaults", modify: func(c *Config) {}},
+		{name: "postgres with dsn", modify: func(c *Config) { c.DBDriver = DriverPostgres; c.DBDSN = "postgres://localhost/vouchers" }},
+		{name: "wildcard origin", modify: func(c *Config) { c.CORSOrigins = []string{"*"} }},
+		{name: "port out of range", modify: func(c *Config) { c.Port = "70000" }, wantErr: "port must be"},
+		{name: "unknown driver", modify: func(c *Config) { c.DBDriver = "mysql" }, wantErr: "database driver must be"},
+		{name: "postgres without dsn", modify: func(c *Config) { c.DBDriver = DriverPostgres }, wantErr: "DSN is required"},
+		{name: "seat count", modify: func(c *Config) { c.DefaultSeatCount = 0 }, wantErr: "default seat count"},
+		{name: "no origins", modify: func(c *Config) { c.CORSOrigins = nil }, wantErr: "at least one CORS origin"},
+		{name: "origin with path", modify: func(c *Config) { c.CORSOrigins = []string{"http://localhost:3000/app"} }, wantErr: "CORS origin must look like"},
+		{name: "log level", modify: func(c *Config) { c.LogLevel = "verbose" }, wantErr: "log level must be"},
+		{name: "tls cert without key", modify: func(c *Config) { c.TLSCertFile = certFile }, wantErr: "must be set together"},
+		{name: "missing tls files", modify: func(c *Config) { c.TLSCertFile = "/nonexistent/cert.pem"; c.TLSKeyFile = "/nonexistent/key.pem" }, wantErr: "TLS file /nonexistent/cert.pem"},
+		{name: "negative seat expiry", modify: func(c *Config) { c.SeatExpiry = -time.Hour }, wantErr: "seat expiry must not be negative"},
+		{name: "negative idempotency key ttl", modify: func(c *Config) { c.IdempotencyKeyTTL = -time.Hour }, wantErr: "idempotency key TTL must not be negative"},
+		{name: "negative regeneration limit", modify: func(c *Config) { c.MaxSeatRegenerations = -1 }, wantErr: "regeneration limits must not be negative"},
+		{name: "short jwt secret", modify: func(c *Config) { c.JWTSecret = "secret" }, wantErr: "JWT secret must be at least"},
+		{name: "short api key", modify: func(c *Config) { c.APIKeys = map[string]string{"reporting": "key"} }, wantErr: `API key "reporting"`},
+		{name: "unknown api key role", modify: func(c *Config) {
+			c.APIKeys = map[string]string{"reporting": "reporting-key-0123456789abcdef"}
+			c.APIKeyRoles = map[string]string{"reporting": "pilot"}
+		}, wantErr: "must be one of crew, supervisor, admin"},
+		{name: "role without api key", modify: func(c *Config) { c.APIKeyRoles = map[string]string{"billing": "admin"} }, wantErr: `role for "billing" has no matching API key`},
+		{name: "negative timeout", modify: func(c *Config) { c.ShutdownTimeout = -time.Second }, wantErr: "shutdown timeout must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestConfig_Validate_ReportsEveryProblem(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Port = "http"
+	cfg.LogLevel = "loud"
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "port must be")
+	assert.Contains(t, err.Error(), "log level must be")
+}
+
+func TestConfig_Print(t *testing.T) {
+	cfg := NewConfig()
+	cfg.DBDriver = DriverPostgres
+	cfg.DBDSN = "postgres://voucher:secret@db:5432/vouchers?sslmode=disable"
+	cfg.JWTSecret = "jwt-secret-0123456789abcdef01234567"
+	cfg.APIKeys = map[string]string{"reporting": "api-secret-0123456789abcdef", "scheduler": "api-secret-fedcba9876543210"}
+	cfg.APIKeyRoles = map[string]string{"reporting": "admin"}
+
+	var out bytes.Buffer
+	require.NoError(t, cfg.Print(&out))
+
+	assert.Contains(t, out.String(), "postgres://voucher:xxxxx@db:5432/vouchers")
+	assert.NotContains(t, out.String(), "secret")
+	assert.Contains(t, out.String(), "http://localhost:3000")
+	assert.Contains(t, out.String(), "built-in")
+	assert.Contains(t, out.String(), "bearer tokens, API keys (reporting: admin, scheduler: crew)")
+}
+
+func TestRedactDSN(t *testing.T) {
+	assert.Equal(t, "./vouchers.db", redactDSN("./vouchers.db"))
+	assert.Equal(t, "host=db user=voucher password=xxxxx dbname=vouchers", redactDSN("host=db user=voucher password=secret dbname=vouchers"))
+}
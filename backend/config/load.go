@@ -0,0 +1,438 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"airline-voucher-backend/auth"
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// setting is a configuration value that can be set from an environment
+// variable or a command-line flag
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   setter
+}
+
+// setter parses the text of a setting into the config. A bool setter makes
+// its flag a boolean flag, so a bare -name means true.
+type setter struct {
+	apply  func(c *Config, value string) error
+	isBool bool
+}
+
+// boolFlag holds the text of a bool setting's flag until Load applies it.
+// Load applies only the flags that were set, so an unset flag still differs
+// from -name=false.
+type boolFlag string
+
+func (b *boolFlag) String() string {
+	if b == nil {
+		return ""
+	}
+	return string(*b)
+}
+
+func (b *boolFlag) Set(value string) error {
+	*b = boolFlag(value)
+	return nil
+}
+
+func (b *boolFlag) IsBoolFlag() bool {
+	return true
+}
+
+// settings lists every value that can be overridden by environment
+// variables and flags. Config files use the yaml tags on Config instead.
+var settings = []setting{
+	{"PORT", "port", "HTTP port to listen on", setString(func(c *Config) *string { return &c.Port })},
+	{"DB_DRIVER", "db-driver", "database driver: sqlite3 or postgres", setString(func(c *Config) *string { return &c.DBDriver })},
+	{"DB_DSN", "db-dsn", "database connection string (required for postgres)", setString(func(c *Config) *string { return &c.DBDSN })},
+	{"DB_PATH", "db-path", "SQLite database file", setString(func(c *Config) *string { return &c.DBPath })},
+	{"AIRCRAFT_CONFIG_PATH", "aircraft-config", "JSON or YAML file with aircraft seat layouts", setString(func(c *Config) *string { return &c.AircraftConfigPath })},
+	{"VOUCHER_SEAT_COUNT", "seat-count", "seats drawn per voucher by default", setInt(func(c *Config) *int { return &c.DefaultSeatCount })},
+	{"VOUCHER_SEAT_EXPIRY", "seat-expiry", "time after the flight date until unredeemed seats expire (0 never)", setDuration(func(c *Config) *time.Duration { return &c.SeatExpiry })},
+	{"IDEMPOTENCY_KEY_TTL", "idempotency-key-ttl", "time an Idempotency-Key is remembered (0 forever)", setDuration(func(c *Config) *time.Duration { return &c.IdempotencyKeyTTL })},
+	{"VOUCHER_MAX_REGENERATIONS", "max-regenerations", "seat redraws allowed per voucher (0 unlimited)", setInt(func(c *Config) *int { return &c.MaxVoucherRegenerations })},
+	{"VOUCHER_MAX_SEAT_REGENERATIONS", "max-seat-regenerations", "redraws allowed per seat position (0 unlimited)", setInt(func(c *Config) *int { return &c.MaxSeatRegenerations })},
+	{"CORS_ORIGINS", "cors-origins", "comma-separated origins allowed to call the API", setList(func(c *Config) *[]string { return &c.CORSOrigins })},
+	{"LOG_LEVEL", "log-level", "log level: debug, info, warn or error", setString(func(c *Config) *string { return &c.LogLevel })},
+	{"JWT_SECRET", "jwt-secret", "secret that signs crew bearer tokens (HS256)", setString(func(c *Config) *string { return &c.JWTSecret })},
+	{"API_KEYS", "api-keys", "comma-separated name:key pairs of service account API keys", setKeyValues(func(c *Config) *map[string]string { return &c.APIKeys })},
+	{"API_KEY_ROLES", "api-key-roles", "comma-separated name:role pairs giving service accounts the supervisor or admin role", setKeyValues(func(c *Config) *map[string]string { return &c.APIKeyRoles })},
+	{"AUTH_DISABLED", "auth-disabled", "serve the API without authentication (local development only)", setBool(func(c *Config) *bool { return &c.AuthDisabled })},
+	{"TLS_CERT_FILE", "tls-cert", "TLS certificate file; enables HTTPS together with -tls-key", setString(func(c *Config) *string { return &c.TLSCertFile })},
+	{"TLS_KEY_FILE", "tls-key", "TLS private key file", setString(func(c *Config) *string { return &c.TLSKeyFile })},
+	{"READ_TIMEOUT", "read-timeout", "maximum duration for reading a request", setDuration(func(c *Config) *time.Duration { return &c.ReadTimeout })},
+	{"WRITE_TIMEOUT", "write-timeout", "maximum duration for writing a response", setDuration(func(c *Config) *time.Duration { return &c.WriteTimeout })},
+	{"IDLE_TIMEOUT", "idle-timeout", "maximum time to keep idle connections open", setDuration(func(c *Config) *time.Duration { return &c.IdleTimeout })},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "time allowed for in-flight requests on shutdown", setDuration(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+}
+
+// logLevels lists the accepted values of LogLevel
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// Load builds the effective configuration from the built-in defaults, an
+// optional config file (-config or CONFIG_FILE), environment variables and
+// command-line flags, each layer overriding the previous one, and validates
+// the result. It returns the arguments left after the flags, such as a
+// subcommand.
+func Load(args []string) (*Config, []string, error) {
+	fs := flag.NewFlagSet("airline-voucher-backend", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "JSON or YAML config file")
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		if s.set.isBool {
+			fs.Var(new(boolFlag), s.flag, usage)
+		} else {
+			fs.String(s.flag, "", usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := NewConfig()
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, s := range settings {
+		value := os.Getenv(s.env)
+		if value == "" {
+			continue
+		}
+		if err := s.set.apply(cfg, value); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: %w", s.env, err)
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag != f.Name || flagErr != nil {
+				continue
+			}
+			if err := s.set.apply(cfg, f.Value.String()); err != nil {
+				flagErr = fmt.Errorf("invalid -%s: %w", s.flag, err)
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// loadFile overlays the values present in a JSON or YAML config file.
+// Unknown keys are rejected so typos do not go unnoticed.
+func (c *Config) loadFile(path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+	default:
+		return fmt.Errorf("unsupported config file format: %s (use .json, .yaml or .yml)", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	defer file.Close()
+
+	// JSON is a subset of YAML, so one strict decoder handles both formats
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Validate checks that the configuration can be used to start the server and
+// reports every problem found
+func (c *Config) Validate() error {
+	var errs []error
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be a number between 1 and 65535, got %q", c.Port))
+	}
+
+	switch c.DBDriver {
+	case DriverSQLite:
+		if c.DataSource() == "" {
+			errs = append(errs, errors.New("database path is required for the sqlite3 driver"))
+		}
+	case DriverPostgres:
+		if c.DBDSN == "" {
+			errs = append(errs, errors.New("database DSN is required for the postgres driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("database driver must be %s or %s, got %q", DriverSQLite, DriverPostgres, c.DBDriver))
+	}
+
+	if c.DefaultSeatCount < 1 || c.DefaultSeatCount > utils.MaxSeatCount {
+		errs = append(errs, fmt.Errorf("default seat count must be between 1 and %d, got %d", utils.MaxSeatCount, c.DefaultSeatCount))
+	}
+
+	if c.SeatExpiry < 0 {
+		errs = append(errs, fmt.Errorf("seat expiry must not be negative, got %s", c.SeatExpiry))
+	}
+
+	if c.IdempotencyKeyTTL < 0 {
+		errs = append(errs, fmt.Errorf("idempotency key TTL must not be negative, got %s", c.IdempotencyKeyTTL))
+	}
+
+	if c.MaxVoucherRegenerations < 0 || c.MaxSeatRegenerations < 0 {
+		errs = append(errs, fmt.Errorf("regeneration limits must not be negative, got %d per voucher and %d per seat", c.MaxVoucherRegenerations, c.MaxSeatRegenerations))
+	}
+
+	if len(c.CORSOrigins) == 0 {
+		errs = append(errs, errors.New("at least one CORS origin is required"))
+	}
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+			errs = append(errs, fmt.Errorf("CORS origin must look like https://host[:port], got %q", origin))
+		}
+	}
+
+	if !containsString(logLevels, c.LogLevel) {
+		errs = append(errs, fmt.Errorf("log level must be one of %s, got %q", strings.Join(logLevels, ", "), c.LogLevel))
+	}
+
+	if c.JWTSecret != "" && len(c.JWTSecret) < auth.MinSecretLength {
+		errs = append(errs, fmt.Errorf("JWT secret must be at least %d characters", auth.MinSecretLength))
+	}
+	for _, name := range sortedKeys(c.APIKeys) {
+		if name == "" || len(c.APIKeys[name]) < auth.MinAPIKeyLength {
+			errs = append(errs, fmt.Errorf("API key %q must have a name and at least %d characters", name, auth.MinAPIKeyLength))
+		}
+	}
+	for _, name := range sortedKeys(c.APIKeyRoles) {
+		if _, ok := c.APIKeys[name]; !ok {
+			errs = append(errs, fmt.Errorf("API key role for %q has no matching API key", name))
+		}
+		if role := c.APIKeyRoles[name]; !models.ValidRole(role) {
+			errs = append(errs, fmt.Errorf("API key role for %q must be one of %s, got %q", name, strings.Join(models.Roles, ", "), role))
+		}
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS certificate and key files must be set together"))
+	}
+	for _, path := range []string{c.TLSCertFile, c.TLSKeyFile} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("TLS file %s: %w", path, err))
+		}
+	}
+
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"read timeout", c.ReadTimeout},
+		{"write timeout", c.WriteTimeout},
+		{"idle timeout", c.IdleTimeout},
+		{"shutdown timeout", c.ShutdownTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %s", timeout.name, timeout.value))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Print writes the effective configuration with secrets redacted
+func (c *Config) Print(w io.Writer) error {
+	aircraftConfig := c.AircraftConfigPath
+	if aircraftConfig == "" {
+		aircraftConfig = "built-in"
+	}
+
+	tls := "disabled"
+	if c.TLSEnabled() {
+		tls = fmt.Sprintf("cert=%s key=%s", c.TLSCertFile, c.TLSKeyFile)
+	}
+
+	authentication := "not configured"
+	switch {
+	case c.AuthDisabled:
+		authentication = "disabled"
+	case c.AuthConfigured():
+		var methods []string
+		if c.JWTSecret != "" {
+			methods = append(methods, "bearer tokens")
+		}
+		if len(c.APIKeys) > 0 {
+			var accounts []string
+			for _, name := range sortedKeys(c.APIKeys) {
+				role := c.APIKeyRoles[name]
+				if role == "" {
+					role = models.RoleCrew
+				}
+				accounts = append(accounts, fmt.Sprintf("%s: %s", name, role))
+			}
+			methods = append(methods, fmt.Sprintf("API keys (%s)", strings.Join(accounts, ", ")))
+		}
+		authentication = strings.Join(methods, ", ")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Effective configuration:")
+	fmt.Fprintf(tw, "  port\t%s\n", c.Port)
+	fmt.Fprintf(tw, "  db driver\t%s\n", c.DBDriver)
+	fmt.Fprintf(tw, "  db source\t%s\n", redactDSN(c.DataSource()))
+	fmt.Fprintf(tw, "  aircraft layouts\t%s\n", aircraftConfig)
+	fmt.Fprintf(tw, "  default seat count\t%d\n", c.DefaultSeatCount)
+	fmt.Fprintf(tw, "  seat expiry\t%s\n", c.SeatExpiry)
+	fmt.Fprintf(tw, "  idempotency key ttl\t%s\n", c.IdempotencyKeyTTL)
+	fmt.Fprintf(tw, "  regeneration limits\t%d per voucher, %d per seat\n", c.MaxVoucherRegenerations, c.MaxSeatRegenerations)
+	fmt.Fprintf(tw, "  cors origins\t%s\n", strings.Join(c.CORSOrigins, ", "))
+	fmt.Fprintf(tw, "  log level\t%s\n", c.LogLevel)
+	fmt.Fprintf(tw, "  authentication\t%s\n", authentication)
+	fmt.Fprintf(tw, "  tls\t%s\n", tls)
+	fmt.Fprintf(tw, "  read timeout\t%s\n", c.ReadTimeout)
+	fmt.Fprintf(tw, "  write timeout\t%s\n", c.WriteTimeout)
+	fmt.Fprintf(tw, "  idle timeout\t%s\n", c.IdleTimeout)
+	fmt.Fprintf(tw, "  shutdown timeout\t%s\n", c.ShutdownTimeout)
+	return tw.Flush()
+}
+
+// passwordPattern matches the password of a key=value connection string
+var passwordPattern = regexp.MustCompile(`(password=)\S+`)
+
+// redactDSN hides the password in a URL or key=value connection string
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.User != nil {
+		return u.Redacted()
+	}
+	return passwordPattern.ReplaceAllString(dsn, "${1}xxxxx")
+}
+
+func setString(field func(c *Config) *string) setter {
+	return setter{apply: func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}}
+}
+
+func setInt(field func(c *Config) *int) setter {
+	return setter{apply: func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func setDuration(field func(c *Config) *time.Duration) setter {
+	return setter{apply: func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 2m", value)
+		}
+		*field(c) = d
+		return nil
+	}}
+}
+
+func setBool(field func(c *Config) *bool) setter {
+	return setter{isBool: true, apply: func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*field(c) = b
+		return nil
+	}}
+}
+
+func setKeyValues(field func(c *Config) *map[string]string) setter {
+	return setter{apply: func(c *Config, value string) error {
+		pairs := make(map[string]string)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			key, val, ok := strings.Cut(item, ":")
+			if !ok {
+				return fmt.Errorf("%q is not a name:value pair", item)
+			}
+			pairs[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+		*field(c) = pairs
+		return nil
+	}}
+}
+
+func setList(field func(c *Config) *[]string) setter {
+	return setter{apply: func(c *Config, value string) error {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*field(c) = items
+		return nil
+	}}
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"airline-voucher-backend/config"
+	"airline-voucher-backend/export"
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
+	"airline-voucher-backend/services"
+)
+
+// runExport implements the "export" subcommand used by finance to pull
+// issued vouchers into a spreadsheet. It takes the same filters as
+// GET /api/vouchers/export.
+func runExport(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("o", "", "output file (default stdout); the extension picks the format")
+	format := fs.String("format", "", "csv or xlsx (default csv, or from the -o extension)")
+
+	var req models.ListVouchersRequest
+	fs.StringVar(&req.DateFrom, "date-from", "", "earliest flight date, YYYY-MM-DD")
+	fs.StringVar(&req.DateTo, "date-to", "", "latest flight date, YYYY-MM-DD")
+	fs.StringVar(&req.FlightNumber, "flight", "", "flight number prefix")
+	fs.StringVar(&req.Aircraft, "aircraft", "", "aircraft type")
+	fs.StringVar(&req.CrewID, "crew-id", "", "crew ID")
+	fs.StringVar(&req.Sort, "sort", "", "flightDate, flightNumber, createdAt or id; prefix with - for descending")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*output)), ".")
+		if !export.ValidFormat(*format) {
+			*format = export.FormatCSV
+		}
+	}
+	if !export.ValidFormat(*format) {
+		return fmt.Errorf("unsupported export format %q (use %s or %s)", *format, export.FormatCSV, export.FormatXLSX)
+	}
+
+	db, err := config.OpenDatabase(cfg.DBDriver, cfg.DataSource())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	repo, err := repository.NewSQLVoucherRepository(cfg.DBDriver, db)
+	if err != nil {
+		return err
+	}
+	service := services.NewVoucherService(repo)
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	writer, err := export.NewWriter(*format, out)
+	if err != nil {
+		return err
+	}
+
+	vouchers := 0
+	err = service.ExportVouchers(&req, func(voucher *models.Voucher) error {
+		vouchers++
+		return writer.Write(voucher)
+	})
+	if err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "Exported %d voucher(s) to %s\n", vouchers, *output)
+	}
+	return nil
+}
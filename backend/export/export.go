@@ -0,0 +1,185 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"airline-voucher-backend/models"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Supported export formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Columns is the header row of every export. Each seat of a voucher becomes
+// its own row, repeating the crew and flight details.
+var Columns = []string{
+	"voucher_id",
+	"flight_number",
+	"flight_date",
+	"aircraft_type",
+	"cabin_class",
+	"crew_name",
+	"crew_id",
+	"created_at",
+	"seat_position",
+	"seat",
+}
+
+// Writer writes vouchers as spreadsheet rows. Close must be called to
+// complete the file.
+type Writer interface {
+	// Write appends one row per seat of the voucher
+	Write(voucher *models.Voucher) error
+	// Close flushes buffered rows and finishes the file
+	Close() error
+}
+
+// ValidFormat reports whether format is a supported export format
+func ValidFormat(format string) bool {
+	return format == FormatCSV || format == FormatXLSX
+}
+
+// ContentType returns the MIME type of an export format
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// NewWriter creates a Writer for the format that writes to w
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q (use %s or %s)", format, FormatCSV, FormatXLSX)
+	}
+}
+
+// seatRows returns the export rows of a voucher, one per seat
+func seatRows(voucher *models.Voucher) [][]string {
+	rows := make([][]string, 0, len(voucher.Seats))
+	for i, seat := range voucher.Seats {
+		rows = append(rows, []string{
+			strconv.Itoa(voucher.ID),
+			voucher.FlightNumber,
+			voucher.FlightDate,
+			voucher.AircraftType,
+			voucher.CabinClass,
+			voucher.CrewName,
+			voucher.CrewID,
+			voucher.CreatedAt,
+			strconv.Itoa(i + 1),
+			seat,
+		})
+	}
+	return rows
+}
+
+// csvWriter streams rows through encoding/csv; memory use does not grow
+// with the number of vouchers
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	writer := &csvWriter{w: csv.NewWriter(w)}
+	if err := writer.w.Write(Columns); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (w *csvWriter) Write(voucher *models.Voucher) error {
+	return w.w.WriteAll(seatRows(voucher))
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// xlsxWriter uses excelize's stream writer, which spills rows to a temporary
+// file instead of holding the whole sheet in memory. The workbook is written
+// to the output on Close.
+type xlsxWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+// xlsxSheet is the name of the worksheet holding the export
+const xlsxSheet = "Vouchers"
+
+func newXLSXWriter(out io.Writer) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName("Sheet1", xlsxSheet); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	stream, err := file.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	writer := &xlsxWriter{out: out, file: file, stream: stream}
+	header := make([]interface{}, len(Columns))
+	for i, column := range Columns {
+		header[i] = column
+	}
+	if err := writer.appendRow(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+func (w *xlsxWriter) Write(voucher *models.Voucher) error {
+	for _, row := range seatRows(voucher) {
+		values := make([]interface{}, len(row))
+		for i, value := range row {
+			values[i] = value
+		}
+		// Keep numeric columns numeric so spreadsheets can sum and sort them
+		values[0] = voucher.ID
+		values[8], _ = strconv.Atoi(row[8])
+
+		if err := w.appendRow(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *xlsxWriter) appendRow(values []interface{}) error {
+	w.row++
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	return w.stream.SetRow(cell, values)
+}
+
+func (w *xlsxWriter) Close() error {
+	defer w.file.Close()
+
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	_, err := w.file.WriteTo(w.out)
+	return err
+}
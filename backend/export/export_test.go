@@ -0,0 +1,91 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"airline-voucher-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func testVouchers() []*models.Voucher {
+	return []*models.Voucher{
+		{
+			ID:           1,
+			CrewName:     "Sarah, Jr.",
+			CrewID:       "98123",
+			FlightNumber: "GA102",
+			FlightDate:   "2025-07-12",
+			AircraftType: "Airbus 320",
+			CabinClass:   "economy",
+			CreatedAt:    "2025-07-01 10:00:00",
+			Seats:        []string{"4A", "10C"},
+		},
+		{
+			ID:           2,
+			CrewName:     "Budi",
+			CrewID:       "77001",
+			FlightNumber: "QZ200",
+			FlightDate:   "2025-07-13",
+			AircraftType: "ATR",
+			CreatedAt:    "2025-07-02 09:30:00",
+			Seats:        []string{"7D"},
+		},
+	}
+}
+
+func writeAll(t *testing.T, format string) []byte {
+	var buf bytes.Buffer
+	writer, err := NewWriter(format, &buf)
+	require.NoError(t, err)
+	for _, voucher := range testVouchers() {
+		require.NoError(t, writer.Write(voucher))
+	}
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+var wantRows = [][]string{
+	Columns,
+	{"1", "GA102", "2025-07-12", "Airbus 320", "economy", "Sarah, Jr.", "98123", "2025-07-01 10:00:00", "1", "4A"},
+	{"1", "GA102", "2025-07-12", "Airbus 320", "economy", "Sarah, Jr.", "98123", "2025-07-01 10:00:00", "2", "10C"},
+	{"2", "QZ200", "2025-07-13", "ATR", "", "Budi", "77001", "2025-07-02 09:30:00", "1", "7D"},
+}
+
+func TestCSVWriter(t *testing.T) {
+	rows, err := csv.NewReader(bytes.NewReader(writeAll(t, FormatCSV))).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, wantRows, rows)
+}
+
+func TestXLSXWriter(t *testing.T) {
+	file, err := excelize.OpenReader(bytes.NewReader(writeAll(t, FormatXLSX)))
+	require.NoError(t, err)
+	defer file.Close()
+
+	rows, err := file.GetRows(xlsxSheet)
+	require.NoError(t, err)
+
+	// Empty trailing cells are dropped by GetRows; compare cell by cell
+	require.Len(t, rows, len(wantRows))
+	for i, want := range wantRows {
+		for j, cell := range want {
+			got := ""
+			if j < len(rows[i]) {
+				got = rows[i][j]
+			}
+			assert.Equal(t, cell, got, "row %d column %s", i, Columns[j])
+		}
+	}
+}
+
+func TestNewWriter_UnsupportedFormat(t *testing.T) {
+	_, err := NewWriter("pdf", &bytes.Buffer{})
+	assert.Error(t, err)
+	assert.False(t, ValidFormat("pdf"))
+	assert.True(t, ValidFormat(FormatXLSX))
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"airline-voucher-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLayoutsSize caps the body of an aircraft layouts upload
+const maxLayoutsSize = 1 << 20
+
+// ReplaceAircraftLayouts handles PUT /api/aircraft-layouts requests. The body
+// has the shape of the layouts file, as JSON or, with a YAML content type, as
+// YAML, and replaces every active layout once it validates.
+func (h *VoucherHandler) ReplaceAircraftLayouts(c *gin.Context) {
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxLayoutsSize))
+	if err != nil {
+		respondBindError(c, fmt.Errorf("failed to read aircraft layouts: %w", err))
+		return
+	}
+
+	aircraft, err := h.serviceFor(c).ReplaceAircraftLayouts(data, layoutsFormat(c))
+	if err != nil {
+		respondServiceError(c, err, "Failed to replace aircraft layouts")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AircraftLayoutsResponse{
+		Success:  true,
+		Aircraft: aircraft,
+	})
+}
+
+// layoutsFormat returns the layout format named by the request's content
+// type, JSON unless it is a YAML type
+func layoutsFormat(c *gin.Context) string {
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	switch mediaType {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return "yaml"
+	default:
+		return "json"
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"airline-voucher-backend/auth"
+	"airline-voucher-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader carries the static API key of a service account
+const APIKeyHeader = "X-API-Key"
+
+// principalKey stores the authenticated caller in the Gin context
+const principalKey = "principal"
+
+// Authenticate returns middleware that requires every request to carry a
+// crew bearer token or a service account API key, and answers 401 in the
+// ErrorResponse shape when it does not
+func Authenticate(authenticator *auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := authenticator.Authenticate(c.GetHeader("Authorization"), c.GetHeader(APIKeyHeader))
+		if err != nil {
+			title := "Invalid credentials"
+			if errors.Is(err, auth.ErrMissingCredentials) {
+				title = "Authentication required"
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="vouchers"`)
+			respondError(c, http.StatusUnauthorized, models.CodeUnauthorized, title, err.Error())
+			c.Abort()
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// RequireRole returns middleware that only lets through callers holding role
+// or a more privileged one, and answers 403 in the ErrorResponse shape
+// otherwise. When the API runs without authentication every request passes.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := principalFrom(c)
+		if principal != nil && !principal.HasRole(role) {
+			respondError(c, http.StatusForbidden, models.CodeForbidden, "Forbidden",
+				fmt.Sprintf("%s %s may not use %s %s (requires the %s role)", principal.Role, principal.ID, c.Request.Method, c.FullPath(), role))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// principalFrom returns the authenticated caller, or nil when the API runs
+// without authentication
+func principalFrom(c *gin.Context) *models.Principal {
+	principal, _ := c.Get(principalKey)
+	p, _ := principal.(*models.Principal)
+	return p
+}
+
+// actingCrew replaces the crew ID, and the crew name when name is not nil
+// and the token carries one, that a request body claims with the identity of
+// the authenticated crew member. Service accounts act on behalf of crew, so
+// their requests keep the body values, as do requests when authentication is
+// disabled.
+func actingCrew(c *gin.Context, id, name *string) {
+	principal := principalFrom(c)
+	if principal == nil || principal.ServiceAccount {
+		return
+	}
+
+	*id = principal.ID
+	if name != nil && principal.Name != "" {
+		*name = principal.Name
+	}
+}
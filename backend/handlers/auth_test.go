@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"airline-voucher-backend/auth"
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
+	"airline-voucher-backend/services"
+	"airline-voucher-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAPIKey = "reporting-key-0123456789abcdef"
+
+const schedulerAPIKey = "scheduler-key-0123456789abcdef"
+
+// newAuthTestRouter serves part of the voucher API behind Authenticate and
+// the role groups of main.go
+func newAuthTestRouter(t *testing.T) (*gin.Engine, *auth.Authenticator) {
+	authenticator, err := auth.NewAuthenticator("0123456789abcdef0123456789abcdef",
+		map[string]string{"reporting": testAPIKey, "scheduler": schedulerAPIKey},
+		map[string]string{"reporting": models.RoleAdmin})
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	handler := NewVoucherHandler(services.NewVoucherService(repository.NewMemoryVoucherRepository()))
+	router := gin.New()
+	api := router.Group("/api", Authenticate(authenticator))
+	crew := api.Group("", RequireRole(models.RoleCrew))
+	crew.POST("/generate", handler.GenerateVoucher)
+	crew.POST("/generate/batch", handler.GenerateBatch)
+	crew.GET("/vouchers/:id", handler.GetVoucherResource)
+	crew.POST("/vouchers/:id/redeem", handler.RedeemSeatResource)
+	supervisor := api.Group("", RequireRole(models.RoleSupervisor))
+	supervisor.PATCH("/vouchers/:id", handler.PatchVoucherResource)
+	supervisor.POST("/vouchers/:id/void", handler.VoidSeatResource)
+	supervisor.PUT("/occupancy/:flightNumber/:date", handler.SetOccupancy)
+	admin := api.Group("", RequireRole(models.RoleAdmin))
+	admin.GET("/vouchers/export", handler.ExportVouchers)
+	admin.PUT("/aircraft-layouts", handler.ReplaceAircraftLayouts)
+	router.GET("/health", handler.HealthCheck)
+
+	return router, authenticator
+}
+
+func bearer(t *testing.T, authenticator *auth.Authenticator, principal *models.Principal) map[string]string {
+	token, err := authenticator.IssueToken(principal, time.Hour)
+	require.NoError(t, err)
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+func TestAuthenticate_RejectsMissingAndInvalidCredentials(t *testing.T) {
+	router, _ := newAuthTestRouter(t)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{"No credentials", nil},
+		{"Malformed token", map[string]string{"Authorization": "Bearer not-a-jwt"}},
+		{"Wrong scheme", map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}},
+		{"Unknown API key", map[string]string{APIKeyHeader: "reporting-key-wrong"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, "GET", "/api/vouchers/1", nil, tt.headers)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Equal(t, `Bearer realm="vouchers"`, w.Header().Get("WWW-Authenticate"))
+
+			var response models.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, models.CodeUnauthorized, response.Code)
+		})
+	}
+
+	// The health check stays open for load balancers
+	assert.Equal(t, http.StatusOK, serve(router, "GET", "/health", nil, nil).Code)
+}
+
+func TestAuthenticate_CrewIdentityComesFromToken(t *testing.T) {
+	router, authenticator := newAuthTestRouter(t)
+	crew := bearer(t, authenticator, &models.Principal{ID: "98123", Name: "Sarah", Flights: []string{"GA102"}})
+
+	// The body claims someone else; the voucher is recorded for the token holder
+	w := serve(router, "POST", "/api/generate", map[string]interface{}{
+		"name": "Mallory", "id": "66666", "flightNumber": "GA102", "date": "2025-07-12", "aircraft": "ATR",
+	}, crew)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// A service account acts on behalf of the crew member named in the body
+	w = serve(router, "POST", "/api/generate", map[string]interface{}{
+		"name": "Budi", "id": "77001", "flightNumber": "QZ200", "date": "2025-07-12", "aircraft": "ATR",
+	}, map[string]string{APIKeyHeader: testAPIKey})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = serve(router, "GET", "/api/vouchers/1", nil, crew)
+	require.Equal(t, http.StatusOK, w.Code)
+	var response models.GetVoucherResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "98123", response.Voucher.CrewID)
+	assert.Equal(t, "Sarah", response.Voucher.CrewName)
+
+	w = serve(router, "GET", "/api/vouchers/2", nil, crew)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "77001", response.Voucher.CrewID)
+
+	// Crew no longer need to repeat their ID in the body
+	w = serve(router, "POST", "/api/vouchers/1/redeem", map[string]interface{}{"seatPosition": 1}, crew)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "98123", response.Voucher.SeatStates[0].RedeemedBy)
+}
+
+func TestAuthenticate_CrewBatchCSVWithoutIdentityColumns(t *testing.T) {
+	router, authenticator := newAuthTestRouter(t)
+	upload := func(csv string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/api/generate/batch", strings.NewReader(csv))
+		req.Header.Set("Content-Type", "text/csv")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	const schedule = "flightNumber,date,aircraft\nGA102,2025-07-12,ATR\n"
+
+	// The token says who the crew member is
+	crew := bearer(t, authenticator, &models.Principal{ID: "98123", Name: "Sarah", Flights: []string{"GA102", "GA103"}})
+	w := upload(schedule, crew)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 1, decodeBatchResponse(t, w).Created)
+
+	w = serve(router, "GET", "/api/vouchers/1", nil, crew)
+	var response models.GetVoucherResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "98123", response.Voucher.CrewID)
+	assert.Equal(t, "Sarah", response.Voucher.CrewName)
+
+	// A token without a name still needs the name column
+	unnamed := bearer(t, authenticator, &models.Principal{ID: "98123", Flights: []string{"GA103"}})
+	w = upload("flightNumber,date,aircraft\nGA103,2025-07-12,ATR\n", unnamed)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "missing the name column")
+	w = upload("flightNumber,date,aircraft,name\nGA103,2025-07-12,ATR,Sarah\n", unnamed)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Service accounts act on behalf of crew, who must be named
+	w = upload(schedule, map[string]string{APIKeyHeader: schedulerAPIKey})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "missing the name column")
+}
+
+func TestAuthenticate_AircraftLayoutsNeedAdmin(t *testing.T) {
+	router, authenticator := newAuthTestRouter(t)
+	crew := bearer(t, authenticator, &models.Principal{ID: "98123", Name: "Sarah", Flights: []string{"GA102"}})
+	supervisor := bearer(t, authenticator, &models.Principal{ID: "77001", Name: "Budi", Role: models.RoleSupervisor})
+	admin := map[string]string{APIKeyHeader: testAPIKey}
+
+	// The built-in fleet plus one more aircraft
+	const builtIn = "../utils/aircraft.json"
+	t.Cleanup(func() { require.NoError(t, utils.LoadAircraftConfigs(builtIn)) })
+	data, err := os.ReadFile(builtIn)
+	require.NoError(t, err)
+	var fleet utils.AircraftFleet
+	require.NoError(t, json.Unmarshal(data, &fleet))
+	fleet.Aircraft = append(fleet.Aircraft, utils.AircraftConfig{Type: "Dash 8", Rows: 20, Seats: []string{"A", "C", "D", "F"}})
+
+	for _, headers := range []map[string]string{crew, supervisor} {
+		w := serve(router, "PUT", "/api/aircraft-layouts", fleet, headers)
+		require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	}
+	assert.False(t, utils.ValidateAircraftType("Dash 8"))
+
+	w := serve(router, "PUT", "/api/aircraft-layouts", fleet, admin)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response models.AircraftLayoutsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Contains(t, response.Aircraft, "Dash 8")
+	assert.True(t, utils.ValidateAircraftType("Dash 8"))
+
+	// Invalid layouts leave the active ones in place
+	w = serve(router, "PUT", "/api/aircraft-layouts", map[string]interface{}{"aircraft": []interface{}{}}, admin)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	var errResponse models.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResponse))
+	assert.Equal(t, models.CodeInvalidAircraftLayouts, errResponse.Code)
+	assert.True(t, utils.ValidateAircraftType("Dash 8"))
+}
+
+func TestRequireRole(t *testing.T) {
+	router, authenticator := newAuthTestRouter(t)
+	crew := bearer(t, authenticator, &models.Principal{ID: "98123", Name: "Sarah", Flights: []string{"GA102"}})
+	supervisor := bearer(t, authenticator, &models.Principal{ID: "77001", Name: "Budi", Role: models.RoleSupervisor})
+	admin := map[string]string{APIKeyHeader: testAPIKey}
+	scheduler := map[string]string{APIKeyHeader: schedulerAPIKey}
+
+	generate := func(flightNumber string) map[string]interface{} {
+		return map[string]interface{}{"name": "Sarah", "id": "98123", "flightNumber": flightNumber, "date": "2025-07-12", "aircraft": "ATR"}
+	}
+	redraw := map[string]interface{}{"seatPosition": 3, "reason": models.ReasonPassengerRequest}
+	occupancy := models.SetOccupancyRequest{Seats: []string{"1A"}}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         interface{}
+		headers      map[string]string
+		expectedCode int
+	}{
+		{"Crew generates for their flight", "POST", "/api/generate", generate("GA102"), crew, http.StatusOK},
+		{"Crew generates for another flight", "POST", "/api/generate", generate("QZ200"), crew, http.StatusForbidden},
+		{"Supervisor generates for any flight", "POST", "/api/generate", generate("QZ200"), supervisor, http.StatusOK},
+		{"Crew service account generates for any flight", "POST", "/api/generate", generate("JT610"), scheduler, http.StatusOK},
+		{"Crew redeems", "POST", "/api/vouchers/1/redeem", map[string]interface{}{"seatPosition": 1}, crew, http.StatusOK},
+		{"Crew redraws", "PATCH", "/api/vouchers/1", redraw, crew, http.StatusForbidden},
+		{"Crew voids", "POST", "/api/vouchers/1/void", map[string]interface{}{"seatPosition": 2}, crew, http.StatusForbidden},
+		{"Crew service account voids", "POST", "/api/vouchers/1/void", map[string]interface{}{"seatPosition": 2}, scheduler, http.StatusForbidden},
+		{"Supervisor redraws", "PATCH", "/api/vouchers/1", redraw, supervisor, http.StatusOK},
+		{"Supervisor voids", "POST", "/api/vouchers/1/void", map[string]interface{}{"seatPosition": 2}, supervisor, http.StatusOK},
+		{"Crew uploads occupancy", "PUT", "/api/occupancy/GA102/2025-07-13", occupancy, crew, http.StatusForbidden},
+		{"Supervisor uploads occupancy", "PUT", "/api/occupancy/GA102/2025-07-13", occupancy, supervisor, http.StatusOK},
+		{"Supervisor exports", "GET", "/api/vouchers/export", nil, supervisor, http.StatusForbidden},
+		{"Admin exports", "GET", "/api/vouchers/export", nil, admin, http.StatusOK},
+		{"Admin service account redraws without naming the crew member", "PATCH", "/api/vouchers/2", redraw, admin, http.StatusBadRequest},
+	}
+
+	// The cases run in order; later ones use the vouchers of earlier ones
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, tt.method, tt.path, tt.body, tt.headers)
+			require.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+
+			if tt.expectedCode == http.StatusForbidden {
+				var response models.ErrorResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, models.CodeForbidden, response.Code)
+			}
+		})
+	}
+}
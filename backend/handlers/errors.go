@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serviceError describes how a service error is presented over HTTP
+type serviceError struct {
+	err    error
+	status int
+	code   string
+	title  string
+}
+
+// serviceErrors maps service errors to responses. Errors are matched with
+// errors.Is in order, so wrapped errors map the same as the sentinels.
+var serviceErrors = []serviceError{
+	{services.ErrVoucherExists, http.StatusConflict, models.CodeVoucherExists, "Voucher already exists"},
+	{services.ErrVoucherNotFound, http.StatusNotFound, models.CodeVoucherNotFound, "Voucher not found"},
+	{services.ErrMissingFields, http.StatusBadRequest, models.CodeMissingFields, "Missing required fields"},
+	{services.ErrInvalidAircraft, http.StatusBadRequest, models.CodeInvalidAircraft, "Invalid aircraft type"},
+	{services.ErrInvalidDate, http.StatusBadRequest, models.CodeInvalidDate, "Invalid date format"},
+	{services.ErrInvalidCabinClass, http.StatusBadRequest, models.CodeInvalidCabinClass, "Invalid cabin class"},
+	{services.ErrInvalidSeatCount, http.StatusBadRequest, models.CodeInvalidSeatCount, "Invalid seat count"},
+	{services.ErrInvalidSeatPosition, http.StatusBadRequest, models.CodeInvalidSeatPosition, "Invalid seat position"},
+	{services.ErrInvalidSeatTransition, http.StatusConflict, models.CodeInvalidSeatTransition, "Invalid seat status transition"},
+	{services.ErrInvalidReason, http.StatusBadRequest, models.CodeInvalidReason, "Invalid regeneration reason"},
+	{services.ErrRegenerationLimitReached, http.StatusUnprocessableEntity, models.CodeRegenerationLimitReached, "Regeneration limit reached"},
+	{services.ErrInvalidQuery, http.StatusBadRequest, models.CodeInvalidQuery, "Invalid query"},
+	{services.ErrInvalidBatch, http.StatusBadRequest, models.CodeInvalidBatch, "Invalid batch"},
+	{services.ErrInvalidIdempotencyKey, http.StatusBadRequest, models.CodeInvalidIdempotencyKey, "Invalid idempotency key"},
+	{services.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, models.CodeIdempotencyKeyReused, "Idempotency key already used"},
+	{services.ErrInvalidSeatPreference, http.StatusBadRequest, models.CodeInvalidSeatPreference, "Invalid seat preference"},
+	{services.ErrSeatPreferenceUnsatisfiable, http.StatusUnprocessableEntity, models.CodeSeatPreferenceUnsatisfiable, "Seat preference cannot be satisfied"},
+	{services.ErrSeatTaken, http.StatusConflict, models.CodeSeatTaken, "Seat already assigned"},
+	{services.ErrInvalidOccupancy, http.StatusBadRequest, models.CodeInvalidOccupancy, "Invalid occupancy"},
+	{services.ErrInvalidAircraftLayouts, http.StatusBadRequest, models.CodeInvalidAircraftLayouts, "Invalid aircraft layouts"},
+	{services.ErrNotEnoughSeats, http.StatusUnprocessableEntity, models.CodeNotEnoughSeats, "Not enough seats"},
+	{services.ErrPreconditionFailed, http.StatusPreconditionFailed, models.CodePreconditionFailed, "Voucher has changed"},
+	{services.ErrForbidden, http.StatusForbidden, models.CodeForbidden, "Forbidden"},
+}
+
+// respondError writes an ErrorResponse with the given status and code
+func respondError(c *gin.Context, status int, code, title, message string) {
+	c.JSON(status, models.ErrorResponse{
+		Error:   title,
+		Code:    code,
+		Message: message,
+	})
+}
+
+// respondBindError reports a request body that could not be decoded or validated
+func respondBindError(c *gin.Context, err error) {
+	respondError(c, http.StatusBadRequest, models.CodeInvalidRequest, "Invalid request body", err.Error())
+}
+
+// respondServiceError maps an error returned by VoucherService to an HTTP
+// response. Unknown errors become a 500 with the given title.
+func respondServiceError(c *gin.Context, err error, fallbackTitle string) {
+	if mapping, ok := lookupServiceError(err); ok {
+		respondError(c, mapping.status, mapping.code, mapping.title, err.Error())
+		return
+	}
+
+	respondError(c, http.StatusInternalServerError, models.CodeInternal, fallbackTitle, err.Error())
+}
+
+// serviceErrorCode returns the error code of a service error, or
+// CodeInternal for unknown errors
+func serviceErrorCode(err error) string {
+	if mapping, ok := lookupServiceError(err); ok {
+		return mapping.code
+	}
+	return models.CodeInternal
+}
+
+// lookupServiceError finds the first mapping that matches err
+func lookupServiceError(err error) (serviceError, bool) {
+	for _, mapping := range serviceErrors {
+		if errors.Is(err, mapping.err) {
+			return mapping, true
+		}
+	}
+	return serviceError{}, false
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRespondServiceError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "Voucher exists",
+			err:            fmt.Errorf("%w for flight GA102 on 2025-07-12", services.ErrVoucherExists),
+			expectedStatus: http.StatusConflict,
+			expectedCode:   models.CodeVoucherExists,
+		},
+		{
+			name:           "Voucher not found",
+			err:            fmt.Errorf("%w for flight GA102 on 2025-07-12", services.ErrVoucherNotFound),
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   models.CodeVoucherNotFound,
+		},
+		{
+			name:           "Invalid aircraft",
+			err:            fmt.Errorf("%w: Concorde", services.ErrInvalidAircraft),
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   models.CodeInvalidAircraft,
+		},
+		{
+			name:           "Invalid date",
+			err:            fmt.Errorf("%w: 12-07-2025 (expected YYYY-MM-DD)", services.ErrInvalidDate),
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   models.CodeInvalidDate,
+		},
+		{
+			name:           "Doubly wrapped",
+			err:            fmt.Errorf("failed to generate seats: %w", fmt.Errorf("%w on ATR: need 80, have 72", services.ErrNotEnoughSeats)),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   models.CodeNotEnoughSeats,
+		},
+		{
+			name:           "Invalid seat preference",
+			err:            fmt.Errorf("%w: unknown seat position \"exit\"", services.ErrInvalidSeatPreference),
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   models.CodeInvalidSeatPreference,
+		},
+		{
+			name:           "Seat preference unsatisfiable",
+			err:            fmt.Errorf("failed to generate seats: %w", fmt.Errorf("%w: ATR has 0 middle seats", services.ErrSeatPreferenceUnsatisfiable)),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   models.CodeSeatPreferenceUnsatisfiable,
+		},
+		{
+			name:           "Seat taken",
+			err:            fmt.Errorf("failed to update seat: %w", fmt.Errorf("%w: 12C", services.ErrSeatTaken)),
+			expectedStatus: http.StatusConflict,
+			expectedCode:   models.CodeSeatTaken,
+		},
+		{
+			name:           "Invalid seat transition",
+			err:            fmt.Errorf("%w: seat 12C is voided and cannot be redeemed", services.ErrInvalidSeatTransition),
+			expectedStatus: http.StatusConflict,
+			expectedCode:   models.CodeInvalidSeatTransition,
+		},
+		{
+			name:           "Regeneration limit reached",
+			err:            fmt.Errorf("%w: seat 1 of flight GA102 on 2025-07-12 was already redrawn 2 times (limit 2)", services.ErrRegenerationLimitReached),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   models.CodeRegenerationLimitReached,
+		},
+		{
+			name:           "Forbidden",
+			err:            fmt.Errorf("%w: crew 98123 may not void seats (requires the supervisor role)", services.ErrForbidden),
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   models.CodeForbidden,
+		},
+		{
+			name:           "Unknown error",
+			err:            errors.New("disk full"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   models.CodeInternal,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			respondServiceError(c, tt.err, "Failed")
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			var response models.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedCode, response.Code)
+			assert.Equal(t, tt.err.Error(), response.Message)
+		})
+	}
+}
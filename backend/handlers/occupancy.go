@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"airline-voucher-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOccupancy handles GET /api/occupancy/:flightNumber/:date requests
+func (h *VoucherHandler) GetOccupancy(c *gin.Context) {
+	occupancy, err := h.service.GetOccupancy(c.Param("flightNumber"), c.Param("date"))
+	if err != nil {
+		respondServiceError(c, err, "Failed to get occupancy")
+		return
+	}
+
+	c.JSON(http.StatusOK, occupancy)
+}
+
+// SetOccupancy handles PUT /api/occupancy/:flightNumber/:date requests. The
+// seats come from a JSON SetOccupancyRequest, or from a passenger manifest
+// sent as a text/csv body or a multipart upload in the "file" field. The
+// upload replaces the flight's occupied seats.
+func (h *VoucherHandler) SetOccupancy(c *gin.Context) {
+	seats, err := bindOccupancySeats(c)
+	if err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	occupancy, err := h.serviceFor(c).SetOccupancy(c.Param("flightNumber"), c.Param("date"), seats)
+	if err != nil {
+		respondServiceError(c, err, "Failed to save occupancy")
+		return
+	}
+
+	c.JSON(http.StatusOK, occupancy)
+}
+
+// DeleteOccupancy handles DELETE /api/occupancy/:flightNumber/:date requests
+func (h *VoucherHandler) DeleteOccupancy(c *gin.Context) {
+	if _, err := h.serviceFor(c).SetOccupancy(c.Param("flightNumber"), c.Param("date"), nil); err != nil {
+		respondServiceError(c, err, "Failed to clear occupancy")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// bindOccupancySeats reads the occupied seats in whichever format the request uses
+func bindOccupancySeats(c *gin.Context) ([]string, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+
+	switch mediaType {
+	case "multipart/form-data":
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("manifest upload must be in the \"file\" field: %w", err)
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		return parseManifestCSV(file)
+
+	case "text/csv":
+		return parseManifestCSV(c.Request.Body)
+
+	default:
+		var req models.SetOccupancyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return nil, err
+		}
+		return req.Seats, nil
+	}
+}
+
+// parseManifestCSV reads the seat column of a passenger manifest. The first
+// row names the columns; columns other than "seat" are ignored, and passengers
+// without a seat may leave it blank.
+func parseManifestCSV(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("manifest is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	column := -1
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")), "seat") {
+			column = i
+			break
+		}
+	}
+	if column < 0 {
+		return nil, errors.New("manifest header is missing the seat column")
+	}
+
+	seats := []string{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if column < len(record) {
+			seats = append(seats, record[column])
+		}
+	}
+
+	return seats, nil
+}
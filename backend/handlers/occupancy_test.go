@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"airline-voucher-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeOccupancy(t *testing.T, body []byte) models.Occupancy {
+	var occupancy models.Occupancy
+	require.NoError(t, json.Unmarshal(body, &occupancy))
+	return occupancy
+}
+
+func putOccupancy(router *gin.Engine, path, contentType string, body *bytes.Buffer) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPut, path, body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOccupancy_JSON(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	w := serve(router, http.MethodGet, "/api/occupancy/GA200/2025-07-12", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, decodeOccupancy(t, w.Body.Bytes()).Seats)
+
+	w = serve(router, http.MethodPut, "/api/occupancy/GA200/2025-07-12", models.SetOccupancyRequest{Seats: []string{"1a", "2C"}}, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	occupancy := decodeOccupancy(t, w.Body.Bytes())
+	assert.Equal(t, "GA200", occupancy.FlightNumber)
+	assert.Equal(t, []string{"1A", "2C"}, occupancy.Seats)
+
+	w = serve(router, http.MethodGet, "/api/occupancy/GA200/2025-07-12", nil, nil)
+	assert.ElementsMatch(t, []string{"1A", "2C"}, decodeOccupancy(t, w.Body.Bytes()).Seats)
+
+	w = serve(router, http.MethodDelete, "/api/occupancy/GA200/2025-07-12", nil, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = serve(router, http.MethodGet, "/api/occupancy/GA200/2025-07-12", nil, nil)
+	assert.Empty(t, decodeOccupancy(t, w.Body.Bytes()).Seats)
+}
+
+func TestOccupancy_Manifest(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	manifest := "\ufeffPNR,Passenger,Seat\nABC123,Jane Doe,3A\nDEF456,John Roe,\nGHI789,Ann Poe,3C\n"
+	w := putOccupancy(router, "/api/occupancy/GA200/2025-07-12", "text/csv", bytes.NewBufferString(manifest))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"3A", "3C"}, decodeOccupancy(t, w.Body.Bytes()).Seats)
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	file, err := form.CreateFormFile("file", "manifest.csv")
+	require.NoError(t, err)
+	_, err = file.Write([]byte("seat\n10F\n"))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	w = putOccupancy(router, "/api/occupancy/GA200/2025-07-12", form.FormDataContentType(), body)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"10F"}, decodeOccupancy(t, w.Body.Bytes()).Seats)
+}
+
+func TestOccupancy_Errors(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	tests := []struct {
+		name         string
+		path         string
+		contentType  string
+		body         string
+		expectedCode string
+	}{
+		{"no seat column", "/api/occupancy/GA200/2025-07-12", "text/csv", "passenger\nJane\n", models.CodeInvalidRequest},
+		{"invalid seat", "/api/occupancy/GA200/2025-07-12", "application/json", `{"seats": ["3A", "window"]}`, models.CodeInvalidOccupancy},
+		{"invalid date", "/api/occupancy/GA200/12-07-2025", "application/json", `{"seats": ["3A"]}`, models.CodeInvalidDate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := putOccupancy(router, tt.path, tt.contentType, bytes.NewBufferString(tt.body))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response models.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedCode, response.Code)
+		})
+	}
+}
+
+func TestOccupancy_GenerateSkipsOccupiedSeats(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	// Leave three business seats free
+	occupied := []string{"1A", "1C", "1D", "1F", "2A", "2C", "2D", "2F", "3A"}
+	w := serve(router, http.MethodPut, "/api/occupancy/GA200/2025-07-12", models.SetOccupancyRequest{Seats: occupied}, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req := models.GenerateVoucherRequest{Name: "Sarah", ID: "98123", FlightNumber: "GA200", Date: "2025-07-12", Aircraft: "Airbus 320", CabinClass: "business"}
+	w = serve(router, http.MethodPost, "/api/generate", req, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response models.GenerateVoucherResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.ElementsMatch(t, []string{"3C", "3D", "3F"}, response.Seats)
+
+	req.FlightNumber = "GA201"
+	w = serve(router, http.MethodPut, "/api/occupancy/GA201/2025-07-12", models.SetOccupancyRequest{Seats: append(occupied, "3C")}, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = serve(router, http.MethodPost, "/api/generate", req, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), models.CodeNotEnoughSeats)
+}
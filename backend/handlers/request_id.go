@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"airline-voucher-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID that ties a request to the voucher events it
+// records. Clients may send their own; otherwise one is generated.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 128
+
+// requestIDKey stores the request ID in the Gin context
+const requestIDKey = "requestID"
+
+// RequestID returns middleware that assigns every request an ID, taken from
+// the X-Request-ID header when it is present and reasonable, and echoes it
+// in the response
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := strings.TrimSpace(c.GetHeader(RequestIDHeader))
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// newRequestID returns a random 128-bit ID in hex
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// serviceFor returns the service scoped to the request, so the voucher
+// events it records carry the request ID and its changes are checked
+// against the caller's role
+func (h *VoucherHandler) serviceFor(c *gin.Context) *services.VoucherService {
+	return h.service.WithRequestID(c.GetString(requestIDKey)).WithPrincipal(principalFrom(c))
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// batchColumns maps the accepted CSV header names, lowercased, to setters on
+// a generate request. Columns may appear in any order.
+var batchColumns = map[string]func(req *models.GenerateVoucherRequest, value string) error{
+	"name":         func(req *models.GenerateVoucherRequest, value string) error { req.Name = value; return nil },
+	"id":           func(req *models.GenerateVoucherRequest, value string) error { req.ID = value; return nil },
+	"flightnumber": func(req *models.GenerateVoucherRequest, value string) error { req.FlightNumber = value; return nil },
+	"date":         func(req *models.GenerateVoucherRequest, value string) error { req.Date = value; return nil },
+	"aircraft":     func(req *models.GenerateVoucherRequest, value string) error { req.Aircraft = value; return nil },
+	"cabinclass":   func(req *models.GenerateVoucherRequest, value string) error { req.CabinClass = value; return nil },
+	"seatcount": func(req *models.GenerateVoucherRequest, value string) error {
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("seatCount %q is not a number", value)
+		}
+		req.SeatCount = n
+		return nil
+	},
+	"seatpreference": func(req *models.GenerateVoucherRequest, value string) error { req.SeatPreference = value; return nil },
+	"seatmix": func(req *models.GenerateVoucherRequest, value string) error {
+		mix, err := parseSeatMix(value)
+		if err != nil {
+			return err
+		}
+		req.SeatMix = mix
+		return nil
+	},
+}
+
+// parseSeatMix reads a seat mix cell such as "window:1;aisle:1"
+func parseSeatMix(value string) (map[string]int, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	mix := make(map[string]int)
+	for _, part := range strings.Split(value, ";") {
+		position, count, ok := strings.Cut(part, ":")
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if !ok || err != nil {
+			return nil, fmt.Errorf("seatMix %q must look like window:1;aisle:1", value)
+		}
+		mix[strings.TrimSpace(position)] = n
+	}
+	return mix, nil
+}
+
+// maxBatchBodySize caps the body of a batch request, generously for
+// services.MaxBatchSize items in any of the accepted formats
+const maxBatchBodySize = 1 << 20
+
+// requiredBatchColumns returns the columns that must be present in the
+// header of a CSV upload by a principal. The token of a crew member supplies
+// their ID, and their name when it carries one, so crew may leave those
+// columns out; service accounts and unauthenticated requests may not.
+func requiredBatchColumns(principal *models.Principal) []string {
+	required := []string{"flightNumber", "date", "aircraft"}
+	if principal == nil || principal.ServiceAccount {
+		return append(required, "name", "id")
+	}
+	if principal.Name == "" {
+		required = append(required, "name")
+	}
+	return required
+}
+
+// GenerateBatch handles POST /api/generate/batch requests. The items come
+// from a JSON BatchGenerateRequest, a text/csv body or a multipart upload in
+// the "file" field; the mode query parameter overrides the mode in the body.
+// The response lists the outcome of every item. A rolled-back atomic batch
+// is reported with 422 Unprocessable Entity.
+func (h *VoucherHandler) GenerateBatch(c *gin.Context) {
+	req, err := bindBatchRequest(c)
+	if errors.Is(err, services.ErrInvalidBatch) {
+		respondServiceError(c, err, "Failed to generate vouchers")
+		return
+	}
+	if err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if mode := c.Query("mode"); mode != "" {
+		req.Mode = mode
+	}
+	for i := range req.Items {
+		actingCrew(c, &req.Items[i].ID, &req.Items[i].Name)
+	}
+
+	results, err := h.serviceFor(c).GenerateBatch(req.Items, req.Mode)
+	if err != nil {
+		respondServiceError(c, err, "Failed to generate vouchers")
+		return
+	}
+
+	response := models.BatchGenerateResponse{
+		Success: true,
+		Mode:    req.Mode,
+		Results: make([]models.BatchItemResult, len(results)),
+	}
+	if response.Mode == "" {
+		response.Mode = models.BatchModePerItem
+	}
+
+	for i, result := range results {
+		item := models.BatchItemResult{
+			Index:        i,
+			FlightNumber: req.Items[i].FlightNumber,
+			Date:         req.Items[i].Date,
+			Status:       result.Status,
+		}
+		switch result.Status {
+		case models.BatchStatusCreated:
+			item.Seats = result.Voucher.Seats
+			response.Created++
+		case models.BatchStatusExists:
+			response.Existing++
+		case models.BatchStatusInvalid:
+			response.Invalid++
+		case models.BatchStatusSkipped:
+			response.Success = false
+		}
+		if result.Err != nil {
+			item.Code = serviceErrorCode(result.Err)
+			item.Message = result.Err.Error()
+		}
+		response.Results[i] = item
+	}
+
+	status := http.StatusOK
+	if !response.Success {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, response)
+}
+
+// bindBatchRequest reads the batch items in whichever format the request
+// uses, reading no more than maxBatchBodySize bytes
+func bindBatchRequest(c *gin.Context) (*models.BatchGenerateRequest, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchBodySize)
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+
+	switch mediaType {
+	case "multipart/form-data":
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("CSV upload must be in the \"file\" field: %w", err)
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		items, err := parseBatchCSV(file, requiredBatchColumns(principalFrom(c)))
+		if err != nil {
+			return nil, err
+		}
+		return &models.BatchGenerateRequest{Mode: c.PostForm("mode"), Items: items}, nil
+
+	case "text/csv":
+		items, err := parseBatchCSV(c.Request.Body, requiredBatchColumns(principalFrom(c)))
+		if err != nil {
+			return nil, err
+		}
+		return &models.BatchGenerateRequest{Items: items}, nil
+
+	default:
+		var req models.BatchGenerateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return nil, err
+		}
+		return &req, nil
+	}
+}
+
+// parseBatchCSV reads generate requests from a CSV file whose first row
+// names the columns, e.g. name,id,flightNumber,date,aircraft,cabinClass,seatCount,
+// and must include the required ones. Reading stops with an error wrapping
+// services.ErrInvalidBatch at the first row past services.MaxBatchSize.
+func parseBatchCSV(r io.Reader, required []string) ([]models.GenerateVoucherRequest, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("CSV upload is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	columns := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		column := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := batchColumns[column]; !ok {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		columns[i] = column
+		seen[column] = true
+	}
+	for _, column := range required {
+		if !seen[strings.ToLower(column)] {
+			return nil, fmt.Errorf("CSV header is missing the %s column", column)
+		}
+	}
+
+	var items []models.GenerateVoucherRequest
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if len(items) == services.MaxBatchSize {
+			return nil, fmt.Errorf("%w: more than %d rows (at most %d)", services.ErrInvalidBatch, services.MaxBatchSize, services.MaxBatchSize)
+		}
+
+		var item models.GenerateVoucherRequest
+		for i, value := range record {
+			if err := batchColumns[columns[i]](&item, strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("CSV row %d: %w", len(items)+2, err)
+			}
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchCSV schedules a new flight, the flight newResourceTestRouter already
+// generated and a flight with an unknown aircraft
+const batchCSV = `flightNumber,date,aircraft,name,id,seatCount
+GA200,2025-07-12,Airbus 320,Sarah,98123,2
+GA102,2025-07-12,Airbus 320,Sarah,98123,
+GA201,2025-07-12,Concorde,Sarah,98123,
+`
+
+func decodeBatchResponse(t *testing.T, w *httptest.ResponseRecorder) models.BatchGenerateResponse {
+	var response models.BatchGenerateResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func serveCSV(router *gin.Engine, path, contentType string, body *bytes.Buffer) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, path, body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestVoucherHandler_GenerateBatch_JSON(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	item := models.GenerateVoucherRequest{Name: "Sarah", ID: "98123", FlightNumber: "GA200", Date: "2025-07-12", Aircraft: "Airbus 320"}
+	existing := item
+	existing.FlightNumber = "GA102"
+	missing := item
+	missing.Name = ""
+
+	w := serve(router, http.MethodPost, "/api/generate/batch", models.BatchGenerateRequest{
+		Items: []models.GenerateVoucherRequest{item, existing, missing},
+	}, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	response := decodeBatchResponse(t, w)
+	assert.True(t, response.Success)
+	assert.Equal(t, models.BatchModePerItem, response.Mode)
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, 1, response.Existing)
+	assert.Equal(t, 1, response.Invalid)
+
+	require.Len(t, response.Results, 3)
+	assert.Equal(t, models.BatchStatusCreated, response.Results[0].Status)
+	assert.Len(t, response.Results[0].Seats, 3)
+	assert.Equal(t, models.CodeVoucherExists, response.Results[1].Code)
+	assert.Equal(t, "GA102", response.Results[1].FlightNumber)
+	assert.Equal(t, models.CodeMissingFields, response.Results[2].Code)
+}
+
+func TestVoucherHandler_GenerateBatch_CSV(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	w := serveCSV(router, "/api/generate/batch", "text/csv", bytes.NewBufferString(batchCSV))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	response := decodeBatchResponse(t, w)
+	assert.Equal(t, []string{models.BatchStatusCreated, models.BatchStatusExists, models.BatchStatusInvalid},
+		[]string{response.Results[0].Status, response.Results[1].Status, response.Results[2].Status})
+	assert.Len(t, response.Results[0].Seats, 2)
+	assert.Equal(t, models.CodeInvalidAircraft, response.Results[2].Code)
+}
+
+func TestVoucherHandler_GenerateBatch_SeatPreferences(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	csv := `flightNumber,date,aircraft,name,id,seatPreference,seatMix
+GA200,2025-07-12,ATR,Sarah,98123,window,
+GA201,2025-07-12,ATR,Sarah,98123,,window:1;aisle:2
+GA202,2025-07-12,ATR,Sarah,98123,middle,
+`
+	w := serveCSV(router, "/api/generate/batch", "text/csv", bytes.NewBufferString(csv))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	response := decodeBatchResponse(t, w)
+	assert.Equal(t, 2, response.Created)
+	for _, seat := range response.Results[0].Seats {
+		assert.Regexp(t, `^\d+[AF]$`, seat, "ATR window seats are A and F")
+	}
+	assert.Regexp(t, `^\d+[CD]$`, response.Results[1].Seats[1])
+	assert.Regexp(t, `^\d+[CD]$`, response.Results[1].Seats[2])
+	assert.Equal(t, models.CodeSeatPreferenceUnsatisfiable, response.Results[2].Code, "the ATR has no middle seats")
+}
+
+func TestVoucherHandler_GenerateBatch_AtomicUpload(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	require.NoError(t, form.WriteField("mode", models.BatchModeAtomic))
+	file, err := form.CreateFormFile("file", "schedule.csv")
+	require.NoError(t, err)
+	_, err = file.Write([]byte(batchCSV))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	w := serveCSV(router, "/api/generate/batch", form.FormDataContentType(), body)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	response := decodeBatchResponse(t, w)
+	assert.False(t, response.Success)
+	assert.Equal(t, models.BatchModeAtomic, response.Mode)
+	assert.Zero(t, response.Created)
+	assert.Equal(t, models.BatchStatusSkipped, response.Results[0].Status)
+
+	// Nothing was created
+	w = serve(router, http.MethodGet, "/api/vouchers/GA200/2025-07-12", nil, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestVoucherHandler_GenerateBatch_Errors(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	const header = "flightNumber,date,aircraft,name,id\n"
+	tooManyRows := header + strings.Repeat("GA200,2025-07-12,ATR,Sarah,98123\n", services.MaxBatchSize+1)
+	tooLarge := header + "GA200,2025-07-12,ATR," + strings.Repeat("S", maxBatchBodySize) + ",98123\n"
+
+	tests := []struct {
+		name         string
+		path         string
+		contentType  string
+		body         string
+		expectedCode string
+	}{
+		{"unknown column", "/api/generate/batch", "text/csv", "flightNumber,date,tail\n", models.CodeInvalidRequest},
+		{"missing column", "/api/generate/batch", "text/csv", "flightNumber,date\n", models.CodeInvalidRequest},
+		{"bad seat count", "/api/generate/batch", "text/csv", strings.Replace(batchCSV, ",2\n", ",two\n", 1), models.CodeInvalidRequest},
+		{"bad seat mix", "/api/generate/batch", "text/csv", "flightNumber,date,aircraft,name,id,seatMix\nGA200,2025-07-12,ATR,Sarah,98123,window=1\n", models.CodeInvalidRequest},
+		{"no items", "/api/generate/batch", "application/json", `{"items": []}`, models.CodeInvalidBatch},
+		{"unknown mode", "/api/generate/batch?mode=some", "text/csv", batchCSV, models.CodeInvalidBatch},
+		{"too many rows", "/api/generate/batch", "text/csv", tooManyRows, models.CodeInvalidBatch},
+		{"body too large", "/api/generate/batch", "text/csv", tooLarge, models.CodeInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCSV(router, tt.path, tt.contentType, bytes.NewBufferString(tt.body))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response models.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedCode, response.Code)
+		})
+	}
+}
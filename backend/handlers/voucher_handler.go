@@ -1,8 +1,9 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"airline-voucher-backend/models"
 	"airline-voucher-backend/services"
@@ -13,6 +14,8 @@ import (
 // VoucherHandler handles voucher-related HTTP requests
 type VoucherHandler struct {
 	service *services.VoucherService
+	// exportWriteTimeout is how long an export may go without writing
+	exportWriteTimeout time.Duration
 }
 
 // NewVoucherHandler creates a new VoucherHandler instance
@@ -22,33 +25,32 @@ func NewVoucherHandler(service *services.VoucherService) *VoucherHandler {
 	}
 }
 
+// SetExportWriteTimeout sets how long a voucher export may go without
+// writing before the connection times out, normally the server's write
+// timeout. Without it, an export is bound by the server's write timeout as a
+// whole, which cuts long downloads short. Zero leaves the deadline alone.
+func (h *VoucherHandler) SetExportWriteTimeout(timeout time.Duration) {
+	h.exportWriteTimeout = timeout
+}
+
 // CheckVoucher handles POST /api/check requests
 func (h *VoucherHandler) CheckVoucher(c *gin.Context) {
 	var req models.CheckVoucherRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request body",
-			Message: err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
 	// Validate required fields
 	if req.FlightNumber == "" || req.Date == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Missing required fields",
-			Message: "Both flightNumber and date are required",
-		})
+		respondError(c, http.StatusBadRequest, models.CodeMissingFields, "Missing required fields", "Both flightNumber and date are required")
 		return
 	}
 
 	exists, err := h.service.CheckVoucherExists(req.FlightNumber, req.Date)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Failed to check voucher",
-			Message: err.Error(),
-		})
+		respondServiceError(c, err, "Failed to check voucher")
 		return
 	}
 
@@ -57,60 +59,40 @@ func (h *VoucherHandler) CheckVoucher(c *gin.Context) {
 	})
 }
 
-// GenerateVoucher handles POST /api/generate requests
+// GenerateVoucher handles POST /api/generate requests. With an
+// Idempotency-Key header, a retry of the same request returns the original
+// response, marked with an Idempotent-Replayed: true header.
 func (h *VoucherHandler) GenerateVoucher(c *gin.Context) {
 	var req models.GenerateVoucherRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request body",
-			Message: err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
+	actingCrew(c, &req.ID, &req.Name)
 
 	// Validate required fields
 	if req.Name == "" || req.ID == "" || req.FlightNumber == "" || req.Date == "" || req.Aircraft == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Missing required fields",
-			Message: "All fields (name, id, flightNumber, date, aircraft) are required",
-		})
+		respondError(c, http.StatusBadRequest, models.CodeMissingFields, "Missing required fields", "All fields (name, id, flightNumber, date, aircraft) are required")
 		return
 	}
 
-	response, err := h.service.GenerateVoucher(&req)
-	if err != nil {
-		// Check if it's a business logic error (voucher already exists)
-		if err.Error() == "voucher already exists for flight "+req.FlightNumber+" on "+req.Date {
-			c.JSON(http.StatusConflict, models.ErrorResponse{
-				Error:   "Voucher already exists",
-				Message: err.Error(),
-			})
+	if key, ok := c.Request.Header["Idempotency-Key"]; ok {
+		response, replayed, err := h.serviceFor(c).GenerateVoucherIdempotent(strings.TrimSpace(strings.Join(key, ",")), &req)
+		if err != nil {
+			respondServiceError(c, err, "Failed to generate voucher")
 			return
 		}
-
-		// Check if it's a validation error
-		if err.Error() == "invalid aircraft type: "+req.Aircraft {
-			c.JSON(http.StatusBadRequest, models.ErrorResponse{
-				Error:   "Invalid aircraft type",
-				Message: err.Error(),
-			})
-			return
-		}
-
-		if err.Error() == "invalid date format: "+req.Date+" (expected YYYY-MM-DD)" {
-			c.JSON(http.StatusBadRequest, models.ErrorResponse{
-				Error:   "Invalid date format",
-				Message: err.Error(),
-			})
-			return
+		if replayed {
+			c.Header("Idempotent-Replayed", "true")
 		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
 
-		// Internal server error
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Failed to generate voucher",
-			Message: err.Error(),
-		})
+	response, err := h.serviceFor(c).GenerateVoucher(&req)
+	if err != nil {
+		respondServiceError(c, err, "Failed to generate voucher")
 		return
 	}
 
@@ -122,28 +104,19 @@ func (h *VoucherHandler) GetVoucher(c *gin.Context) {
 	var req models.GetVoucherRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request body",
-			Message: err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
 	// Validate required fields
 	if req.FlightNumber == "" || req.Date == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Missing required fields",
-			Message: "Both flightNumber and date are required",
-		})
+		respondError(c, http.StatusBadRequest, models.CodeMissingFields, "Missing required fields", "Both flightNumber and date are required")
 		return
 	}
 
 	voucher, err := h.service.GetVoucher(req.FlightNumber, req.Date)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Failed to get voucher",
-			Message: err.Error(),
-		})
+		respondServiceError(c, err, "Failed to get voucher")
 		return
 	}
 
@@ -160,55 +133,26 @@ func (h *VoucherHandler) RegenerateSeat(c *gin.Context) {
 	var req models.RegenerateSeatRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid request body",
-			Message: err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
 	// Validate required fields
 	if req.FlightNumber == "" || req.Date == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Missing required fields",
-			Message: "FlightNumber, date, and seatPosition are required",
-		})
+		respondError(c, http.StatusBadRequest, models.CodeMissingFields, "Missing required fields", "FlightNumber, date, and seatPosition are required")
 		return
 	}
 
 	// Validate seat position
-	if req.SeatPosition < 1 || req.SeatPosition > 3 {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid seat position",
-			Message: "Seat position must be 1, 2, or 3",
-		})
+	if req.SeatPosition < 1 {
+		respondError(c, http.StatusBadRequest, models.CodeInvalidSeatPosition, "Invalid seat position", "Seat position must be at least 1")
 		return
 	}
 
-	response, err := h.service.RegenerateSeat(&req)
+	actingCrew(c, &req.CrewID, nil)
+	response, err := h.serviceFor(c).RegenerateSeat(&req)
 	if err != nil {
-		// Check if it's a business logic error (voucher not found)
-		if err.Error() == "no voucher found for flight "+req.FlightNumber+" on "+req.Date {
-			c.JSON(http.StatusNotFound, models.ErrorResponse{
-				Error:   "Voucher not found",
-				Message: err.Error(),
-			})
-			return
-		}
-
-		// Check if it's a validation error
-		if err.Error() == fmt.Sprintf("invalid seat position: %d (must be 1, 2, or 3)", req.SeatPosition) {
-			c.JSON(http.StatusBadRequest, models.ErrorResponse{
-				Error:   "Invalid seat position",
-				Message: err.Error(),
-			})
-			return
-		}
-
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Failed to regenerate seat",
-			Message: err.Error(),
-		})
+		respondServiceError(c, err, "Failed to regenerate seat")
 		return
 	}
 
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 
+	"airline-voucher-backend/config"
 	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
 	"airline-voucher-backend/services"
 
 	"github.com/gin-gonic/gin"
@@ -18,11 +21,43 @@ import (
 func setupTestRouter(handler *VoucherHandler) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(RequestID())
 
+	// Mirrors the routes of main.go; without authentication the role groups
+	// let every request through
 	api := router.Group("/api")
+	crew := api.Group("", RequireRole(models.RoleCrew))
 	{
-		api.POST("/check", handler.CheckVoucher)
-		api.POST("/generate", handler.GenerateVoucher)
+		crew.POST("/check", handler.CheckVoucher)
+		crew.POST("/generate", handler.GenerateVoucher)
+		crew.POST("/generate/batch", handler.GenerateBatch)
+		crew.POST("/voucher", handler.GetVoucher)
+
+		crew.GET("/vouchers", handler.ListVouchers)
+		crew.GET("/vouchers/:id", handler.GetVoucherResource)
+		crew.GET("/vouchers/:id/:date", handler.GetVoucherResource)
+		crew.GET("/vouchers/:id/history", handler.VoucherHistory)
+		crew.GET("/vouchers/:id/:date/history", handler.VoucherHistory)
+		crew.POST("/vouchers/:id/redeem", handler.RedeemSeatResource)
+		crew.POST("/vouchers/:id/:date/redeem", handler.RedeemSeatResource)
+		crew.GET("/occupancy/:flightNumber/:date", handler.GetOccupancy)
+	}
+	supervisor := api.Group("", RequireRole(models.RoleSupervisor))
+	{
+		supervisor.POST("/regenerate-seat", handler.RegenerateSeat)
+		supervisor.PATCH("/vouchers/:id", handler.PatchVoucherResource)
+		supervisor.PATCH("/vouchers/:id/:date", handler.PatchVoucherResource)
+		supervisor.POST("/vouchers/:id/void", handler.VoidSeatResource)
+		supervisor.POST("/vouchers/:id/:date/void", handler.VoidSeatResource)
+		supervisor.PUT("/occupancy/:flightNumber/:date", handler.SetOccupancy)
+		supervisor.DELETE("/occupancy/:flightNumber/:date", handler.DeleteOccupancy)
+	}
+	admin := api.Group("", RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/vouchers/export", handler.ExportVouchers)
+		admin.DELETE("/vouchers/:id", handler.DeleteVoucherResource)
+		admin.DELETE("/vouchers/:id/:date", handler.DeleteVoucherResource)
+		admin.PUT("/aircraft-layouts", handler.ReplaceAircraftLayouts)
 	}
 
 	router.GET("/health", handler.HealthCheck)
@@ -153,3 +188,72 @@ func TestVoucherHandler_HealthCheck(t *testing.T) {
 	assert.Equal(t, "healthy", response["status"])
 	assert.Contains(t, response["message"], "running")
 }
+
+func TestVoucherHandler_GenerateVoucher_Conflict(t *testing.T) {
+	db, err := config.InitDB(filepath.Join(t.TempDir(), "vouchers.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	handler := NewVoucherHandler(services.NewVoucherService(repository.NewSQLiteVoucherRepository(db)))
+	router := setupTestRouter(handler)
+
+	body, err := json.Marshal(models.GenerateVoucherRequest{
+		Name:         "Sarah",
+		ID:           "98123",
+		FlightNumber: "GA102",
+		Date:         "2025-07-12",
+		Aircraft:     "ATR",
+	})
+	require.NoError(t, err)
+
+	expected := []int{http.StatusOK, http.StatusConflict}
+	for _, status := range expected {
+		req, err := http.NewRequest("POST", "/api/generate", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, status, w.Code)
+
+		if status == http.StatusConflict {
+			var response models.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, models.CodeVoucherExists, response.Code)
+		}
+	}
+}
+
+func TestVoucherHandler_GenerateVoucher_IdempotencyKey(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	request := models.GenerateVoucherRequest{
+		Name:         "Sarah",
+		ID:           "98123",
+		FlightNumber: "GA200",
+		Date:         "2025-07-12",
+		Aircraft:     "ATR",
+	}
+	headers := map[string]string{"Idempotency-Key": "tablet-7-0001"}
+
+	first := serve(router, http.MethodPost, "/api/generate", request, headers)
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	retry := serve(router, http.MethodPost, "/api/generate", request, headers)
+	require.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+
+	request.FlightNumber = "GA201"
+	reused := serve(router, http.MethodPost, "/api/generate", request, headers)
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+
+	var response models.ErrorResponse
+	require.NoError(t, json.Unmarshal(reused.Body.Bytes(), &response))
+	assert.Equal(t, models.CodeIdempotencyKeyReused, response.Code)
+
+	empty := serve(router, http.MethodPost, "/api/generate", request, map[string]string{"Idempotency-Key": " "})
+	assert.Equal(t, http.StatusBadRequest, empty.Code)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VoucherHistory handles GET /api/vouchers/{id}/history and
+// GET /api/vouchers/{flightNumber}/{date}/history requests. By ID, the
+// history stays available after the voucher is deleted.
+func (h *VoucherHandler) VoucherHistory(c *gin.Context) {
+	var id int
+	if c.Param("date") != "" {
+		voucher, ok := h.voucherFromPath(c)
+		if !ok {
+			return
+		}
+		id = voucher.ID
+	} else {
+		var ok bool
+		if id, ok = voucherIDFromPath(c); !ok {
+			return
+		}
+	}
+
+	response, err := h.service.VoucherHistory(id)
+	if err != nil {
+		respondServiceError(c, err, "Failed to get voucher history")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
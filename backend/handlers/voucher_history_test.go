@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"airline-voucher-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoucherHistory(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	w := serve(router, "PATCH", "/api/vouchers/1", map[string]interface{}{"seatPosition": 2, "crewId": "77001", "reason": "accessibility"},
+		map[string]string{RequestIDHeader: "req-42"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "req-42", w.Header().Get(RequestIDHeader))
+
+	w = serve(router, "GET", "/api/vouchers/GA102/2025-07-12/history", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotEmpty(t, w.Header().Get(RequestIDHeader), "a request ID is generated when none is sent")
+
+	var history models.VoucherHistoryResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	assert.Equal(t, 1, history.VoucherID)
+	require.Len(t, history.Events, 2)
+	assert.Equal(t, models.EventCreate, history.Events[0].Type)
+	assert.Equal(t, models.EventRegenerate, history.Events[1].Type)
+	assert.Equal(t, "77001", history.Events[1].ActorID)
+	assert.Equal(t, "req-42", history.Events[1].RequestID)
+	assert.Equal(t, models.ReasonAccessibility, history.Events[1].Reason)
+
+	// The history stays available by ID after the voucher is deleted
+	w = serve(router, "DELETE", "/api/vouchers/1", nil, nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = serve(router, "GET", "/api/vouchers/1/history", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Len(t, history.Events, 3)
+	assert.Equal(t, models.EventDelete, history.Events[2].Type)
+}
+
+func TestVoucherHistory_Errors(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"Unknown voucher ID", "/api/vouchers/99/history", http.StatusNotFound, models.CodeVoucherNotFound},
+		{"Invalid voucher ID", "/api/vouchers/GA102/history", http.StatusBadRequest, models.CodeInvalidVoucherID},
+		{"Unknown flight", "/api/vouchers/GA999/2025-07-12/history", http.StatusNotFound, models.CodeVoucherNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, "GET", tt.path, nil, nil)
+			assert.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+
+			var response models.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedErr, response.Code)
+		})
+	}
+}
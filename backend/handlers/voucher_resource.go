@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"airline-voucher-backend/export"
+	"airline-voucher-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListVouchers handles GET /api/vouchers requests with optional filters,
+// sorting and cursor pagination in the query string
+func (h *VoucherHandler) ListVouchers(c *gin.Context) {
+	var req models.ListVouchersRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		respondError(c, http.StatusBadRequest, models.CodeInvalidQuery, "Invalid query", err.Error())
+		return
+	}
+
+	response, err := h.service.ListVouchers(&req)
+	if err != nil {
+		respondServiceError(c, err, "Failed to list vouchers")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ExportVouchers handles GET /api/vouchers/export requests. It accepts the
+// filters and sort of ListVouchers plus format=csv|xlsx and streams one row
+// per seat as a file download.
+func (h *VoucherHandler) ExportVouchers(c *gin.Context) {
+	var req models.ListVouchersRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		respondError(c, http.StatusBadRequest, models.CodeInvalidQuery, "Invalid query", err.Error())
+		return
+	}
+
+	format := c.DefaultQuery("format", export.FormatCSV)
+	if !export.ValidFormat(format) {
+		respondError(c, http.StatusBadRequest, models.CodeInvalidQuery, "Invalid query",
+			fmt.Sprintf("Unsupported export format %q (use %s or %s)", format, export.FormatCSV, export.FormatXLSX))
+		return
+	}
+
+	// The writer is created with the first voucher, so validation errors can
+	// still be reported as JSON before the download starts
+	var writer export.Writer
+	start := func() error {
+		if writer != nil {
+			return nil
+		}
+		filename := fmt.Sprintf("vouchers-%s.%s", time.Now().Format("20060102-150405"), format)
+		c.Header("Content-Type", export.ContentType(format))
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Status(http.StatusOK)
+
+		var err error
+		writer, err = export.NewWriter(format, c.Writer)
+		return err
+	}
+
+	err := h.serviceFor(c).ExportVouchers(&req, func(voucher *models.Voucher) error {
+		if err := start(); err != nil {
+			return err
+		}
+		h.extendWriteDeadline(c)
+		return writer.Write(voucher)
+	})
+	if err == nil {
+		err = start()
+	}
+	if err == nil {
+		h.extendWriteDeadline(c)
+		err = writer.Close()
+	}
+	if err != nil {
+		if writer == nil {
+			respondServiceError(c, err, "Failed to export vouchers")
+			return
+		}
+		// The download has started, so the status can no longer change;
+		// abort so the client sees a truncated transfer
+		log.Printf("Voucher export failed: %v", err)
+		c.Abort()
+	}
+}
+
+// extendWriteDeadline gives a streaming response another export write
+// timeout from now, so a download keeps going for as long as it makes
+// progress while a client that stops reading still times out
+func (h *VoucherHandler) extendWriteDeadline(c *gin.Context) {
+	if h.exportWriteTimeout <= 0 {
+		return
+	}
+	err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(h.exportWriteTimeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("Failed to extend the export write deadline: %v", err)
+	}
+}
+
+// GetVoucherResource handles GET /api/vouchers/{id} and
+// GET /api/vouchers/{flightNumber}/{date} requests. The response carries an
+// ETag, and a matching If-None-Match header returns 304 Not Modified.
+func (h *VoucherHandler) GetVoucherResource(c *gin.Context) {
+	voucher, ok := h.voucherFromPath(c)
+	if !ok {
+		return
+	}
+
+	etag := voucherETag(voucher)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+
+	if match := c.GetHeader("If-None-Match"); match != "" && etagMatches(match, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.GetVoucherResponse{
+		Voucher: voucher,
+		Exists:  true,
+	})
+}
+
+// PatchVoucherResource handles PATCH /api/vouchers/{id} and
+// PATCH /api/vouchers/{flightNumber}/{date} requests, which regenerate one
+// seat. An If-Match header guards against overwriting a newer version.
+func (h *VoucherHandler) PatchVoucherResource(c *gin.Context) {
+	voucher, ok := h.voucherFromPath(c)
+	if !ok {
+		return
+	}
+	version, ok := checkIfMatch(c, voucher)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateVoucherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	actingCrew(c, &req.CrewID, nil)
+
+	_, err := h.serviceFor(c).RegenerateSeat(&models.RegenerateSeatRequest{
+		FlightNumber: voucher.FlightNumber,
+		Date:         voucher.FlightDate,
+		SeatPosition: req.SeatPosition,
+		CrewID:       req.CrewID,
+		Reason:       req.Reason,
+		Version:      version,
+	})
+	if err != nil {
+		respondServiceError(c, err, "Failed to regenerate seat")
+		return
+	}
+
+	updated, err := h.service.GetVoucherByID(voucher.ID)
+	if err != nil {
+		respondServiceError(c, err, "Failed to get voucher")
+		return
+	}
+
+	c.Header("ETag", voucherETag(updated))
+	c.JSON(http.StatusOK, models.GetVoucherResponse{
+		Voucher: updated,
+		Exists:  true,
+	})
+}
+
+// DeleteVoucherResource handles DELETE /api/vouchers/{id} and
+// DELETE /api/vouchers/{flightNumber}/{date} requests. An If-Match header
+// guards against deleting a newer version.
+func (h *VoucherHandler) DeleteVoucherResource(c *gin.Context) {
+	voucher, ok := h.voucherFromPath(c)
+	if !ok {
+		return
+	}
+	version, ok := checkIfMatch(c, voucher)
+	if !ok {
+		return
+	}
+
+	if err := h.serviceFor(c).DeleteVoucher(voucher.ID, version); err != nil {
+		respondServiceError(c, err, "Failed to delete voucher")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// voucherFromPath loads the voucher addressed by /api/vouchers/:id or
+// /api/vouchers/:id/:date and writes an error response when it cannot.
+// Gin requires both routes to share the wildcard name, so on the two-segment
+// route :id holds the flight number.
+func (h *VoucherHandler) voucherFromPath(c *gin.Context) (*models.Voucher, bool) {
+	if date := c.Param("date"); date != "" {
+		flightNumber := c.Param("id")
+		voucher, err := h.service.GetVoucher(flightNumber, date)
+		if err != nil {
+			respondServiceError(c, err, "Failed to get voucher")
+			return nil, false
+		}
+		if voucher == nil {
+			respondError(c, http.StatusNotFound, models.CodeVoucherNotFound, "Voucher not found",
+				fmt.Sprintf("No voucher for flight %s on %s", flightNumber, date))
+			return nil, false
+		}
+		return voucher, true
+	}
+
+	id, ok := voucherIDFromPath(c)
+	if !ok {
+		return nil, false
+	}
+
+	voucher, err := h.service.GetVoucherByID(id)
+	if err != nil {
+		respondServiceError(c, err, "Failed to get voucher")
+		return nil, false
+	}
+	return voucher, true
+}
+
+// voucherIDFromPath parses the voucher ID of /api/vouchers/:id and writes a
+// 400 response when it is not a positive number
+func voucherIDFromPath(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 1 {
+		respondError(c, http.StatusBadRequest, models.CodeInvalidVoucherID, "Invalid voucher ID",
+			fmt.Sprintf("Voucher ID must be a positive number, got %q", c.Param("id")))
+		return 0, false
+	}
+	return id, true
+}
+
+// checkIfMatch enforces an If-Match precondition against the voucher's
+// current ETag and writes a 412 response when it fails. Otherwise it returns
+// the version the write must still find, or zero when any version will do;
+// the service compares it inside the write, so a change that lands after
+// this check fails the same way.
+func checkIfMatch(c *gin.Context, voucher *models.Voucher) (int, bool) {
+	match := c.GetHeader("If-Match")
+	if match == "" || strings.TrimSpace(match) == "*" {
+		return 0, true
+	}
+	if etagMatches(match, voucherETag(voucher)) {
+		return voucher.Version, true
+	}
+
+	respondError(c, http.StatusPreconditionFailed, models.CodePreconditionFailed, "Voucher has changed",
+		"If-Match does not match the current version of the voucher; fetch it again and retry")
+	return 0, false
+}
+
+// voucherETag returns a strong entity tag derived from the voucher's content
+func voucherETag(voucher *models.Voucher) string {
+	data, _ := json.Marshal(voucher)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header value
+// lists the given entity tag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
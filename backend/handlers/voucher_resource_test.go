@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"airline-voucher-backend/export"
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
+	"airline-voucher-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newResourceTestRouter returns a router backed by an in-memory repository
+// holding one generated voucher for GA102 on 2025-07-12
+func newResourceTestRouter(t *testing.T) *gin.Engine {
+	service := services.NewVoucherService(repository.NewMemoryVoucherRepository())
+	_, err := service.GenerateVoucher(&models.GenerateVoucherRequest{
+		Name:         "Sarah",
+		ID:           "98123",
+		FlightNumber: "GA102",
+		Date:         "2025-07-12",
+		Aircraft:     "Airbus 320",
+	})
+	require.NoError(t, err)
+
+	return setupTestRouter(NewVoucherHandler(service))
+}
+
+func serve(router *gin.Engine, method, path string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	var reader *bytes.Buffer
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewBuffer(data)
+	} else {
+		reader = &bytes.Buffer{}
+	}
+
+	req, _ := http.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestVoucherResource_Get(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	byFlight := serve(router, "GET", "/api/vouchers/GA102/2025-07-12", nil, nil)
+	require.Equal(t, http.StatusOK, byFlight.Code)
+
+	var response models.GetVoucherResponse
+	require.NoError(t, json.Unmarshal(byFlight.Body.Bytes(), &response))
+	require.NotNil(t, response.Voucher)
+	assert.True(t, response.Exists)
+	assert.Equal(t, "GA102", response.Voucher.FlightNumber)
+	assert.Len(t, response.Voucher.Seats, 3)
+	assert.NotEmpty(t, byFlight.Header().Get("ETag"))
+
+	byID := serve(router, "GET", "/api/vouchers/1", nil, nil)
+	require.Equal(t, http.StatusOK, byID.Code)
+	assert.Equal(t, byFlight.Body.String(), byID.Body.String())
+	assert.Equal(t, byFlight.Header().Get("ETag"), byID.Header().Get("ETag"))
+}
+
+func TestVoucherResource_GetErrors(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"/api/vouchers/GA102/2025-07-13", http.StatusNotFound, models.CodeVoucherNotFound},
+		{"/api/vouchers/99", http.StatusNotFound, models.CodeVoucherNotFound},
+		{"/api/vouchers/GA102", http.StatusBadRequest, models.CodeInvalidVoucherID},
+		{"/api/vouchers/0", http.StatusBadRequest, models.CodeInvalidVoucherID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := serve(router, "GET", tt.path, nil, nil)
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			var response models.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.wantCode, response.Code)
+		})
+	}
+}
+
+func TestVoucherResource_IfNoneMatch(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	first := serve(router, "GET", "/api/vouchers/1", nil, nil)
+	etag := first.Header().Get("ETag")
+
+	notModified := serve(router, "GET", "/api/vouchers/1", nil, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Empty(t, notModified.Body.String())
+
+	stale := serve(router, "GET", "/api/vouchers/1", nil, map[string]string{"If-None-Match": `"stale"`})
+	assert.Equal(t, http.StatusOK, stale.Code)
+}
+
+func TestVoucherResource_Patch(t *testing.T) {
+	router := newResourceTestRouter(t)
+	etag := serve(router, "GET", "/api/vouchers/1", nil, nil).Header().Get("ETag")
+
+	// A stale precondition is rejected without changing the voucher
+	stale := serve(router, "PATCH", "/api/vouchers/GA102/2025-07-12",
+		models.UpdateVoucherRequest{SeatPosition: 1, CrewID: "98123", Reason: models.ReasonPassengerRequest}, map[string]string{"If-Match": `"stale"`})
+	assert.Equal(t, http.StatusPreconditionFailed, stale.Code)
+	assert.Equal(t, etag, serve(router, "GET", "/api/vouchers/1", nil, nil).Header().Get("ETag"))
+
+	w := serve(router, "PATCH", "/api/vouchers/1",
+		models.UpdateVoucherRequest{SeatPosition: 2, CrewID: "98123", Reason: models.ReasonPassengerRequest}, map[string]string{"If-Match": etag})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response models.GetVoucherResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Voucher.Seats, 3)
+	assert.Equal(t, w.Header().Get("ETag"), serve(router, "GET", "/api/vouchers/1", nil, nil).Header().Get("ETag"))
+
+	invalid := serve(router, "PATCH", "/api/vouchers/1", models.UpdateVoucherRequest{SeatPosition: 4, CrewID: "98123", Reason: models.ReasonPassengerRequest}, nil)
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+
+	// A redraw must say who asked for it and why
+	unjustified := serve(router, "PATCH", "/api/vouchers/1", models.UpdateVoucherRequest{SeatPosition: 1}, nil)
+	assert.Equal(t, http.StatusBadRequest, unjustified.Code)
+	unknownReason := serve(router, "PATCH", "/api/vouchers/1", models.UpdateVoucherRequest{SeatPosition: 1, CrewID: "98123", Reason: "friend"}, nil)
+	assert.Equal(t, http.StatusBadRequest, unknownReason.Code)
+	assert.Contains(t, unknownReason.Body.String(), models.CodeInvalidReason)
+}
+
+func TestVoucherResource_Delete(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	stale := serve(router, "DELETE", "/api/vouchers/1", nil, map[string]string{"If-Match": `"stale"`})
+	assert.Equal(t, http.StatusPreconditionFailed, stale.Code)
+
+	w := serve(router, "DELETE", "/api/vouchers/GA102/2025-07-12", nil, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/api/vouchers/1", nil, nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "DELETE", "/api/vouchers/1", nil, nil).Code)
+
+	// The legacy POST lookup still works and reports the voucher as gone
+	legacy := serve(router, "POST", "/api/voucher", models.GetVoucherRequest{FlightNumber: "GA102", Date: "2025-07-12"}, nil)
+	require.Equal(t, http.StatusOK, legacy.Code)
+	var response models.GetVoucherResponse
+	require.NoError(t, json.Unmarshal(legacy.Body.Bytes(), &response))
+	assert.False(t, response.Exists)
+}
+
+func TestEtagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`"x", W/"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`*`, `"abc"`))
+	assert.False(t, etagMatches(`"abd"`, `"abc"`))
+}
+
+func TestVoucherResource_List(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	w := serve(router, "GET", "/api/vouchers?dateFrom=2025-07-01&dateTo=2025-07-31&flightNumber=GA&aircraft=Airbus%20320&crewId=98123&sort=-createdAt&limit=10", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ListVouchersResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Total)
+	require.Len(t, response.Vouchers, 1)
+	assert.Equal(t, "GA102", response.Vouchers[0].FlightNumber)
+	assert.Empty(t, response.NextCursor)
+
+	empty := serve(router, "GET", "/api/vouchers?crewId=nobody", nil, nil)
+	require.Equal(t, http.StatusOK, empty.Code)
+	assert.JSONEq(t, `{"vouchers": [], "total": 0}`, empty.Body.String())
+
+	for _, query := range []string{"sort=seat", "limit=abc", "limit=1000", "cursor=bogus"} {
+		t.Run(query, func(t *testing.T) {
+			w := serve(router, "GET", "/api/vouchers?"+query, nil, nil)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var errResponse models.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResponse))
+			assert.Equal(t, models.CodeInvalidQuery, errResponse.Code)
+		})
+	}
+}
+
+func TestVoucherResource_Export(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	w := serve(router, "GET", "/api/vouchers/export?flightNumber=GA", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), ".csv")
+
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 4, "header plus one row per seat")
+	assert.Equal(t, export.Columns, rows[0])
+	for i, row := range rows[1:] {
+		assert.Equal(t, "GA102", row[1])
+		assert.Equal(t, strconv.Itoa(i+1), row[8])
+	}
+
+	xlsx := serve(router, "GET", "/api/vouchers/export?format=xlsx&crewId=nobody", nil, nil)
+	require.Equal(t, http.StatusOK, xlsx.Code)
+	assert.Equal(t, export.ContentType(export.FormatXLSX), xlsx.Header().Get("Content-Type"))
+	assert.NotEmpty(t, xlsx.Body.Bytes(), "an empty export still has a header row")
+
+	for _, query := range []string{"format=pdf", "sort=seat", "dateFrom=yesterday"} {
+		t.Run(query, func(t *testing.T) {
+			w := serve(router, "GET", "/api/vouchers/export?"+query, nil, nil)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+		})
+	}
+}
+
+// slowListRepository takes a while for every batch an export reads
+type slowListRepository struct {
+	*repository.MemoryVoucherRepository
+	delay time.Duration
+}
+
+func (r *slowListRepository) List(opts repository.ListOptions) ([]models.Voucher, error) {
+	time.Sleep(r.delay)
+	return r.MemoryVoucherRepository.List(opts)
+}
+
+func TestVoucherResource_ExportOutlastsWriteTimeout(t *testing.T) {
+	const writeTimeout = 200 * time.Millisecond
+
+	// Enough vouchers for three export batches, each slower to read than
+	// half the write timeout
+	repo := &slowListRepository{MemoryVoucherRepository: repository.NewMemoryVoucherRepository(), delay: 150 * time.Millisecond}
+	vouchers := make([]*models.Voucher, 1001)
+	for i := range vouchers {
+		vouchers[i] = &models.Voucher{
+			CrewName: "Sarah", CrewID: "98123", FlightNumber: fmt.Sprintf("GA%d", i+1), FlightDate: "2025-07-12",
+			AircraftType: "ATR", CabinClass: "economy", CreatedAt: "2025-07-01 10:00:00", Seats: []string{"1A"},
+		}
+	}
+	require.NoError(t, repo.CreateAll(vouchers))
+
+	gin.SetMode(gin.TestMode)
+	handler := NewVoucherHandler(services.NewVoucherService(repo))
+	handler.SetExportWriteTimeout(writeTimeout)
+	router := gin.New()
+	router.GET("/api/vouchers/export", handler.ExportVouchers)
+
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = writeTimeout
+	server.Start()
+	defer server.Close()
+
+	start := time.Now()
+	response, err := http.Get(server.URL + "/api/vouchers/export")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	rows, err := csv.NewReader(response.Body).ReadAll()
+	require.NoError(t, err, "the download must not be cut off")
+	assert.Len(t, rows, 1+len(vouchers))
+	assert.Greater(t, time.Since(start), writeTimeout)
+}
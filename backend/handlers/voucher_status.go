@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"airline-voucher-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RedeemSeatResource handles POST /api/vouchers/{id}/redeem and
+// POST /api/vouchers/{flightNumber}/{date}/redeem requests, which record that
+// an issued seat was given to its passenger
+func (h *VoucherHandler) RedeemSeatResource(c *gin.Context) {
+	var req models.RedeemSeatRequest
+	h.changeSeatStatus(c, &req, func(voucher *models.Voucher, version int) (*models.Voucher, error) {
+		actingCrew(c, &req.RedeemedBy, nil)
+		req.Version = version
+		return h.serviceFor(c).RedeemSeat(voucher.ID, &req)
+	})
+}
+
+// VoidSeatResource handles POST /api/vouchers/{id}/void and
+// POST /api/vouchers/{flightNumber}/{date}/void requests, which withdraw an
+// issued seat
+func (h *VoucherHandler) VoidSeatResource(c *gin.Context) {
+	var req models.VoidSeatRequest
+	h.changeSeatStatus(c, &req, func(voucher *models.Voucher, version int) (*models.Voucher, error) {
+		actingCrew(c, &req.CrewID, nil)
+		req.Version = version
+		return h.serviceFor(c).VoidSeat(voucher.ID, &req)
+	})
+}
+
+// changeSeatStatus loads the voucher addressed by the path, binds the JSON
+// body into req and applies the change, passing the version an If-Match
+// header requires. Like PATCH, the header guards against changing a newer
+// version, and the response carries the new ETag.
+func (h *VoucherHandler) changeSeatStatus(c *gin.Context, req interface{}, change func(voucher *models.Voucher, version int) (*models.Voucher, error)) {
+	voucher, ok := h.voucherFromPath(c)
+	if !ok {
+		return
+	}
+	version, ok := checkIfMatch(c, voucher)
+	if !ok {
+		return
+	}
+
+	if err := c.ShouldBindJSON(req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	updated, err := change(voucher, version)
+	if err != nil {
+		respondServiceError(c, err, "Failed to change seat status")
+		return
+	}
+
+	c.Header("ETag", voucherETag(updated))
+	c.JSON(http.StatusOK, models.GetVoucherResponse{
+		Voucher: updated,
+		Exists:  true,
+	})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"airline-voucher-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoucherStatus_RedeemAndVoid(t *testing.T) {
+	router := newResourceTestRouter(t)
+
+	w := serve(router, "POST", "/api/vouchers/1/redeem", map[string]interface{}{"seatPosition": 1, "redeemedBy": "98123"}, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response models.GetVoucherResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Voucher.SeatStates, 3)
+	assert.Equal(t, models.SeatStatusRedeemed, response.Voucher.SeatStates[0].Status)
+	assert.Equal(t, "98123", response.Voucher.SeatStates[0].RedeemedBy)
+	assert.NotEmpty(t, response.Voucher.SeatStates[0].RedeemedAt)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w = serve(router, "POST", "/api/vouchers/GA102/2025-07-12/void", map[string]interface{}{"seatPosition": 2}, map[string]string{"If-Match": etag})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.SeatStatusVoided, response.Voucher.SeatStates[1].Status)
+
+	w = serve(router, "GET", "/api/vouchers/1", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.SeatStatusRedeemed, response.Voucher.SeatStates[0].Status)
+	assert.Equal(t, models.SeatStatusVoided, response.Voucher.SeatStates[1].Status)
+	assert.Equal(t, models.SeatStatusIssued, response.Voucher.SeatStates[2].Status)
+}
+
+func TestVoucherStatus_Errors(t *testing.T) {
+	router := newResourceTestRouter(t)
+	w := serve(router, "POST", "/api/vouchers/1/void", map[string]interface{}{"seatPosition": 1}, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	tests := []struct {
+		name         string
+		path         string
+		body         interface{}
+		headers      map[string]string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"Voided seat", "/api/vouchers/1/redeem", map[string]interface{}{"seatPosition": 1, "redeemedBy": "98123"}, nil, http.StatusConflict, models.CodeInvalidSeatTransition},
+		{"Missing redeemer", "/api/vouchers/1/redeem", map[string]interface{}{"seatPosition": 2}, nil, http.StatusBadRequest, models.CodeMissingFields},
+		{"Unknown position", "/api/vouchers/1/void", map[string]interface{}{"seatPosition": 4}, nil, http.StatusBadRequest, models.CodeInvalidSeatPosition},
+		{"Unknown voucher", "/api/vouchers/99/void", map[string]interface{}{"seatPosition": 1}, nil, http.StatusNotFound, models.CodeVoucherNotFound},
+		{"Invalid voucher ID", "/api/vouchers/GA102/void", map[string]interface{}{"seatPosition": 1}, nil, http.StatusBadRequest, models.CodeInvalidVoucherID},
+		{"Stale If-Match", "/api/vouchers/1/void", map[string]interface{}{"seatPosition": 2}, map[string]string{"If-Match": `"stale"`}, http.StatusPreconditionFailed, models.CodePreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, "POST", tt.path, tt.body, tt.headers)
+			assert.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+
+			var response models.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedErr, response.Code)
+		})
+	}
+}
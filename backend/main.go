@@ -1,12 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
+	"airline-voucher-backend/auth"
 	"airline-voucher-backend/config"
 	"airline-voucher-backend/handlers"
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
 	"airline-voucher-backend/services"
+	"airline-voucher-backend/utils"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -14,49 +25,215 @@ import (
 
 func main() {
 	// Load configuration
-	cfg := config.NewConfig()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Database maintenance commands
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(cfg, args[1:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// Reporting commands
+	if len(args) > 0 && args[0] == "export" {
+		if err := runExport(cfg, args[1:]); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "token" {
+		if err := runToken(cfg, args[1:]); err != nil {
+			log.Fatalf("Token failed: %v", err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "replay" {
+		if err := runReplay(cfg, args[1:]); err != nil {
+			log.Fatalf("Replay failed: %v", err)
+		}
+		return
+	}
+
+	// Load aircraft seat layouts
+	if cfg.AircraftConfigPath != "" {
+		if err := utils.LoadAircraftConfigs(cfg.AircraftConfigPath); err != nil {
+			log.Fatalf("Failed to load aircraft layouts: %v", err)
+		}
+		log.Printf("Aircraft layouts loaded from %s", cfg.AircraftConfigPath)
+	}
+	if err := cfg.Print(log.Writer()); err != nil {
+		log.Fatalf("Failed to print configuration: %v", err)
+	}
+
+	log.Printf("Aircraft types: %s", strings.Join(utils.AircraftTypes(), ", "))
 
 	// Initialize database
-	db, err := config.InitDB(cfg.DBPath)
+	db, err := config.InitDatabase(cfg.DBDriver, cfg.DataSource())
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
+	log.Printf("Using %s database", cfg.DBDriver)
+
+	// Initialize repositories
+	voucherRepo, err := repository.NewSQLVoucherRepository(cfg.DBDriver, db)
+	if err != nil {
+		log.Fatalf("Failed to initialize repository: %v", err)
+	}
 
 	// Initialize services
-	voucherService := services.NewVoucherService(db)
+	voucherService := services.NewVoucherService(voucherRepo)
+	if err := voucherService.SetDefaultSeatCount(cfg.DefaultSeatCount); err != nil {
+		log.Fatalf("Invalid voucher configuration: %v", err)
+	}
+	if err := voucherService.SetSeatExpiry(cfg.SeatExpiry); err != nil {
+		log.Fatalf("Invalid voucher configuration: %v", err)
+	}
+	if err := voucherService.SetIdempotencyKeyTTL(cfg.IdempotencyKeyTTL); err != nil {
+		log.Fatalf("Invalid voucher configuration: %v", err)
+	}
+	if err := voucherService.SetRegenerationLimits(cfg.MaxVoucherRegenerations, cfg.MaxSeatRegenerations); err != nil {
+		log.Fatalf("Invalid voucher configuration: %v", err)
+	}
 
 	// Initialize handlers
 	voucherHandler := handlers.NewVoucherHandler(voucherService)
+	voucherHandler.SetExportWriteTimeout(cfg.WriteTimeout)
+
+	// Authentication; refusing to start beats silently serving an open API
+	var apiMiddleware []gin.HandlerFunc
+	switch {
+	case cfg.AuthDisabled:
+		log.Printf("WARNING: authentication is disabled; anyone who can reach the API can use it")
+	case !cfg.AuthConfigured():
+		log.Fatalf("Authentication is not configured: set JWT_SECRET or API_KEYS, or AUTH_DISABLED=true for local development")
+	default:
+		authenticator, err := auth.NewAuthenticator(cfg.JWTSecret, cfg.APIKeys, cfg.APIKeyRoles)
+		if err != nil {
+			log.Fatalf("Invalid authentication configuration: %v", err)
+		}
+		apiMiddleware = append(apiMiddleware, handlers.Authenticate(authenticator))
+	}
 
-	// Initialize Gin router
-	router := gin.Default()
+	// Initialize Gin router; GIN_MODE still takes precedence over the log level
+	if os.Getenv(gin.EnvGinMode) == "" {
+		if cfg.LogLevel == "debug" {
+			gin.SetMode(gin.DebugMode)
+		} else {
+			gin.SetMode(gin.ReleaseMode)
+		}
+	}
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(handlers.RequestID())
+	if cfg.LogLevel == "debug" || cfg.LogLevel == "info" {
+		router.Use(gin.Logger())
+	}
 
 	// Configure CORS
 	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = []string{"http://localhost:3000"} // Frontend URL
-	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	if len(cfg.CORSOrigins) == 1 && cfg.CORSOrigins[0] == "*" {
+		corsConfig.AllowAllOrigins = true
+	} else {
+		corsConfig.AllowOrigins = cfg.CORSOrigins
+	}
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "If-Match", "If-None-Match", "Idempotency-Key", handlers.APIKeyHeader, handlers.RequestIDHeader}
+	corsConfig.ExposeHeaders = []string{"ETag", "Idempotent-Replayed", handlers.RequestIDHeader}
 	router.Use(cors.New(corsConfig))
 
-	// Routes
-	api := router.Group("/api")
+	// Routes, grouped by the least privileged role allowed to use them. The
+	// service checks the same policy, including crew members' own flights.
+	api := router.Group("/api", apiMiddleware...)
+
+	// Cabin crew: look up vouchers, generate for their own flights and
+	// redeem seats
+	crew := api.Group("", handlers.RequireRole(models.RoleCrew))
 	{
-		api.POST("/check", voucherHandler.CheckVoucher)
-		api.POST("/generate", voucherHandler.GenerateVoucher)
-		api.POST("/voucher", voucherHandler.GetVoucher)
-		api.POST("/regenerate-seat", voucherHandler.RegenerateSeat)
+		crew.POST("/check", voucherHandler.CheckVoucher)
+		crew.POST("/generate", voucherHandler.GenerateVoucher)
+		crew.POST("/generate/batch", voucherHandler.GenerateBatch)
+		crew.POST("/voucher", voucherHandler.GetVoucher)
+
+		// RESTful voucher resources; :id is a voucher ID on the one-segment
+		// routes and a flight number on the flight/date routes
+		crew.GET("/vouchers", voucherHandler.ListVouchers)
+		crew.GET("/vouchers/:id", voucherHandler.GetVoucherResource)
+		crew.GET("/vouchers/:id/:date", voucherHandler.GetVoucherResource)
+		crew.GET("/vouchers/:id/history", voucherHandler.VoucherHistory)
+		crew.GET("/vouchers/:id/:date/history", voucherHandler.VoucherHistory)
+
+		// Seat status changes; the seat position is in the JSON body
+		crew.POST("/vouchers/:id/redeem", voucherHandler.RedeemSeatResource)
+		crew.POST("/vouchers/:id/:date/redeem", voucherHandler.RedeemSeatResource)
+
+		// Seats taken by booked passengers, excluded from every draw
+		crew.GET("/occupancy/:flightNumber/:date", voucherHandler.GetOccupancy)
+	}
+
+	// Supervisors: redraw and void seats, and upload seat occupancy
+	supervisor := api.Group("", handlers.RequireRole(models.RoleSupervisor))
+	{
+		supervisor.POST("/regenerate-seat", voucherHandler.RegenerateSeat)
+		supervisor.PATCH("/vouchers/:id", voucherHandler.PatchVoucherResource)
+		supervisor.PATCH("/vouchers/:id/:date", voucherHandler.PatchVoucherResource)
+		supervisor.POST("/vouchers/:id/void", voucherHandler.VoidSeatResource)
+		supervisor.POST("/vouchers/:id/:date/void", voucherHandler.VoidSeatResource)
+		supervisor.PUT("/occupancy/:flightNumber/:date", voucherHandler.SetOccupancy)
+		supervisor.DELETE("/occupancy/:flightNumber/:date", voucherHandler.DeleteOccupancy)
+	}
+
+	// Admins: delete and export vouchers, and replace the aircraft layouts
+	admin := api.Group("", handlers.RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/vouchers/export", voucherHandler.ExportVouchers)
+		admin.DELETE("/vouchers/:id", voucherHandler.DeleteVoucherResource)
+		admin.DELETE("/vouchers/:id/:date", voucherHandler.DeleteVoucherResource)
+		admin.PUT("/aircraft-layouts", voucherHandler.ReplaceAircraftLayouts)
 	}
 
 	// Health check endpoint
 	router.GET("/health", voucherHandler.HealthCheck)
 
 	// Start server
-	log.Printf("Server starting on port %s", cfg.Port)
-	log.Printf("Database file: %s", cfg.DBPath)
-	log.Printf("CORS enabled for: http://localhost:3000")
+	server := &http.Server{
+		Addr:         ":" + cfg.Port,
+		Handler:      router,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+
+	go func() {
+		var err error
+		if cfg.TLSEnabled() {
+			log.Printf("Server starting on port %s (HTTPS)", cfg.Port)
+			err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			log.Printf("Server starting on port %s", cfg.Port)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Wait for a termination signal, then let in-flight requests finish
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
 
-	if err := http.ListenAndServe(":"+cfg.Port, router); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	log.Printf("Shutting down server")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown did not complete: %v", err)
 	}
 }
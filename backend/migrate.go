@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"airline-voucher-backend/config"
+	"airline-voucher-backend/migrations"
+)
+
+const migrateUsage = `Usage: migrate <command>
+
+Commands:
+  status      List migrations and whether they have been applied
+  up          Apply all pending migrations
+  down [n]    Roll back the last n applied migrations (default 1)
+`
+
+// runMigrate implements the "migrate" subcommand used by ops to inspect and
+// upgrade deployed databases
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return fmt.Errorf("missing migrate command")
+	}
+
+	db, err := config.OpenDatabase(cfg.DBDriver, cfg.DataSource())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.ForDriver(db, cfg.DBDriver)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+
+	case "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+
+		rolledBack, err := migrator.Down(steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", rolledBack)
+
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return fmt.Errorf("unknown migrate command: %s", args[0])
+	}
+
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Database schema version: %d\n", version)
+
+	return nil
+}
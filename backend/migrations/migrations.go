@@ -0,0 +1,277 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// advisoryLockKey identifies the migration lock among the PostgreSQL
+// advisory locks of a database
+const advisoryLockKey = 0x766f7563686572 // "voucher"
+
+// Migration is a single, ordered schema change. Up and Down run inside a
+// transaction together with the bookkeeping in schema_migrations, so a
+// migration is either fully applied or not applied at all.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+	Down    func(tx *sql.Tx) error
+}
+
+// Status describes whether a migration has been applied to the database
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt string
+}
+
+// Migrator applies and rolls back migrations against a database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	// numberedPlaceholders selects $1, $2, ... in bookkeeping queries
+	numberedPlaceholders bool
+	// advisoryLock serializes Up and Down across processes with a
+	// PostgreSQL advisory lock. SQLite needs none: its transactions take
+	// the write lock when they begin.
+	advisoryLock bool
+}
+
+// New creates a Migrator for the SQLite schema
+func New(db *sql.DB) *Migrator {
+	return NewWithMigrations(db, sqliteMigrations)
+}
+
+// NewPostgres creates a Migrator for the PostgreSQL schema
+func NewPostgres(db *sql.DB) *Migrator {
+	m := NewWithMigrations(db, postgresMigrations)
+	m.numberedPlaceholders = true
+	m.advisoryLock = true
+	return m
+}
+
+// ForDriver creates a Migrator for the schema of the given database/sql driver
+func ForDriver(db *sql.DB, driver string) (*Migrator, error) {
+	switch driver {
+	case "sqlite3":
+		return New(db), nil
+	case "postgres":
+		return NewPostgres(db), nil
+	default:
+		return nil, fmt.Errorf("no migrations for database driver %q", driver)
+	}
+}
+
+// NewWithMigrations creates a Migrator for a custom list of migrations
+func NewWithMigrations(db *sql.DB, migrations []Migration) *Migrator {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	return &Migrator{
+		db:         db,
+		migrations: sorted,
+	}
+}
+
+// Status returns every known migration and whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, Status{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+
+	return statuses, nil
+}
+
+// Version returns the highest applied migration version, or 0 for an empty database
+func (m *Migrator) Version() (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+
+	return version, nil
+}
+
+// Up applies all pending migrations in order and returns how many were
+// applied. Replicas starting together against one database wait for each
+// other, and each applies only what is still pending once it gets its turn.
+func (m *Migrator) Up() (int, error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		if err := m.run(migration, migration.Up, true); err != nil {
+			return count, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// Down rolls back the given number of most recently applied migrations and
+// returns how many were rolled back
+func (m *Migrator) Down(steps int) (int, error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		if migration.Down == nil {
+			return count, fmt.Errorf("migration %d (%s) cannot be rolled back", migration.Version, migration.Name)
+		}
+
+		if err := m.run(migration, migration.Down, false); err != nil {
+			return count, fmt.Errorf("rollback of migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// lock takes the migration lock, if the Migrator uses one, and returns the
+// function that releases it. The advisory lock belongs to a session, so it
+// is held on a dedicated connection until released.
+func (m *Migrator) lock() (func(), error) {
+	if !m.advisoryLock {
+		return func() {}, nil
+	}
+
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockKey); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+
+	return func() {
+		conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, advisoryLockKey)
+		conn.Close()
+	}, nil
+}
+
+// run executes one migration step and records the result in a single transaction
+func (m *Migrator) run(migration Migration, step func(tx *sql.Tx) error, up bool) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := step(tx); err != nil {
+		return err
+	}
+
+	insertQuery := `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`
+	deleteQuery := `DELETE FROM schema_migrations WHERE version = ?`
+	if m.numberedPlaceholders {
+		insertQuery = `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`
+		deleteQuery = `DELETE FROM schema_migrations WHERE version = $1`
+	}
+
+	if up {
+		_, err = tx.Exec(
+			insertQuery,
+			migration.Version, migration.Name, time.Now().UTC().Format("2006-01-02 15:04:05"),
+		)
+	} else {
+		_, err = tx.Exec(deleteQuery, migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// applied returns the applied migration versions mapped to their apply time
+func (m *Migrator) applied() (map[int]string, error) {
+	createTableQuery := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	);
+	`
+
+	if _, err := m.db.Exec(createTableQuery); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt string
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
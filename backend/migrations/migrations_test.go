@@ -0,0 +1,416 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=on")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func columnsOf(t *testing.T, db *sql.DB, table string) map[string]bool {
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	columns, err := tableColumns(tx, table)
+	require.NoError(t, err)
+	return columns
+}
+
+func TestMigrator_UpFreshDatabase(t *testing.T) {
+	db := openTestDB(t)
+	migrator := New(db)
+
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Equal(t, len(sqliteMigrations), applied)
+
+	version, err := migrator.Version()
+	require.NoError(t, err)
+	assert.Equal(t, sqliteMigrations[len(sqliteMigrations)-1].Version, version)
+
+	columns := columnsOf(t, db, "vouchers")
+	assert.True(t, columns["cabin_class"])
+	assert.False(t, columns["seat1"])
+	assert.True(t, columnsOf(t, db, "voucher_seats")["seat"])
+
+	// Running again is a no-op
+	applied, err = migrator.Up()
+	require.NoError(t, err)
+	assert.Equal(t, 0, applied)
+}
+
+func TestMigrator_UpLegacyDatabase(t *testing.T) {
+	db := openTestDB(t)
+
+	// Schema and data as written by the original CREATE TABLE IF NOT EXISTS
+	_, err := db.Exec(`
+		CREATE TABLE vouchers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			crew_name TEXT NOT NULL,
+			crew_id TEXT NOT NULL,
+			flight_number TEXT NOT NULL,
+			flight_date TEXT NOT NULL,
+			aircraft_type TEXT NOT NULL,
+			seat1 TEXT NOT NULL,
+			seat2 TEXT NOT NULL,
+			seat3 TEXT NOT NULL,
+			created_at TEXT NOT NULL
+		);
+		INSERT INTO vouchers (crew_name, crew_id, flight_number, flight_date, aircraft_type, seat1, seat2, seat3, created_at)
+		VALUES ('Sarah', '98123', 'GA102', '2025-07-12', 'ATR', '1A', '5C', '12F', '2025-07-01 10:00:00');
+	`)
+	require.NoError(t, err)
+
+	_, err = New(db).Up()
+	require.NoError(t, err)
+
+	rows, err := db.Query(`SELECT position, seat FROM voucher_seats WHERE voucher_id = 1 ORDER BY position`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var seats []string
+	for rows.Next() {
+		var (
+			position int
+			seat     string
+		)
+		require.NoError(t, rows.Scan(&position, &seat))
+		seats = append(seats, seat)
+	}
+	assert.Equal(t, []string{"1A", "5C", "12F"}, seats)
+
+	var cabinClass string
+	require.NoError(t, db.QueryRow(`SELECT cabin_class FROM vouchers WHERE id = 1`).Scan(&cabinClass))
+	assert.Equal(t, "", cabinClass)
+
+	// Seats are backfilled with their flight, which makes them unique per flight
+	var flightNumber, flightDate string
+	require.NoError(t, db.QueryRow(`SELECT flight_number, flight_date FROM voucher_seats WHERE voucher_id = 1 AND position = 1`).Scan(&flightNumber, &flightDate))
+	assert.Equal(t, "GA102", flightNumber)
+	assert.Equal(t, "2025-07-12", flightDate)
+
+	_, err = db.Exec(`INSERT INTO voucher_seats (voucher_id, position, seat, flight_number, flight_date) VALUES (1, 4, '5C', 'GA102', '2025-07-12')`)
+	assert.Error(t, err)
+
+	// Seats drawn before statuses existed start issued
+	var status string
+	require.NoError(t, db.QueryRow(`SELECT status FROM voucher_seats WHERE voucher_id = 1 AND position = 1`).Scan(&status))
+	assert.Equal(t, "issued", status)
+}
+
+func TestMigrator_VoucherEventsAreAppendOnly(t *testing.T) {
+	db := openTestDB(t)
+	_, err := New(db).Up()
+	require.NoError(t, err)
+
+	_, err = db.Exec(`INSERT INTO voucher_events (voucher_id, event_type, new_value, created_at) VALUES (1, 'create', '1A', '2025-07-01 10:00:00')`)
+	require.NoError(t, err)
+
+	_, err = db.Exec(`UPDATE voucher_events SET new_value = '2B' WHERE id = 1`)
+	assert.ErrorContains(t, err, "append-only")
+	_, err = db.Exec(`DELETE FROM voucher_events WHERE id = 1`)
+	assert.ErrorContains(t, err, "append-only")
+}
+
+func TestMigrator_SeatDrawsOutliveVouchers(t *testing.T) {
+	db := openTestDB(t)
+	migrator := New(db)
+
+	// Draws recorded while seat_draws still cascaded with the voucher
+	_, err := migrator.Up()
+	require.NoError(t, err)
+	steps := stepsThrough("keep_seat_draws")
+	_, err = migrator.Down(steps)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+		INSERT INTO vouchers (crew_name, crew_id, flight_number, flight_date, aircraft_type, cabin_class, created_at)
+		VALUES ('Sarah', '98123', 'GA102', '2025-07-12', 'ATR', '', '2025-07-01 10:00:00');
+		INSERT INTO seat_draws (voucher_id, algorithm, seed, commitment, pool, seats, created_at)
+		VALUES (1, 'test/v1', '00', 'c1', '1A', '1A', '2025-07-01 10:00:00'),
+			(1, 'test/v1', '01', 'c2', '5C', '5C', '2025-07-01 10:00:00'),
+			(1, 'test/v1', '02', 'c3', '9F', '9F', '2025-07-01 10:00:00');
+		DELETE FROM seat_draws WHERE id = 3;
+		INSERT INTO voucher_seats (voucher_id, position, seat, flight_number, flight_date, draw_id)
+		VALUES (1, 1, '1A', 'GA102', '2025-07-12', 1), (1, 2, '5C', 'GA102', '2025-07-12', 2);
+	`)
+	require.NoError(t, err)
+
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	// Seats keep pointing to their draws
+	var drawID int
+	require.NoError(t, db.QueryRow(`SELECT draw_id FROM voucher_seats WHERE voucher_id = 1 AND position = 2`).Scan(&drawID))
+	assert.Equal(t, 2, drawID)
+
+	_, err = db.Exec(`DELETE FROM vouchers WHERE id = 1`)
+	require.NoError(t, err)
+	var draws int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM seat_draws WHERE voucher_id = 1`).Scan(&draws))
+	assert.Equal(t, 2, draws)
+
+	// The ID of a removed draw is never handed out again
+	var id int
+	require.NoError(t, db.QueryRow(`
+		INSERT INTO seat_draws (voucher_id, algorithm, seed, commitment, pool, seats, created_at)
+		VALUES (2, 'test/v1', '03', 'c4', '2A', '2A', '2025-07-02 10:00:00') RETURNING id
+	`).Scan(&id))
+	assert.Equal(t, 4, id)
+
+	// Rolling back drops the draws of deleted vouchers and cascades again
+	_, err = migrator.Down(steps)
+	require.NoError(t, err)
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM seat_draws`).Scan(&draws))
+	assert.Equal(t, 0, draws)
+}
+
+// stepsThrough returns how many SQLite migrations Down must roll back to
+// undo the named one
+func stepsThrough(name string) int {
+	for _, migration := range sqliteMigrations {
+		if migration.Name == name {
+			return len(sqliteMigrations) - migration.Version + 1
+		}
+	}
+	return 0
+}
+
+func TestMigrator_ScopedIdempotencyKeys(t *testing.T) {
+	db := openTestDB(t)
+	migrator := New(db)
+
+	// A key stored while keys were global
+	_, err := migrator.Up()
+	require.NoError(t, err)
+	_, err = migrator.Down(stepsThrough("scope_idempotency_keys"))
+	require.NoError(t, err)
+	_, err = db.Exec(`
+		INSERT INTO vouchers (crew_name, crew_id, flight_number, flight_date, aircraft_type, cabin_class, created_at)
+		VALUES ('Sarah', '98123', 'GA102', '2025-07-12', 'ATR', '', '2025-07-01 10:00:00'),
+			('Omar', '77001', 'GA103', '2025-07-12', 'ATR', '', '2025-07-02 10:00:00');
+		INSERT INTO idempotency_keys (idempotency_key, request_hash, response, voucher_id, created_at)
+		VALUES ('key-1', 'h1', '{}', 1, '2025-07-01 10:00:00');
+	`)
+	require.NoError(t, err)
+
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	var scope string
+	require.NoError(t, db.QueryRow(`SELECT scope FROM idempotency_keys WHERE idempotency_key = 'key-1'`).Scan(&scope))
+	assert.Empty(t, scope)
+
+	// The same key in another scope
+	_, err = db.Exec(`
+		INSERT INTO idempotency_keys (scope, idempotency_key, request_hash, response, voucher_id, created_at)
+		VALUES ('crew:77001', 'key-1', 'h2', '{}', 2, '2025-07-02 10:00:00')
+	`)
+	require.NoError(t, err)
+
+	// Rolling back keeps the oldest record of each key
+	_, err = migrator.Down(stepsThrough("scope_idempotency_keys"))
+	require.NoError(t, err)
+	var hash string
+	require.NoError(t, db.QueryRow(`SELECT request_hash FROM idempotency_keys WHERE idempotency_key = 'key-1'`).Scan(&hash))
+	assert.Equal(t, "h1", hash)
+}
+
+func TestMigrator_DownRestoresPreviousSchema(t *testing.T) {
+	db := openTestDB(t)
+	migrator := New(db)
+
+	_, err := migrator.Up()
+	require.NoError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO vouchers (crew_name, crew_id, flight_number, flight_date, aircraft_type, cabin_class, created_at)
+		VALUES ('Sarah', '98123', 'GA102', '2025-07-12', 'ATR', '', '2025-07-01 10:00:00');
+		INSERT INTO voucher_seats (voucher_id, position, seat) VALUES (1, 1, '1A'), (1, 2, '5C'), (1, 3, '12F');
+	`)
+	require.NoError(t, err)
+
+	// Roll back to just before voucher_seats was introduced
+	steps := 0
+	for _, migration := range sqliteMigrations {
+		if migration.Name == "create_voucher_seats" {
+			steps = len(sqliteMigrations) - migration.Version + 1
+		}
+	}
+	rolledBack, err := migrator.Down(steps)
+	require.NoError(t, err)
+	assert.Equal(t, steps, rolledBack)
+
+	var seat1, seat2, seat3 string
+	require.NoError(t, db.QueryRow(`SELECT seat1, seat2, seat3 FROM vouchers WHERE id = 1`).Scan(&seat1, &seat2, &seat3))
+	assert.Equal(t, []string{"1A", "5C", "12F"}, []string{seat1, seat2, seat3})
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	require.Len(t, statuses, len(sqliteMigrations))
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[len(statuses)-1].Applied)
+
+	// Roll back everything, then re-apply
+	_, err = migrator.Down(len(sqliteMigrations))
+	require.NoError(t, err)
+
+	version, err := migrator.Version()
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Equal(t, len(sqliteMigrations), applied)
+}
+
+func TestMigrator_FailedMigrationIsRolledBack(t *testing.T) {
+	db := openTestDB(t)
+
+	migrator := NewWithMigrations(db, []Migration{
+		{
+			Version: 2,
+			Name:    "broken",
+			Up: func(tx *sql.Tx) error {
+				if _, err := tx.Exec(`CREATE TABLE half_done (id INTEGER)`); err != nil {
+					return err
+				}
+				return errors.New("boom")
+			},
+		},
+		{
+			Version: 1,
+			Name:    "first",
+			Up: func(tx *sql.Tx) error {
+				return execAll(tx, `CREATE TABLE first (id INTEGER)`)
+			},
+		},
+	})
+
+	applied, err := migrator.Up()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "migration 2 (broken) failed")
+	assert.Equal(t, 1, applied)
+
+	version, err := migrator.Version()
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'`).Scan(&count))
+	assert.Equal(t, 0, count)
+
+	// Migrations without a Down step cannot be rolled back
+	_, err = migrator.Down(1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot be rolled back")
+}
+
+func TestForDriver(t *testing.T) {
+	db := openTestDB(t)
+
+	migrator, err := ForDriver(db, "sqlite3")
+	require.NoError(t, err)
+	assert.False(t, migrator.numberedPlaceholders)
+	assert.False(t, migrator.advisoryLock)
+
+	migrator, err = ForDriver(db, "postgres")
+	require.NoError(t, err)
+	assert.True(t, migrator.numberedPlaceholders)
+	assert.True(t, migrator.advisoryLock)
+
+	_, err = ForDriver(db, "mysql")
+	assert.Error(t, err)
+}
+
+// openPostgresTestDB opens the database of TEST_POSTGRES_DSN in an empty
+// schema for one test, dropped on cleanup, or skips the test without it
+func openPostgresTestDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set (see make test-postgres)")
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+
+	admin, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		require.NoError(t, err)
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestPostgresMigrator_ConcurrentUp(t *testing.T) {
+	db := openPostgresTestDB(t)
+
+	// Replicas starting together each try to migrate the same database
+	const replicas = 4
+	errs := make([]error, replicas)
+	counts := make([]int, replicas)
+
+	var wg sync.WaitGroup
+	for i := 0; i < replicas; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			counts[i], errs[i] = NewPostgres(db).Up()
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range errs {
+		require.NoError(t, errs[i])
+		total += counts[i]
+	}
+	assert.Equal(t, len(postgresMigrations), total, "each migration is applied exactly once")
+
+	version, err := NewPostgres(db).Version()
+	require.NoError(t, err)
+	assert.Equal(t, postgresMigrations[len(postgresMigrations)-1].Version, version)
+}
+
+func TestPostgresMigrationsMatchSQLite(t *testing.T) {
+	require.Len(t, postgresMigrations, len(sqliteMigrations))
+	for i, migration := range postgresMigrations {
+		assert.Equal(t, sqliteMigrations[i].Version, migration.Version)
+		assert.Equal(t, sqliteMigrations[i].Name, migration.Name)
+		assert.NotNil(t, migration.Down, migration.Name)
+	}
+}
@@ -0,0 +1,309 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// postgresMigrations is the ordered history of the PostgreSQL schema. Versions
+// and names match sqliteMigrations so both engines report the same status.
+var postgresMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_vouchers",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS vouchers (
+					id SERIAL PRIMARY KEY,
+					crew_name TEXT NOT NULL,
+					crew_id TEXT NOT NULL,
+					flight_number TEXT NOT NULL,
+					flight_date TEXT NOT NULL,
+					aircraft_type TEXT NOT NULL,
+					seat1 TEXT NOT NULL,
+					seat2 TEXT NOT NULL,
+					seat3 TEXT NOT NULL,
+					created_at TEXT NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_flight_date ON vouchers(flight_number, flight_date)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `DROP TABLE IF EXISTS vouchers`)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "add_vouchers_cabin_class",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx, `ALTER TABLE vouchers ADD COLUMN IF NOT EXISTS cabin_class TEXT NOT NULL DEFAULT ''`)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `ALTER TABLE vouchers DROP COLUMN IF EXISTS cabin_class`)
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create_voucher_seats",
+		Up: func(tx *sql.Tx) error {
+			err := execAll(tx,
+				`CREATE TABLE IF NOT EXISTS voucher_seats (
+					voucher_id INTEGER NOT NULL REFERENCES vouchers(id) ON DELETE CASCADE,
+					position INTEGER NOT NULL,
+					seat TEXT NOT NULL,
+					PRIMARY KEY (voucher_id, position)
+				)`,
+			)
+			if err != nil {
+				return err
+			}
+
+			// Move seats out of the fixed seat1/seat2/seat3 columns
+			for i, column := range []string{"seat1", "seat2", "seat3"} {
+				query := fmt.Sprintf(
+					`INSERT INTO voucher_seats (voucher_id, position, seat) SELECT id, %d, %s FROM vouchers ON CONFLICT DO NOTHING`,
+					i+1, column,
+				)
+				if _, err := tx.Exec(query); err != nil {
+					return err
+				}
+			}
+
+			return execAll(tx, `ALTER TABLE vouchers DROP COLUMN seat1, DROP COLUMN seat2, DROP COLUMN seat3`)
+		},
+		Down: func(tx *sql.Tx) error {
+			for i, column := range []string{"seat1", "seat2", "seat3"} {
+				query := fmt.Sprintf(
+					`ALTER TABLE vouchers ADD COLUMN %s TEXT NOT NULL DEFAULT ''`,
+					column,
+				)
+				if _, err := tx.Exec(query); err != nil {
+					return err
+				}
+
+				query = fmt.Sprintf(
+					`UPDATE vouchers SET %s = COALESCE((SELECT seat FROM voucher_seats WHERE voucher_id = vouchers.id AND position = %d), '')`,
+					column, i+1,
+				)
+				if _, err := tx.Exec(query); err != nil {
+					return err
+				}
+			}
+
+			return execAll(tx, `DROP TABLE voucher_seats`)
+		},
+	},
+	{
+		Version: 4,
+		Name:    "unique_vouchers_flight_date",
+		Up: func(tx *sql.Tx) error {
+			if err := checkNoDuplicateVouchers(tx); err != nil {
+				return err
+			}
+
+			return execAll(tx,
+				`CREATE UNIQUE INDEX idx_vouchers_flight_date ON vouchers(flight_number, flight_date)`,
+				`DROP INDEX IF EXISTS idx_flight_date`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE INDEX IF NOT EXISTS idx_flight_date ON vouchers(flight_number, flight_date)`,
+				`DROP INDEX idx_vouchers_flight_date`,
+			)
+		},
+	},
+	{
+		Version: 5,
+		Name:    "create_idempotency_keys",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS idempotency_keys (
+					idempotency_key TEXT PRIMARY KEY,
+					request_hash TEXT NOT NULL,
+					response TEXT NOT NULL,
+					voucher_id INTEGER NOT NULL REFERENCES vouchers(id) ON DELETE CASCADE,
+					created_at TEXT NOT NULL
+				)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `DROP TABLE idempotency_keys`)
+		},
+	},
+	{
+		Version: 6,
+		Name:    "create_seat_draws",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS seat_draws (
+					id SERIAL PRIMARY KEY,
+					voucher_id INTEGER NOT NULL REFERENCES vouchers(id) ON DELETE CASCADE,
+					algorithm TEXT NOT NULL,
+					seed TEXT NOT NULL,
+					commitment TEXT NOT NULL,
+					pool TEXT NOT NULL,
+					seats TEXT NOT NULL,
+					created_at TEXT NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_seat_draws_voucher ON seat_draws(voucher_id)`,
+				`ALTER TABLE voucher_seats ADD COLUMN IF NOT EXISTS draw_id INTEGER REFERENCES seat_draws(id)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`ALTER TABLE voucher_seats DROP COLUMN IF EXISTS draw_id`,
+				`DROP TABLE seat_draws`,
+			)
+		},
+	},
+	{
+		Version: 7,
+		Name:    "create_occupied_seats",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS occupied_seats (
+					flight_number TEXT NOT NULL,
+					flight_date TEXT NOT NULL,
+					seat TEXT NOT NULL,
+					uploaded_at TEXT NOT NULL,
+					PRIMARY KEY (flight_number, flight_date, seat)
+				)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `DROP TABLE occupied_seats`)
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add_voucher_seats_flight",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`ALTER TABLE voucher_seats ADD COLUMN IF NOT EXISTS flight_number TEXT NOT NULL DEFAULT ''`,
+				`ALTER TABLE voucher_seats ADD COLUMN IF NOT EXISTS flight_date TEXT NOT NULL DEFAULT ''`,
+				`UPDATE voucher_seats s SET flight_number = v.flight_number, flight_date = v.flight_date
+					FROM vouchers v WHERE v.id = s.voucher_id`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_voucher_seats_flight_seat ON voucher_seats(flight_number, flight_date, seat)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`DROP INDEX IF EXISTS idx_voucher_seats_flight_seat`,
+				`ALTER TABLE voucher_seats DROP COLUMN IF EXISTS flight_date`,
+				`ALTER TABLE voucher_seats DROP COLUMN IF EXISTS flight_number`,
+			)
+		},
+	},
+	{
+		Version: 9,
+		Name:    "add_voucher_seats_status",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`ALTER TABLE voucher_seats ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'issued'`,
+				`ALTER TABLE voucher_seats ADD COLUMN IF NOT EXISTS redeemed_by TEXT NOT NULL DEFAULT ''`,
+				`ALTER TABLE voucher_seats ADD COLUMN IF NOT EXISTS redeemed_at TEXT NOT NULL DEFAULT ''`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`ALTER TABLE voucher_seats DROP COLUMN IF EXISTS redeemed_at`,
+				`ALTER TABLE voucher_seats DROP COLUMN IF EXISTS redeemed_by`,
+				`ALTER TABLE voucher_seats DROP COLUMN IF EXISTS status`,
+			)
+		},
+	},
+	{
+		Version: 10,
+		Name:    "create_voucher_events",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS voucher_events (
+					id SERIAL PRIMARY KEY,
+					voucher_id INTEGER NOT NULL,
+					event_type TEXT NOT NULL,
+					position INTEGER NOT NULL DEFAULT 0,
+					actor_id TEXT NOT NULL DEFAULT '',
+					old_value TEXT NOT NULL DEFAULT '',
+					new_value TEXT NOT NULL DEFAULT '',
+					request_id TEXT NOT NULL DEFAULT '',
+					created_at TEXT NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_voucher_events_voucher ON voucher_events(voucher_id, id)`,
+				`CREATE OR REPLACE FUNCTION voucher_events_append_only() RETURNS trigger AS $$
+				BEGIN
+					RAISE EXCEPTION 'voucher_events is append-only';
+				END
+				$$ LANGUAGE plpgsql`,
+				`DROP TRIGGER IF EXISTS voucher_events_append_only ON voucher_events`,
+				`CREATE TRIGGER voucher_events_append_only BEFORE UPDATE OR DELETE ON voucher_events
+				FOR EACH ROW EXECUTE FUNCTION voucher_events_append_only()`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`DROP TABLE IF EXISTS voucher_events`,
+				`DROP FUNCTION IF EXISTS voucher_events_append_only()`,
+			)
+		},
+	},
+	{
+		Version: 11,
+		Name:    "add_voucher_events_reason",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx, `ALTER TABLE voucher_events ADD COLUMN IF NOT EXISTS reason TEXT NOT NULL DEFAULT ''`)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `ALTER TABLE voucher_events DROP COLUMN IF EXISTS reason`)
+		},
+	},
+	{
+		Version: 12,
+		Name:    "keep_seat_draws",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx, `ALTER TABLE seat_draws DROP CONSTRAINT IF EXISTS seat_draws_voucher_id_fkey`)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`DELETE FROM seat_draws WHERE voucher_id NOT IN (SELECT id FROM vouchers)`,
+				`ALTER TABLE seat_draws ADD CONSTRAINT seat_draws_voucher_id_fkey
+				FOREIGN KEY (voucher_id) REFERENCES vouchers(id) ON DELETE CASCADE`,
+			)
+		},
+	},
+	{
+		Version: 13,
+		Name:    "scope_idempotency_keys",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT ''`,
+				`ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey`,
+				`ALTER TABLE idempotency_keys ADD PRIMARY KEY (scope, idempotency_key)`,
+				`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			// A key used by several callers keeps its oldest record
+			return execAll(tx,
+				`DELETE FROM idempotency_keys a USING idempotency_keys b
+				WHERE a.idempotency_key = b.idempotency_key AND (a.created_at, a.scope) > (b.created_at, b.scope)`,
+				`DROP INDEX IF EXISTS idx_idempotency_keys_created_at`,
+				`ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey`,
+				`ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS scope`,
+				`ALTER TABLE idempotency_keys ADD PRIMARY KEY (idempotency_key)`,
+			)
+		},
+	},
+	{
+		Version: 14,
+		Name:    "add_voucher_version",
+		Up: func(tx *sql.Tx) error {
+			// Every change to a voucher bumps its version, so a write can
+			// require the version its caller last saw
+			return execAll(tx, `ALTER TABLE vouchers ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1`)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `ALTER TABLE vouchers DROP COLUMN IF EXISTS version`)
+		},
+	},
+}
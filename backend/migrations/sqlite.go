@@ -0,0 +1,481 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// sqliteMigrations is the ordered history of the SQLite schema. Databases
+// created before schema_migrations existed may already contain some of these
+// changes, so each step checks the current schema before altering it.
+var sqliteMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_vouchers",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS vouchers (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					crew_name TEXT NOT NULL,
+					crew_id TEXT NOT NULL,
+					flight_number TEXT NOT NULL,
+					flight_date TEXT NOT NULL,
+					aircraft_type TEXT NOT NULL,
+					seat1 TEXT NOT NULL,
+					seat2 TEXT NOT NULL,
+					seat3 TEXT NOT NULL,
+					created_at TEXT NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_flight_date ON vouchers(flight_number, flight_date)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `DROP TABLE IF EXISTS vouchers`)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "add_vouchers_cabin_class",
+		Up: func(tx *sql.Tx) error {
+			return addColumn(tx, "vouchers", "cabin_class", "TEXT NOT NULL DEFAULT ''")
+		},
+		Down: func(tx *sql.Tx) error {
+			return dropColumns(tx, "vouchers", "cabin_class")
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create_voucher_seats",
+		Up: func(tx *sql.Tx) error {
+			err := execAll(tx,
+				`CREATE TABLE IF NOT EXISTS voucher_seats (
+					voucher_id INTEGER NOT NULL REFERENCES vouchers(id) ON DELETE CASCADE,
+					position INTEGER NOT NULL,
+					seat TEXT NOT NULL,
+					PRIMARY KEY (voucher_id, position)
+				)`,
+			)
+			if err != nil {
+				return err
+			}
+
+			// Move seats out of the fixed seat1/seat2/seat3 columns
+			columns, err := tableColumns(tx, "vouchers")
+			if err != nil {
+				return err
+			}
+
+			var legacyColumns []string
+			for i, column := range []string{"seat1", "seat2", "seat3"} {
+				if !columns[column] {
+					continue
+				}
+				legacyColumns = append(legacyColumns, column)
+
+				query := fmt.Sprintf(
+					`INSERT OR IGNORE INTO voucher_seats (voucher_id, position, seat) SELECT id, %d, %s FROM vouchers`,
+					i+1, column,
+				)
+				if _, err := tx.Exec(query); err != nil {
+					return err
+				}
+			}
+
+			return dropColumns(tx, "vouchers", legacyColumns...)
+		},
+		Down: func(tx *sql.Tx) error {
+			for i, column := range []string{"seat1", "seat2", "seat3"} {
+				if err := addColumn(tx, "vouchers", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+					return err
+				}
+
+				query := fmt.Sprintf(
+					`UPDATE vouchers SET %s = COALESCE((SELECT seat FROM voucher_seats WHERE voucher_id = vouchers.id AND position = %d), '')`,
+					column, i+1,
+				)
+				if _, err := tx.Exec(query); err != nil {
+					return err
+				}
+			}
+
+			return execAll(tx, `DROP TABLE voucher_seats`)
+		},
+	},
+	{
+		Version: 4,
+		Name:    "unique_vouchers_flight_date",
+		Up: func(tx *sql.Tx) error {
+			if err := checkNoDuplicateVouchers(tx); err != nil {
+				return err
+			}
+
+			return execAll(tx,
+				`CREATE UNIQUE INDEX idx_vouchers_flight_date ON vouchers(flight_number, flight_date)`,
+				`DROP INDEX IF EXISTS idx_flight_date`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE INDEX IF NOT EXISTS idx_flight_date ON vouchers(flight_number, flight_date)`,
+				`DROP INDEX idx_vouchers_flight_date`,
+			)
+		},
+	},
+	{
+		Version: 5,
+		Name:    "create_idempotency_keys",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS idempotency_keys (
+					idempotency_key TEXT PRIMARY KEY,
+					request_hash TEXT NOT NULL,
+					response TEXT NOT NULL,
+					voucher_id INTEGER NOT NULL REFERENCES vouchers(id) ON DELETE CASCADE,
+					created_at TEXT NOT NULL
+				)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `DROP TABLE idempotency_keys`)
+		},
+	},
+	{
+		Version: 6,
+		Name:    "create_seat_draws",
+		Up: func(tx *sql.Tx) error {
+			err := execAll(tx,
+				`CREATE TABLE IF NOT EXISTS seat_draws (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					voucher_id INTEGER NOT NULL REFERENCES vouchers(id) ON DELETE CASCADE,
+					algorithm TEXT NOT NULL,
+					seed TEXT NOT NULL,
+					commitment TEXT NOT NULL,
+					pool TEXT NOT NULL,
+					seats TEXT NOT NULL,
+					created_at TEXT NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_seat_draws_voucher ON seat_draws(voucher_id)`,
+			)
+			if err != nil {
+				return err
+			}
+
+			return addColumn(tx, "voucher_seats", "draw_id", "INTEGER REFERENCES seat_draws(id)")
+		},
+		Down: func(tx *sql.Tx) error {
+			if err := dropColumns(tx, "voucher_seats", "draw_id"); err != nil {
+				return err
+			}
+			return execAll(tx, `DROP TABLE seat_draws`)
+		},
+	},
+	{
+		Version: 7,
+		Name:    "create_occupied_seats",
+		Up: func(tx *sql.Tx) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS occupied_seats (
+					flight_number TEXT NOT NULL,
+					flight_date TEXT NOT NULL,
+					seat TEXT NOT NULL,
+					uploaded_at TEXT NOT NULL,
+					PRIMARY KEY (flight_number, flight_date, seat)
+				)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `DROP TABLE occupied_seats`)
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add_voucher_seats_flight",
+		Up: func(tx *sql.Tx) error {
+			// Each seat carries its flight and date, so one seat of a flight
+			// can only be assigned once across all vouchers
+			if err := addColumn(tx, "voucher_seats", "flight_number", "TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+			if err := addColumn(tx, "voucher_seats", "flight_date", "TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+			return execAll(tx,
+				`UPDATE voucher_seats SET
+					flight_number = (SELECT flight_number FROM vouchers WHERE id = voucher_seats.voucher_id),
+					flight_date = (SELECT flight_date FROM vouchers WHERE id = voucher_seats.voucher_id)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_voucher_seats_flight_seat ON voucher_seats(flight_number, flight_date, seat)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			if err := execAll(tx, `DROP INDEX IF EXISTS idx_voucher_seats_flight_seat`); err != nil {
+				return err
+			}
+			return dropColumns(tx, "voucher_seats", "flight_number", "flight_date")
+		},
+	},
+	{
+		Version: 9,
+		Name:    "add_voucher_seats_status",
+		Up: func(tx *sql.Tx) error {
+			// Existing seats were never redeemed, so they start issued
+			for _, column := range []struct{ name, definition string }{
+				{"status", "TEXT NOT NULL DEFAULT 'issued'"},
+				{"redeemed_by", "TEXT NOT NULL DEFAULT ''"},
+				{"redeemed_at", "TEXT NOT NULL DEFAULT ''"},
+			} {
+				if err := addColumn(tx, "voucher_seats", column.name, column.definition); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *sql.Tx) error {
+			return dropColumns(tx, "voucher_seats", "status", "redeemed_by", "redeemed_at")
+		},
+	},
+	{
+		Version: 10,
+		Name:    "create_voucher_events",
+		Up: func(tx *sql.Tx) error {
+			// Events have no foreign key, so a voucher's history outlives it,
+			// and triggers reject any change to a recorded event
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS voucher_events (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					voucher_id INTEGER NOT NULL,
+					event_type TEXT NOT NULL,
+					position INTEGER NOT NULL DEFAULT 0,
+					actor_id TEXT NOT NULL DEFAULT '',
+					old_value TEXT NOT NULL DEFAULT '',
+					new_value TEXT NOT NULL DEFAULT '',
+					request_id TEXT NOT NULL DEFAULT '',
+					created_at TEXT NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_voucher_events_voucher ON voucher_events(voucher_id, id)`,
+				`CREATE TRIGGER IF NOT EXISTS voucher_events_no_update BEFORE UPDATE ON voucher_events
+				BEGIN SELECT RAISE(ABORT, 'voucher_events is append-only'); END`,
+				`CREATE TRIGGER IF NOT EXISTS voucher_events_no_delete BEFORE DELETE ON voucher_events
+				BEGIN SELECT RAISE(ABORT, 'voucher_events is append-only'); END`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			return execAll(tx, `DROP TABLE IF EXISTS voucher_events`)
+		},
+	},
+	{
+		Version: 11,
+		Name:    "add_voucher_events_reason",
+		Up: func(tx *sql.Tx) error {
+			return addColumn(tx, "voucher_events", "reason", "TEXT NOT NULL DEFAULT ''")
+		},
+		Down: func(tx *sql.Tx) error {
+			return dropColumns(tx, "voucher_events", "reason")
+		},
+	},
+	{
+		Version: 12,
+		Name:    "keep_seat_draws",
+		Up: func(tx *sql.Tx) error {
+			// Draws are the evidence for disputed seats, so like events they
+			// outlive the voucher instead of cascading with it
+			return rebuildSeatDraws(tx, "")
+		},
+		Down: func(tx *sql.Tx) error {
+			err := execAll(tx, `DELETE FROM seat_draws WHERE voucher_id NOT IN (SELECT id FROM vouchers)`)
+			if err != nil {
+				return err
+			}
+			return rebuildSeatDraws(tx, "REFERENCES vouchers(id) ON DELETE CASCADE")
+		},
+	},
+	{
+		Version: 13,
+		Name:    "scope_idempotency_keys",
+		Up: func(tx *sql.Tx) error {
+			// Keys are unique per caller, so the primary key gains the scope
+			// and SQLite needs a new table. Existing keys keep the empty scope.
+			return execAll(tx,
+				`CREATE TABLE idempotency_keys_scoped (
+					scope TEXT NOT NULL DEFAULT '',
+					idempotency_key TEXT NOT NULL,
+					request_hash TEXT NOT NULL,
+					response TEXT NOT NULL,
+					voucher_id INTEGER NOT NULL REFERENCES vouchers(id) ON DELETE CASCADE,
+					created_at TEXT NOT NULL,
+					PRIMARY KEY (scope, idempotency_key)
+				)`,
+				`INSERT INTO idempotency_keys_scoped (idempotency_key, request_hash, response, voucher_id, created_at)
+				SELECT idempotency_key, request_hash, response, voucher_id, created_at FROM idempotency_keys`,
+				`DROP TABLE idempotency_keys`,
+				`ALTER TABLE idempotency_keys_scoped RENAME TO idempotency_keys`,
+				`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at)`,
+			)
+		},
+		Down: func(tx *sql.Tx) error {
+			// A key used by several callers keeps its oldest record
+			return execAll(tx,
+				`CREATE TABLE idempotency_keys_global (
+					idempotency_key TEXT PRIMARY KEY,
+					request_hash TEXT NOT NULL,
+					response TEXT NOT NULL,
+					voucher_id INTEGER NOT NULL REFERENCES vouchers(id) ON DELETE CASCADE,
+					created_at TEXT NOT NULL
+				)`,
+				`INSERT OR IGNORE INTO idempotency_keys_global (idempotency_key, request_hash, response, voucher_id, created_at)
+				SELECT idempotency_key, request_hash, response, voucher_id, created_at FROM idempotency_keys
+				ORDER BY created_at, scope`,
+				`DROP TABLE idempotency_keys`,
+				`ALTER TABLE idempotency_keys_global RENAME TO idempotency_keys`,
+			)
+		},
+	},
+	{
+		Version: 14,
+		Name:    "add_voucher_version",
+		Up: func(tx *sql.Tx) error {
+			// Every change to a voucher bumps its version, so a write can
+			// require the version its caller last saw
+			return addColumn(tx, "vouchers", "version", "INTEGER NOT NULL DEFAULT 1")
+		},
+		Down: func(tx *sql.Tx) error {
+			return dropColumns(tx, "vouchers", "version")
+		},
+	},
+}
+
+// rebuildSeatDraws recreates seat_draws with the given constraint on its
+// voucher_id column, keeping every draw, its ID and the ID sequence. SQLite
+// cannot change a constraint in place. The draw links of voucher_seats are
+// parked in a temporary table meanwhile, so dropping the old table leaves
+// no seat pointing to a missing draw.
+func rebuildSeatDraws(tx *sql.Tx, voucherReference string) error {
+	var sequence int64
+	err := tx.QueryRow(`SELECT seq FROM sqlite_sequence WHERE name = 'seat_draws'`).Scan(&sequence)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	err = execAll(tx,
+		`CREATE TEMP TABLE seat_draw_links AS
+			SELECT voucher_id, position, draw_id FROM voucher_seats WHERE draw_id IS NOT NULL`,
+		`UPDATE voucher_seats SET draw_id = NULL WHERE draw_id IS NOT NULL`,
+		fmt.Sprintf(`CREATE TABLE seat_draws_rebuilt (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			voucher_id INTEGER NOT NULL %s,
+			algorithm TEXT NOT NULL,
+			seed TEXT NOT NULL,
+			commitment TEXT NOT NULL,
+			pool TEXT NOT NULL,
+			seats TEXT NOT NULL,
+			created_at TEXT NOT NULL
+		)`, voucherReference),
+		`INSERT INTO seat_draws_rebuilt (id, voucher_id, algorithm, seed, commitment, pool, seats, created_at)
+			SELECT id, voucher_id, algorithm, seed, commitment, pool, seats, created_at FROM seat_draws`,
+		`DROP TABLE seat_draws`,
+		`ALTER TABLE seat_draws_rebuilt RENAME TO seat_draws`,
+		`CREATE INDEX IF NOT EXISTS idx_seat_draws_voucher ON seat_draws(voucher_id)`,
+		`UPDATE voucher_seats SET draw_id = (
+			SELECT draw_id FROM seat_draw_links l
+			WHERE l.voucher_id = voucher_seats.voucher_id AND l.position = voucher_seats.position
+		)`,
+		`DROP TABLE seat_draw_links`,
+		`DELETE FROM sqlite_sequence WHERE name = 'seat_draws'`,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO sqlite_sequence (name, seq) SELECT 'seat_draws', MAX(?, COALESCE(MAX(id), 0)) FROM seat_draws`, sequence)
+	return err
+}
+
+// checkNoDuplicateVouchers fails when two vouchers share a flight and date.
+// A unique index cannot be built over existing duplicates, so report them
+// for manual clean-up instead of failing obscurely.
+func checkNoDuplicateVouchers(tx *sql.Tx) error {
+	var (
+		flightNumber string
+		flightDate   string
+		count        int
+	)
+	err := tx.QueryRow(`
+		SELECT flight_number, flight_date, COUNT(*) FROM vouchers
+		GROUP BY flight_number, flight_date HAVING COUNT(*) > 1 LIMIT 1
+	`).Scan(&flightNumber, &flightDate, &count)
+	if err == nil {
+		return fmt.Errorf("found %d vouchers for flight %s on %s; remove duplicates before migrating", count, flightNumber, flightDate)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	return nil
+}
+
+// execAll runs each statement in order, stopping at the first error
+func execAll(tx *sql.Tx, statements ...string) error {
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumn adds a column to a table unless it already exists
+func addColumn(tx *sql.Tx, table, column, definition string) error {
+	columns, err := tableColumns(tx, table)
+	if err != nil {
+		return err
+	}
+	if columns[column] {
+		return nil
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// dropColumns removes the given columns from a table if they exist
+func dropColumns(tx *sql.Tx, table string, names ...string) error {
+	columns, err := tableColumns(tx, table)
+	if err != nil {
+		return err
+	}
+
+	for _, column := range names {
+		if !columns[column] {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableColumns returns the set of column names of a table
+func tableColumns(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
@@ -0,0 +1,7 @@
+package models
+
+// AircraftLayoutsResponse represents the response to PUT /api/aircraft-layouts
+type AircraftLayoutsResponse struct {
+	Success  bool     `json:"success"`
+	Aircraft []string `json:"aircraft"` // The aircraft types now available, sorted
+}
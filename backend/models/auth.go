@@ -0,0 +1,54 @@
+package models
+
+// Roles, from least to most privileged. Each role may do everything the
+// roles before it may.
+const (
+	RoleCrew       = "crew"       // generates vouchers for their own flights and redeems seats
+	RoleSupervisor = "supervisor" // also generates for any flight, redraws and voids seats and uploads occupancy
+	RoleAdmin      = "admin"      // also deletes and exports vouchers and replaces aircraft layouts
+)
+
+// Roles lists the roles in order of privilege
+var Roles = []string{RoleCrew, RoleSupervisor, RoleAdmin}
+
+// Principal is the authenticated caller of the API: a crew member
+// identified by a bearer token, or a service account identified by an API key
+type Principal struct {
+	ID             string `json:"id"`             // crew ID, or the service account name
+	Name           string `json:"name,omitempty"` // crew member's name, when the token carries one
+	Role           string `json:"role"`
+	ServiceAccount bool   `json:"serviceAccount"`
+	// Flights are the flight numbers a crew member works, from their token
+	Flights []string `json:"flights,omitempty"`
+}
+
+// ValidRole reports whether role is one of Roles
+func ValidRole(role string) bool {
+	return roleRank(role) >= 0
+}
+
+// HasRole reports whether the principal holds role or a more privileged one
+func (p *Principal) HasRole(role string) bool {
+	rank := roleRank(role)
+	return rank >= 0 && roleRank(p.Role) >= rank
+}
+
+// WorksFlight reports whether a flight number is among the principal's flights
+func (p *Principal) WorksFlight(flightNumber string) bool {
+	for _, flight := range p.Flights {
+		if flight == flightNumber {
+			return true
+		}
+	}
+	return false
+}
+
+// roleRank returns the position of role in Roles, or -1 when it is unknown
+func roleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
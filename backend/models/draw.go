@@ -0,0 +1,22 @@
+package models
+
+// SeatDraw records one random draw of seats for a voucher, so the draw can be
+// audited and reproduced with utils.ReplayDraw. The seed stays out of API
+// responses; the commitment, its SHA-256, is published instead.
+type SeatDraw struct {
+	ID         int      `json:"id" db:"id"`
+	VoucherID  int      `json:"voucher_id" db:"voucher_id"`
+	Algorithm  string   `json:"algorithm" db:"algorithm"`
+	Seed       string   `json:"-" db:"seed"`                // hex; revealed only for audits
+	Commitment string   `json:"commitment" db:"commitment"` // hex SHA-256 of the seed
+	Pool       []string `json:"pool" db:"pool"`             // candidate seats in shuffle order
+	Seats      []string `json:"seats" db:"seats"`           // seats drawn from the pool
+	CreatedAt  string   `json:"created_at" db:"created_at"`
+}
+
+// SeatCommitment identifies the draw that produced one seat of a voucher
+type SeatCommitment struct {
+	DrawID     int    `json:"draw_id"`
+	Algorithm  string `json:"algorithm"`
+	Commitment string `json:"commitment"`
+}
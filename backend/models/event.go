@@ -0,0 +1,31 @@
+package models
+
+// Voucher event types
+const (
+	EventCreate     = "create"     // the voucher was generated; NewValue lists its seats
+	EventRegenerate = "regenerate" // a seat was redrawn; OldValue and NewValue are the seats
+	EventRedeem     = "redeem"     // a seat was redeemed; the values are its statuses
+	EventVoid       = "void"       // a seat was voided; the values are its statuses
+	EventDelete     = "delete"     // the voucher was deleted; OldValue lists its seats
+)
+
+// VoucherEvent is one entry of a voucher's history. Events are stored in the
+// append-only voucher_events table and outlive the voucher they describe.
+type VoucherEvent struct {
+	ID        int    `json:"id" db:"id"`
+	VoucherID int    `json:"voucher_id" db:"voucher_id"`
+	Type      string `json:"type" db:"event_type"`             // One of the Event* constants
+	Position  int    `json:"position,omitempty" db:"position"` // 1-based seat position; 0 for the whole voucher
+	ActorID   string `json:"actor_id" db:"actor_id"`           // Crew ID of who made the change, if known
+	OldValue  string `json:"old_value,omitempty" db:"old_value"`
+	NewValue  string `json:"new_value,omitempty" db:"new_value"`
+	Reason    string `json:"reason,omitempty" db:"reason"`         // Reason code of a regeneration
+	RequestID string `json:"request_id,omitempty" db:"request_id"` // X-Request-ID of the API request
+	CreatedAt string `json:"created_at" db:"created_at"`
+}
+
+// VoucherHistoryResponse represents the response of GET /api/vouchers/.../history
+type VoucherHistoryResponse struct {
+	VoucherID int            `json:"voucherId"`
+	Events    []VoucherEvent `json:"events"` // Oldest first
+}
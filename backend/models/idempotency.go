@@ -0,0 +1,13 @@
+package models
+
+// IdempotencyKey records the response to a generate request sent with an
+// Idempotency-Key header, so a retry of the request can be answered with it.
+// Keys are unique per Scope, the caller that sent them.
+type IdempotencyKey struct {
+	Scope       string `json:"scope" db:"scope"`
+	Key         string `json:"key" db:"idempotency_key"`
+	RequestHash string `json:"request_hash" db:"request_hash"` // hex SHA-256 of the request payload
+	Response    string `json:"response" db:"response"`         // JSON GenerateVoucherResponse
+	VoucherID   int    `json:"voucher_id" db:"voucher_id"`
+	CreatedAt   string `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// Occupancy lists the seats of a flight already taken by booked passengers.
+// Vouchers are never drawn on these seats.
+type Occupancy struct {
+	FlightNumber string   `json:"flight_number" db:"flight_number"`
+	FlightDate   string   `json:"flight_date" db:"flight_date"`
+	Seats        []string `json:"seats"`                                  // stored in occupied_seats, one row per seat
+	UploadedAt   string   `json:"uploaded_at,omitempty" db:"uploaded_at"` // empty when nothing was uploaded
+}
+
+// SetOccupancyRequest represents the JSON body of PUT /api/occupancy/...
+type SetOccupancyRequest struct {
+	Seats []string `json:"seats"` // Replaces the occupied seats of the flight; empty clears them
+}
@@ -0,0 +1,31 @@
+package models
+
+// Seat statuses. Every seat starts issued and moves at most once, to
+// redeemed, expired or voided; the other statuses are final.
+const (
+	SeatStatusIssued   = "issued"   // drawn, not yet given to a passenger
+	SeatStatusRedeemed = "redeemed" // given to a passenger
+	SeatStatusExpired  = "expired"  // still issued when the voucher expired
+	SeatStatusVoided   = "voided"   // withdrawn, e.g. refused by the passenger
+)
+
+// SeatState records the status of one seat of a voucher
+type SeatState struct {
+	Status     string `json:"status" db:"status"`
+	RedeemedBy string `json:"redeemed_by,omitempty" db:"redeemed_by"` // crew ID of who redeemed the seat
+	RedeemedAt string `json:"redeemed_at,omitempty" db:"redeemed_at"`
+}
+
+// RedeemSeatRequest represents a POST to /api/vouchers/.../redeem
+type RedeemSeatRequest struct {
+	SeatPosition int    `json:"seatPosition" binding:"required,min=1"` // 1-based position of the seat to redeem
+	RedeemedBy   string `json:"redeemedBy"`                            // Crew ID of who gave the seat to the passenger; a crew token overrides it
+	Version      int    `json:"-"`                                     // Voucher version the caller expects, from If-Match; zero accepts any
+}
+
+// VoidSeatRequest represents a POST to /api/vouchers/.../void
+type VoidSeatRequest struct {
+	SeatPosition int    `json:"seatPosition" binding:"required,min=1"` // 1-based position of the seat to void
+	CrewID       string `json:"crewId"`                                // Optional: crew member voiding the seat, kept in the voucher history; a crew token overrides it
+	Version      int    `json:"-"`                                     // Voucher version the caller expects, from If-Match; zero accepts any
+}
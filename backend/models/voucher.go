@@ -1,7 +1,6 @@
 package models
 
 import (
-	"database/sql"
 	"time"
 )
 
@@ -13,10 +12,25 @@ type Voucher struct {
 	FlightNumber string `json:"flight_number" db:"flight_number"`
 	FlightDate   string `json:"flight_date" db:"flight_date"`
 	AircraftType string `json:"aircraft_type" db:"aircraft_type"`
-	Seat1        string `json:"seat1" db:"seat1"`
-	Seat2        string `json:"seat2" db:"seat2"`
-	Seat3        string `json:"seat3" db:"seat3"`
+	CabinClass   string `json:"cabin_class" db:"cabin_class"`
 	CreatedAt    string `json:"created_at" db:"created_at"`
+	// Version starts at 1 and grows with every change to the voucher's
+	// seats, so a write can require the version its caller last saw
+	Version int `json:"version" db:"version"`
+	// Seats holds the drawn seats in position order (stored in voucher_seats)
+	Seats []string `json:"seats"`
+	// Commitments identifies the draw behind each seat, in the same order as
+	// Seats. It is empty for vouchers drawn before draws were recorded.
+	Commitments []SeatCommitment `json:"commitments,omitempty"`
+	// SeatStates holds the status of each seat, in the same order as Seats
+	SeatStates []SeatState `json:"seat_states"`
+	// Draws are the draws that produced Seats; their seats, in order, are the
+	// voucher's seats. Repositories record them on create and fill in
+	// Commitments; they are not loaded back.
+	Draws []*SeatDraw `json:"-"`
+	// Events are recorded with the voucher on create, in the same
+	// transaction; they are not loaded back
+	Events []*VoucherEvent `json:"-"`
 }
 
 // CheckVoucherRequest represents the request to check if vouchers exist
@@ -32,11 +46,18 @@ type CheckVoucherResponse struct {
 
 // GenerateVoucherRequest represents the request to generate vouchers
 type GenerateVoucherRequest struct {
-	Name         string `json:"name" binding:"required"`
-	ID           string `json:"id" binding:"required"`
+	Name         string `json:"name"` // A crew token naming its holder overrides it
+	ID           string `json:"id"`   // A crew token overrides it
 	FlightNumber string `json:"flightNumber" binding:"required"`
 	Date         string `json:"date" binding:"required"`
 	Aircraft     string `json:"aircraft" binding:"required"`
+	CabinClass   string `json:"cabinClass"`                                 // Optional: limit the draw to one cabin zone
+	SeatCount    int    `json:"seatCount" binding:"omitempty,min=1,max=50"` // Optional: defaults to the service's seat count
+	// Optional: window, middle or aisle; every seat is drawn at that position
+	SeatPreference string `json:"seatPreference,omitempty"`
+	// Optional: minimum number of seats per position, e.g. {"window": 1};
+	// cannot be combined with SeatPreference
+	SeatMix map[string]int `json:"seatMix,omitempty"`
 }
 
 // GenerateVoucherResponse represents the response for generating vouchers
@@ -45,12 +66,85 @@ type GenerateVoucherResponse struct {
 	Seats   []string `json:"seats"`
 }
 
+// Batch generation modes
+const (
+	BatchModeAtomic  = "atomic"   // all vouchers are created in one transaction, or none are
+	BatchModePerItem = "per-item" // each voucher is created on its own; failures don't affect the rest
+)
+
+// Batch item statuses
+const (
+	BatchStatusCreated = "created" // the voucher was generated
+	BatchStatusExists  = "exists"  // the flight and date already have a voucher
+	BatchStatusInvalid = "invalid" // the item failed validation or its seat draw
+	BatchStatusSkipped = "skipped" // valid, but not created because an atomic batch failed
+)
+
+// BatchGenerateRequest represents the JSON body of POST /api/generate/batch
+type BatchGenerateRequest struct {
+	Mode  string                   `json:"mode"` // Optional: atomic or per-item (default)
+	Items []GenerateVoucherRequest `json:"items" binding:"required"`
+}
+
+// BatchItemResult reports the outcome of one item of a batch
+type BatchItemResult struct {
+	Index        int      `json:"index"` // 0-based position in the request (data row for CSV uploads)
+	FlightNumber string   `json:"flightNumber"`
+	Date         string   `json:"date"`
+	Status       string   `json:"status"` // One of the BatchStatus* constants
+	Seats        []string `json:"seats,omitempty"`
+	Code         string   `json:"code,omitempty"`    // Error code for exists and invalid items
+	Message      string   `json:"message,omitempty"` // Error details for exists and invalid items
+}
+
+// BatchGenerateResponse represents the response for batch generation
+type BatchGenerateResponse struct {
+	Success  bool              `json:"success"` // False when an atomic batch was rolled back
+	Mode     string            `json:"mode"`
+	Created  int               `json:"created"`
+	Existing int               `json:"existing"`
+	Invalid  int               `json:"invalid"`
+	Results  []BatchItemResult `json:"results"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error   string `json:"error"`
+	Code    string `json:"code"` // Machine-readable error code, one of the Code* constants
 	Message string `json:"message"`
 }
 
+// Error codes returned in ErrorResponse.Code
+const (
+	CodeInvalidRequest              = "INVALID_REQUEST"
+	CodeMissingFields               = "MISSING_FIELDS"
+	CodeInvalidAircraft             = "INVALID_AIRCRAFT"
+	CodeInvalidDate                 = "INVALID_DATE"
+	CodeInvalidCabinClass           = "INVALID_CABIN_CLASS"
+	CodeInvalidSeatCount            = "INVALID_SEAT_COUNT"
+	CodeInvalidSeatPosition         = "INVALID_SEAT_POSITION"
+	CodeNotEnoughSeats              = "NOT_ENOUGH_SEATS"
+	CodeInvalidOccupancy            = "INVALID_OCCUPANCY"
+	CodeInvalidAircraftLayouts      = "INVALID_AIRCRAFT_LAYOUTS"
+	CodeSeatTaken                   = "SEAT_TAKEN"
+	CodeInvalidSeatTransition       = "INVALID_SEAT_TRANSITION"
+	CodeInvalidReason               = "INVALID_REASON"
+	CodeRegenerationLimitReached    = "REGENERATION_LIMIT_REACHED"
+	CodeInvalidSeatPreference       = "INVALID_SEAT_PREFERENCE"
+	CodeSeatPreferenceUnsatisfiable = "SEAT_PREFERENCE_UNSATISFIABLE"
+	CodeVoucherExists               = "VOUCHER_EXISTS"
+	CodeVoucherNotFound             = "VOUCHER_NOT_FOUND"
+	CodeInvalidVoucherID            = "INVALID_VOUCHER_ID"
+	CodeInvalidQuery                = "INVALID_QUERY"
+	CodeInvalidBatch                = "INVALID_BATCH"
+	CodeInvalidIdempotencyKey       = "INVALID_IDEMPOTENCY_KEY"
+	CodeIdempotencyKeyReused        = "IDEMPOTENCY_KEY_REUSED"
+	CodePreconditionFailed          = "PRECONDITION_FAILED"
+	CodeUnauthorized                = "UNAUTHORIZED"
+	CodeForbidden                   = "FORBIDDEN"
+	CodeInternal                    = "INTERNAL_ERROR"
+)
+
 // GetVoucherRequest represents the request to get existing vouchers
 type GetVoucherRequest struct {
 	FlightNumber string `json:"flightNumber" binding:"required"`
@@ -67,21 +161,55 @@ type GetVoucherResponse struct {
 type RegenerateSeatRequest struct {
 	FlightNumber string `json:"flightNumber" binding:"required"`
 	Date         string `json:"date" binding:"required"`
-	SeatPosition int    `json:"seatPosition" binding:"required,min=1,max=3"` // 1, 2, or 3
+	SeatPosition int    `json:"seatPosition" binding:"required,min=1"` // 1-based position within the voucher's seats
+	CrewID       string `json:"crewId"`                                // Crew member redrawing the seat, kept in the voucher history; a crew token overrides it
+	Reason       string `json:"reason" binding:"required"`             // One of RegenerationReasons
+	Version      int    `json:"-"`                                     // Voucher version the caller expects, from If-Match; zero accepts any
+}
+
+// Reason codes a seat regeneration must give
+const (
+	ReasonPassengerRequest = "passenger_request" // the passenger asked for another seat
+	ReasonSeatUnavailable  = "seat_unavailable"  // the seat is broken or blocked
+	ReasonAccessibility    = "accessibility"     // the passenger needs a more accessible seat
+	ReasonOperational      = "operational"       // weight and balance or another operational need
+)
+
+// RegenerationReasons lists the accepted regeneration reason codes
+var RegenerationReasons = []string{ReasonPassengerRequest, ReasonSeatUnavailable, ReasonAccessibility, ReasonOperational}
+
+// ListVouchersRequest holds the query parameters of GET /api/vouchers
+type ListVouchersRequest struct {
+	DateFrom     string `form:"dateFrom"`     // Optional: earliest flight date, inclusive
+	DateTo       string `form:"dateTo"`       // Optional: latest flight date, inclusive
+	FlightNumber string `form:"flightNumber"` // Optional: flight number prefix
+	Aircraft     string `form:"aircraft"`     // Optional: exact aircraft type
+	CrewID       string `form:"crewId"`       // Optional: crew member who generated the voucher
+	Sort         string `form:"sort"`         // flightDate, flightNumber, createdAt or id; prefix with - for descending
+	Cursor       string `form:"cursor"`       // nextCursor from the previous page
+	Limit        int    `form:"limit"`        // Page size, defaults to 50
+}
+
+// ListVouchersResponse represents one page of vouchers
+type ListVouchersResponse struct {
+	Vouchers   []Voucher `json:"vouchers"`
+	Total      int       `json:"total"`                // Number of vouchers matching the filters across all pages
+	NextCursor string    `json:"nextCursor,omitempty"` // Empty on the last page
+}
+
+// UpdateVoucherRequest represents a PATCH to /api/vouchers/..., which
+// regenerates one seat of the voucher
+type UpdateVoucherRequest struct {
+	SeatPosition int    `json:"seatPosition" binding:"required,min=1"` // 1-based position of the seat to redraw
+	CrewID       string `json:"crewId"`                                // Crew member redrawing the seat, kept in the voucher history; a crew token overrides it
+	Reason       string `json:"reason" binding:"required"`             // One of RegenerationReasons
 }
 
 // RegenerateSeatResponse represents the response for regenerating a single seat
 type RegenerateSeatResponse struct {
 	Success  bool     `json:"success"`
 	NewSeat  string   `json:"newSeat"`
-	AllSeats []string `json:"allSeats"` // All three seats after regeneration
-}
-
-// Database interface for testing
-type Database interface {
-	Exec(query string, args ...interface{}) (sql.Result, error)
-	QueryRow(query string, args ...interface{}) *sql.Row
-	Close() error
+	AllSeats []string `json:"allSeats"` // All seats after regeneration
 }
 
 // GetCurrentTimestamp returns the current timestamp in ISO format
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"airline-voucher-backend/config"
+	"airline-voucher-backend/repository"
+	"airline-voucher-backend/services"
+	"airline-voucher-backend/utils"
+)
+
+const replayUsage = `Usage:
+  replay -id <voucher id>
+  replay -flight <flight number> -date <YYYY-MM-DD>
+  replay -seed <hex> -pool <seat,seat,...> -count <n> [-algorithm <name>]
+
+The first two forms replay every recorded draw of a voucher from the
+database. The last form re-derives a single draw from a disclosed seed.
+`
+
+// runReplay implements the "replay" subcommand used by compliance to re-derive
+// disputed seat draws. It exits with an error when any check fails.
+func runReplay(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), replayUsage)
+		fs.PrintDefaults()
+	}
+	id := fs.Int("id", 0, "voucher ID")
+	flightNumber := fs.String("flight", "", "flight number")
+	date := fs.String("date", "", "flight date, YYYY-MM-DD")
+	seed := fs.String("seed", "", "disclosed draw seed, hex")
+	pool := fs.String("pool", "", "comma-separated candidate seats, in recorded order")
+	count := fs.Int("count", 1, "number of seats drawn")
+	algorithm := fs.String("algorithm", utils.DrawAlgorithm, "draw algorithm")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *seed != "" {
+		seats, err := services.ReplayDraw(*algorithm, *seed, strings.Split(*pool, ","), *count)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("commitment  %s\n", commitmentOf(*seed))
+		fmt.Printf("seats       %s\n", strings.Join(seats, ", "))
+		return nil
+	}
+
+	if *id == 0 && (*flightNumber == "" || *date == "") {
+		fs.Usage()
+		return fmt.Errorf("a voucher ID, a flight and date, or a seed is required")
+	}
+
+	db, err := config.OpenDatabase(cfg.DBDriver, cfg.DataSource())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	repo, err := repository.NewSQLVoucherRepository(cfg.DBDriver, db)
+	if err != nil {
+		return err
+	}
+	service := services.NewVoucherService(repo)
+
+	if *id == 0 {
+		voucher, err := service.GetVoucher(*flightNumber, *date)
+		if err != nil {
+			return err
+		}
+		if voucher == nil {
+			return fmt.Errorf("%w for flight %s on %s", services.ErrVoucherNotFound, *flightNumber, *date)
+		}
+		*id = voucher.ID
+	}
+
+	replay, err := service.ReplayVoucherDraws(*id)
+	if err != nil {
+		return err
+	}
+
+	if voucher := replay.Voucher; voucher != nil {
+		fmt.Printf("Voucher %d: flight %s on %s, seats %s\n\n",
+			voucher.ID, voucher.FlightNumber, voucher.FlightDate, strings.Join(voucher.Seats, ", "))
+	} else {
+		fmt.Printf("Voucher %d: deleted, replaying its recorded draws\n\n", *id)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DRAW\tDRAWN AT\tALGORITHM\tCOMMITMENT\tRECORDED\tREPLAYED\tRESULT")
+	for _, check := range replay.Draws {
+		result := "ok"
+		switch {
+		case check.Err != nil:
+			result = check.Err.Error()
+		case !check.CommitmentValid:
+			result = "seed does not match commitment"
+		case !check.Reproduced:
+			result = "seats differ"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			check.Draw.ID,
+			check.Draw.CreatedAt,
+			check.Draw.Algorithm,
+			shortCommitment(check.Draw.Commitment),
+			strings.Join(check.Draw.Seats, ","),
+			strings.Join(check.Replayed, ","),
+			result,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, problem := range replay.Problems {
+		fmt.Printf("\n%s", problem)
+	}
+	if len(replay.Problems) > 0 {
+		fmt.Println()
+	}
+
+	if !replay.OK() {
+		return fmt.Errorf("voucher %d failed replay", *id)
+	}
+	fmt.Printf("\nAll %d draw(s) reproduced\n", len(replay.Draws))
+	return nil
+}
+
+// commitmentOf returns the commitment of a hex seed, or "" if it isn't hex
+func commitmentOf(seedHex string) string {
+	seed, err := hex.DecodeString(seedHex)
+	if err != nil {
+		return ""
+	}
+	return utils.SeedCommitment(seed)
+}
+
+// shortCommitment abbreviates a commitment for tabular output
+func shortCommitment(commitment string) string {
+	if len(commitment) > 16 {
+		return commitment[:16] + "…"
+	}
+	return commitment
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// checkSeatsFree fails with ErrSeatTaken when a voucher already holds one of
+// the seats on the flight and date
+func (r *sqlVoucherRepository) checkSeatsFree(tx *sql.Tx, flightNumber, date string, seats []string) error {
+	if len(seats) == 0 {
+		return nil
+	}
+
+	args := []interface{}{flightNumber, date}
+	for _, seat := range seats {
+		args = append(args, seat)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(seats)), ",")
+	query := fmt.Sprintf(`
+		SELECT seat FROM voucher_seats
+		WHERE flight_number = ? AND flight_date = ? AND seat IN (%s)
+		ORDER BY seat LIMIT 1
+	`, placeholders)
+
+	var taken string
+	err := tx.QueryRow(r.rebind(query), args...).Scan(&taken)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s on flight %s on %s", ErrSeatTaken, taken, flightNumber, date)
+}
+
+// AssignedSeats returns the seats of a flight and date held by any voucher
+func (r *sqlVoucherRepository) AssignedSeats(flightNumber, date string) ([]string, error) {
+	rows, err := r.db.Query(
+		r.rebind(`SELECT seat FROM voucher_seats WHERE flight_number = ? AND flight_date = ? ORDER BY seat`),
+		flightNumber, date,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seats := []string{}
+	for rows.Next() {
+		var seat string
+		if err := rows.Scan(&seat); err != nil {
+			return nil, err
+		}
+		seats = append(seats, seat)
+	}
+	return seats, rows.Err()
+}
+
+// AssignedSeats returns the seats of a flight and date held by any voucher
+func (r *MemoryVoucherRepository) AssignedSeats(flightNumber, date string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seats := []string{}
+	for _, voucher := range r.vouchers {
+		if voucher.FlightNumber == flightNumber && voucher.FlightDate == date {
+			seats = append(seats, voucher.Seats...)
+		}
+	}
+	sort.Strings(seats)
+	return seats, nil
+}
+
+// seatHolder returns the ID and 0-based position of the voucher holding a
+// seat of a flight and date; the caller must hold the lock
+func (r *MemoryVoucherRepository) seatHolder(flightNumber, date, seat string) (int, int, bool) {
+	for id, voucher := range r.vouchers {
+		if voucher.FlightNumber != flightNumber || voucher.FlightDate != date {
+			continue
+		}
+		for i, held := range voucher.Seats {
+			if held == seat {
+				return id, i, true
+			}
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"airline-voucher-backend/models"
+)
+
+// insertDraw stores a draw inside a transaction and sets its ID
+func (r *sqlVoucherRepository) insertDraw(tx *sql.Tx, draw *models.SeatDraw) (int64, error) {
+	pool, err := json.Marshal(draw.Pool)
+	if err != nil {
+		return 0, err
+	}
+	seats, err := json.Marshal(draw.Seats)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO seat_draws (voucher_id, algorithm, seed, commitment, pool, seats, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	id, err := r.insertID(tx, query,
+		draw.VoucherID,
+		draw.Algorithm,
+		draw.Seed,
+		draw.Commitment,
+		string(pool),
+		string(seats),
+		draw.CreatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	draw.ID = int(id)
+	return id, nil
+}
+
+// ListDraws returns the recorded draws of a voucher, oldest first
+func (r *sqlVoucherRepository) ListDraws(voucherID int) ([]models.SeatDraw, error) {
+	query := `
+		SELECT id, voucher_id, algorithm, seed, commitment, pool, seats, created_at
+		FROM seat_draws WHERE voucher_id = ? ORDER BY id
+	`
+	rows, err := r.db.Query(r.rebind(query), voucherID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	draws := []models.SeatDraw{}
+	for rows.Next() {
+		var (
+			draw  models.SeatDraw
+			pool  string
+			seats string
+		)
+		err := rows.Scan(&draw.ID, &draw.VoucherID, &draw.Algorithm, &draw.Seed, &draw.Commitment, &pool, &seats, &draw.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(pool), &draw.Pool); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(seats), &draw.Seats); err != nil {
+			return nil, err
+		}
+		draws = append(draws, draw)
+	}
+
+	return draws, rows.Err()
+}
+
+// drawCommitments returns the commitments of the seats produced by a
+// sequence of draws, in seat order, or nil when no draw was recorded
+func drawCommitments(draws []*models.SeatDraw) []models.SeatCommitment {
+	var commitments []models.SeatCommitment
+	for _, draw := range draws {
+		for range draw.Seats {
+			commitments = append(commitments, seatCommitment(draw))
+		}
+	}
+	return commitments
+}
+
+// seatCommitment identifies a draw for a voucher seat
+func seatCommitment(draw *models.SeatDraw) models.SeatCommitment {
+	return models.SeatCommitment{
+		DrawID:     draw.ID,
+		Algorithm:  draw.Algorithm,
+		Commitment: draw.Commitment,
+	}
+}
+
+// ListDraws returns copies of the recorded draws of a voucher, oldest first
+func (r *MemoryVoucherRepository) ListDraws(voucherID int) ([]models.SeatDraw, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	draws := []models.SeatDraw{}
+	for _, draw := range r.draws {
+		if draw.VoucherID == voucherID {
+			draws = append(draws, copyDraw(draw))
+		}
+	}
+	return draws, nil
+}
+
+// recordDraw stores a copy of a draw and assigns it the next ID; the caller
+// must hold the write lock
+func (r *MemoryVoucherRepository) recordDraw(draw *models.SeatDraw) {
+	r.nextDrawID++
+	draw.ID = r.nextDrawID
+	r.draws = append(r.draws, copyDraw(*draw))
+}
+
+// copyDraw returns a draw that shares no slices with the original
+func copyDraw(draw models.SeatDraw) models.SeatDraw {
+	draw.Pool = append([]string{}, draw.Pool...)
+	draw.Seats = append([]string{}, draw.Seats...)
+	return draw
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"database/sql"
+
+	"airline-voucher-backend/models"
+)
+
+// insertEvent appends an event for a voucher inside a transaction and sets
+// its IDs. A nil event records nothing.
+func (r *sqlVoucherRepository) insertEvent(tx *sql.Tx, voucherID int, event *models.VoucherEvent) error {
+	if event == nil {
+		return nil
+	}
+
+	query := `
+		INSERT INTO voucher_events (voucher_id, event_type, position, actor_id, old_value, new_value, reason, request_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	id, err := r.insertID(tx, query,
+		voucherID,
+		event.Type,
+		event.Position,
+		event.ActorID,
+		event.OldValue,
+		event.NewValue,
+		event.Reason,
+		event.RequestID,
+		event.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	event.ID = int(id)
+	event.VoucherID = voucherID
+	return nil
+}
+
+// ListEvents returns the recorded events of a voucher, oldest first
+func (r *sqlVoucherRepository) ListEvents(voucherID int) ([]models.VoucherEvent, error) {
+	query := `
+		SELECT id, voucher_id, event_type, position, actor_id, old_value, new_value, reason, request_id, created_at
+		FROM voucher_events WHERE voucher_id = ? ORDER BY id
+	`
+	rows, err := r.db.Query(r.rebind(query), voucherID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.VoucherEvent{}
+	for rows.Next() {
+		var event models.VoucherEvent
+		err := rows.Scan(&event.ID, &event.VoucherID, &event.Type, &event.Position, &event.ActorID,
+			&event.OldValue, &event.NewValue, &event.Reason, &event.RequestID, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// ListEvents returns copies of the recorded events of a voucher, oldest first
+func (r *MemoryVoucherRepository) ListEvents(voucherID int) ([]models.VoucherEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := []models.VoucherEvent{}
+	for _, event := range r.events {
+		if event.VoucherID == voucherID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// recordEvent appends a copy of an event for a voucher and sets its IDs; the
+// caller must hold the write lock. A nil event records nothing.
+func (r *MemoryVoucherRepository) recordEvent(voucherID int, event *models.VoucherEvent) {
+	if event == nil {
+		return
+	}
+	r.nextEventID++
+	event.ID = r.nextEventID
+	event.VoucherID = voucherID
+	r.events = append(r.events, *event)
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"airline-voucher-backend/models"
+)
+
+// CreateIdempotent inserts the voucher, its seats and the idempotency key in
+// one transaction
+func (r *sqlVoucherRepository) CreateIdempotent(voucher *models.Voucher, key *models.IdempotencyKey) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id, err := r.insert(tx, voucher)
+	if r.dialect.uniqueViolation(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO idempotency_keys (scope, idempotency_key, request_hash, response, voucher_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err = tx.Exec(r.rebind(query), key.Scope, key.Key, key.RequestHash, key.Response, id, key.CreatedAt)
+	if r.dialect.uniqueViolation(err) {
+		return ErrDuplicateKey
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	voucher.ID = int(id)
+	key.VoucherID = int(id)
+	return nil
+}
+
+// GetIdempotencyKey loads an idempotency key stored in a scope
+func (r *sqlVoucherRepository) GetIdempotencyKey(scope, key string) (*models.IdempotencyKey, error) {
+	query := `
+		SELECT scope, idempotency_key, request_hash, response, voucher_id, created_at
+		FROM idempotency_keys WHERE scope = ? AND idempotency_key = ?
+	`
+
+	var record models.IdempotencyKey
+	err := r.db.QueryRow(r.rebind(query), scope, key).Scan(
+		&record.Scope,
+		&record.Key,
+		&record.RequestHash,
+		&record.Response,
+		&record.VoucherID,
+		&record.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// DeleteIdempotencyKeys removes the idempotency keys created before a
+// timestamp
+func (r *sqlVoucherRepository) DeleteIdempotencyKeys(before string) (int, error) {
+	result, err := r.db.Exec(r.rebind(`DELETE FROM idempotency_keys WHERE created_at < ?`), before)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
+
+// CreateIdempotent stores copies of the voucher and the idempotency key
+func (r *MemoryVoucherRepository) CreateIdempotent(voucher *models.Voucher, key *models.IdempotencyKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Check the voucher before the key, in the order the SQL repository inserts them
+	for _, existing := range r.vouchers {
+		if existing.FlightNumber == voucher.FlightNumber && existing.FlightDate == voucher.FlightDate {
+			return ErrDuplicate
+		}
+	}
+	if _, ok := r.keys[scopedKey{key.Scope, key.Key}]; ok {
+		return ErrDuplicateKey
+	}
+
+	if err := r.createLocked([]*models.Voucher{voucher}); err != nil {
+		return err
+	}
+
+	key.VoucherID = voucher.ID
+	r.keys[scopedKey{key.Scope, key.Key}] = *key
+	return nil
+}
+
+// GetIdempotencyKey returns a copy of an idempotency key stored in a scope
+func (r *MemoryVoucherRepository) GetIdempotencyKey(scope, key string) (*models.IdempotencyKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.keys[scopedKey{scope, key}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &record, nil
+}
+
+// DeleteIdempotencyKeys removes the idempotency keys created before a
+// timestamp
+func (r *MemoryVoucherRepository) DeleteIdempotencyKeys(before string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for k, record := range r.keys {
+		if record.CreatedAt < before {
+			delete(r.keys, k)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"airline-voucher-backend/models"
+)
+
+// SortField is a column List can order by. Ties are always broken by ID, so
+// every ordering is total and can be paged with a Cursor.
+type SortField string
+
+// Sort fields accepted by ListOptions.Sort
+const (
+	SortByID           SortField = "id"
+	SortByFlightDate   SortField = "flight_date"
+	SortByFlightNumber SortField = "flight_number"
+	SortByCreatedAt    SortField = "created_at"
+)
+
+// ListOptions filters, orders and pages the vouchers returned by List. Zero
+// values mean "no filter"; a zero Limit returns every match.
+type ListOptions struct {
+	DateFrom     string // inclusive, YYYY-MM-DD
+	DateTo       string // inclusive, YYYY-MM-DD
+	FlightPrefix string // case-sensitive prefix of the flight number
+	AircraftType string
+	CrewID       string
+
+	Sort       SortField // defaults to SortByID
+	Descending bool
+	// After continues a previous page: only vouchers ordered after it are returned
+	After *Cursor
+	Limit int
+}
+
+// Cursor marks the last voucher of a page in a keyset ordering
+type Cursor struct {
+	Value string // the voucher's value of the sort field
+	ID    int
+}
+
+// sortField returns the effective sort field
+func (o ListOptions) sortField() SortField {
+	if o.Sort == "" {
+		return SortByID
+	}
+	return o.Sort
+}
+
+// ValidSortField reports whether field is a known sort field
+func ValidSortField(field SortField) bool {
+	switch field {
+	case SortByID, SortByFlightDate, SortByFlightNumber, SortByCreatedAt:
+		return true
+	}
+	return false
+}
+
+// SortValue returns a voucher's value of a sort field, as stored in a Cursor
+func SortValue(voucher *models.Voucher, field SortField) string {
+	switch field {
+	case SortByFlightDate:
+		return voucher.FlightDate
+	case SortByFlightNumber:
+		return voucher.FlightNumber
+	case SortByCreatedAt:
+		return voucher.CreatedAt
+	default:
+		return strconv.Itoa(voucher.ID)
+	}
+}
+
+// matches reports whether a voucher passes the filters of the options
+func (o ListOptions) matches(voucher *models.Voucher) bool {
+	if o.DateFrom != "" && voucher.FlightDate < o.DateFrom {
+		return false
+	}
+	if o.DateTo != "" && voucher.FlightDate > o.DateTo {
+		return false
+	}
+	if o.FlightPrefix != "" && !strings.HasPrefix(voucher.FlightNumber, o.FlightPrefix) {
+		return false
+	}
+	if o.AircraftType != "" && voucher.AircraftType != o.AircraftType {
+		return false
+	}
+	if o.CrewID != "" && voucher.CrewID != o.CrewID {
+		return false
+	}
+	return true
+}
+
+// less reports whether voucher a is ordered before voucher b
+func (o ListOptions) less(a, b *models.Voucher) bool {
+	field := o.sortField()
+	if field != SortByID {
+		va, vb := SortValue(a, field), SortValue(b, field)
+		if va != vb {
+			if o.Descending {
+				return va > vb
+			}
+			return va < vb
+		}
+	}
+	if o.Descending {
+		return a.ID > b.ID
+	}
+	return a.ID < b.ID
+}
+
+// afterCursor reports whether a voucher is ordered after the options' cursor
+func (o ListOptions) afterCursor(voucher *models.Voucher) bool {
+	if o.After == nil {
+		return true
+	}
+	return o.less(&models.Voucher{
+		ID:           o.After.ID,
+		FlightDate:   o.After.Value,
+		FlightNumber: o.After.Value,
+		CreatedAt:    o.After.Value,
+	}, voucher)
+}
+
+// whereClause renders the filters and cursor as a SQL condition with ?
+// placeholders. The cursor is skipped when withCursor is false, e.g. for Count.
+// collate is appended to text comparisons so every engine orders by bytes.
+func (o ListOptions) whereClause(withCursor bool, collate string) (string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	if o.DateFrom != "" {
+		conditions = append(conditions, "flight_date >= ?")
+		args = append(args, o.DateFrom)
+	}
+	if o.DateTo != "" {
+		conditions = append(conditions, "flight_date <= ?")
+		args = append(args, o.DateTo)
+	}
+	if o.FlightPrefix != "" {
+		// substr instead of LIKE: LIKE is case-insensitive in SQLite but not
+		// in PostgreSQL, and the prefix would need escaping. substr counts
+		// characters, not bytes.
+		conditions = append(conditions, "substr(flight_number, 1, ?) = ?")
+		args = append(args, utf8.RuneCountInString(o.FlightPrefix), o.FlightPrefix)
+	}
+	if o.AircraftType != "" {
+		conditions = append(conditions, "aircraft_type = ?")
+		args = append(args, o.AircraftType)
+	}
+	if o.CrewID != "" {
+		conditions = append(conditions, "crew_id = ?")
+		args = append(args, o.CrewID)
+	}
+
+	if withCursor && o.After != nil {
+		op := ">"
+		if o.Descending {
+			op = "<"
+		}
+		field := o.sortField()
+		if field == SortByID {
+			conditions = append(conditions, "id "+op+" ?")
+			args = append(args, o.After.ID)
+		} else {
+			column := string(field) + collate
+			conditions = append(conditions, "("+column+" "+op+" ? OR ("+column+" = ? AND id "+op+" ?))")
+			args = append(args, o.After.Value, o.After.Value, o.After.ID)
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// orderClause renders the ORDER BY clause of the options
+func (o ListOptions) orderClause(collate string) string {
+	direction := "ASC"
+	if o.Descending {
+		direction = "DESC"
+	}
+
+	field := o.sortField()
+	if field == SortByID {
+		return " ORDER BY id " + direction
+	}
+	return " ORDER BY " + string(field) + collate + " " + direction + ", id " + direction
+}
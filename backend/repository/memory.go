@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"airline-voucher-backend/models"
+)
+
+var _ VoucherRepository = (*MemoryVoucherRepository)(nil)
+
+// MemoryVoucherRepository keeps vouchers in memory. It is intended for tests
+// and local experiments; data is lost when the process exits.
+type MemoryVoucherRepository struct {
+	mu       sync.RWMutex
+	nextID   int
+	vouchers map[int]models.Voucher
+	keys     map[scopedKey]models.IdempotencyKey
+
+	nextDrawID int
+	draws      []models.SeatDraw
+
+	occupancy map[flightDate]models.Occupancy
+
+	nextEventID int
+	events      []models.VoucherEvent
+}
+
+// flightDate identifies a flight on one day
+type flightDate struct{ flightNumber, date string }
+
+// scopedKey identifies an idempotency key within the scope of its caller
+type scopedKey struct{ scope, key string }
+
+// NewMemoryVoucherRepository creates an empty in-memory repository
+func NewMemoryVoucherRepository() *MemoryVoucherRepository {
+	return &MemoryVoucherRepository{
+		nextID:   1,
+		vouchers: make(map[int]models.Voucher),
+		keys:     make(map[scopedKey]models.IdempotencyKey),
+
+		occupancy: make(map[flightDate]models.Occupancy),
+	}
+}
+
+// Create stores a copy of the voucher and assigns it the next ID
+func (r *MemoryVoucherRepository) Create(voucher *models.Voucher) error {
+	return r.CreateAll([]*models.Voucher{voucher})
+}
+
+// CreateAll stores copies of the vouchers if none of them is a duplicate
+func (r *MemoryVoucherRepository) CreateAll(vouchers []*models.Voucher) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.createLocked(vouchers)
+}
+
+// createLocked implements CreateAll; the caller must hold the write lock
+func (r *MemoryVoucherRepository) createLocked(vouchers []*models.Voucher) error {
+	taken := make(map[flightDate]bool, len(r.vouchers)+len(vouchers))
+	for _, existing := range r.vouchers {
+		taken[flightDate{existing.FlightNumber, existing.FlightDate}] = true
+	}
+	for i, voucher := range vouchers {
+		key := flightDate{voucher.FlightNumber, voucher.FlightDate}
+		if taken[key] {
+			return &DuplicateError{Index: i}
+		}
+		taken[key] = true
+	}
+	for i, voucher := range vouchers {
+		seen := make(map[string]bool, len(voucher.Seats))
+		for _, seat := range voucher.Seats {
+			if _, _, held := r.seatHolder(voucher.FlightNumber, voucher.FlightDate, seat); held || seen[seat] {
+				return &SeatTakenError{
+					Index: i,
+					Err:   fmt.Errorf("%w: %s on flight %s on %s", ErrSeatTaken, seat, voucher.FlightNumber, voucher.FlightDate),
+				}
+			}
+			seen[seat] = true
+		}
+	}
+
+	for _, voucher := range vouchers {
+		voucher.ID = r.nextID
+		voucher.Version = 1
+		r.nextID++
+		for _, draw := range voucher.Draws {
+			draw.VoucherID = voucher.ID
+			r.recordDraw(draw)
+		}
+		voucher.Commitments = drawCommitments(voucher.Draws)
+		voucher.SeatStates = issuedStates(len(voucher.Seats))
+		for _, event := range voucher.Events {
+			r.recordEvent(voucher.ID, event)
+		}
+		r.vouchers[voucher.ID] = copyVoucher(*voucher)
+	}
+
+	return nil
+}
+
+// GetByFlightDate returns a copy of the voucher for the given flight and date
+func (r *MemoryVoucherRepository) GetByFlightDate(flightNumber, date string) (*models.Voucher, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, voucher := range r.vouchers {
+		if voucher.FlightNumber == flightNumber && voucher.FlightDate == date {
+			found := copyVoucher(voucher)
+			return &found, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// GetByID returns a copy of the voucher with the given ID
+func (r *MemoryVoucherRepository) GetByID(id int) (*models.Voucher, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	voucher, ok := r.vouchers[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	found := copyVoucher(voucher)
+	return &found, nil
+}
+
+// UpdateSeat replaces a single issued seat of a voucher and records its draw
+// and event
+func (r *MemoryVoucherRepository) UpdateSeat(id, version, position int, seat string, draw *models.SeatDraw, event *models.VoucherEvent, limits RegenerationLimits) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	voucher, ok := r.vouchers[id]
+	if !ok || position < 1 || position > len(voucher.Seats) {
+		return ErrNotFound
+	}
+	if version != 0 && voucher.Version != version {
+		return ErrVersionChanged
+	}
+	if voucher.SeatStates[position-1].Status != models.SeatStatusIssued {
+		return ErrStatusChanged
+	}
+	voucherCount, seatCount := 0, 0
+	for _, recorded := range r.events {
+		if recorded.VoucherID == id && recorded.Type == models.EventRegenerate {
+			voucherCount++
+			if recorded.Position == position {
+				seatCount++
+			}
+		}
+	}
+	if err := limits.check(position, voucherCount, seatCount); err != nil {
+		return err
+	}
+	if holder, index, held := r.seatHolder(voucher.FlightNumber, voucher.FlightDate, seat); held && (holder != id || index != position-1) {
+		return fmt.Errorf("%w: %s", ErrSeatTaken, seat)
+	}
+
+	voucher.Seats[position-1] = seat
+	voucher.Version++
+	commitment := models.SeatCommitment{}
+	if draw != nil {
+		draw.VoucherID = id
+		r.recordDraw(draw)
+		commitment = seatCommitment(draw)
+	}
+	if draw != nil || voucher.Commitments != nil {
+		if voucher.Commitments == nil {
+			voucher.Commitments = make([]models.SeatCommitment, len(voucher.Seats))
+		}
+		voucher.Commitments[position-1] = commitment
+	}
+	r.vouchers[id] = voucher
+	r.recordEvent(id, event)
+	return nil
+}
+
+// List returns copies of the vouchers matching the options
+func (r *MemoryVoucherRepository) List(opts ListOptions) ([]models.Voucher, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	vouchers := make([]models.Voucher, 0, len(r.vouchers))
+	for _, voucher := range r.vouchers {
+		if opts.matches(&voucher) && opts.afterCursor(&voucher) {
+			vouchers = append(vouchers, copyVoucher(voucher))
+		}
+	}
+	sort.Slice(vouchers, func(i, j int) bool {
+		return opts.less(&vouchers[i], &vouchers[j])
+	})
+
+	if opts.Limit > 0 && len(vouchers) > opts.Limit {
+		vouchers = vouchers[:opts.Limit]
+	}
+
+	return vouchers, nil
+}
+
+// Count returns how many vouchers match the options' filters
+func (r *MemoryVoucherRepository) Count(opts ListOptions) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, voucher := range r.vouchers {
+		if opts.matches(&voucher) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Delete removes a voucher and records its event; the voucher's draws and
+// events are kept
+func (r *MemoryVoucherRepository) Delete(id, version int, event *models.VoucherEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	voucher, ok := r.vouchers[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if version != 0 && voucher.Version != version {
+		return ErrVersionChanged
+	}
+
+	delete(r.vouchers, id)
+	r.recordEvent(id, event)
+	for key, record := range r.keys {
+		if record.VoucherID == id {
+			delete(r.keys, key)
+		}
+	}
+	return nil
+}
+
+// copyVoucher returns a voucher that shares no slices with the original
+func copyVoucher(voucher models.Voucher) models.Voucher {
+	voucher.Seats = append([]string{}, voucher.Seats...)
+	voucher.SeatStates = append([]models.SeatState{}, voucher.SeatStates...)
+	if voucher.Commitments != nil {
+		voucher.Commitments = append([]models.SeatCommitment{}, voucher.Commitments...)
+	}
+	voucher.Draws = nil
+	return voucher
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"sort"
+
+	"airline-voucher-backend/models"
+)
+
+// SetOccupancy replaces the occupied seats of a flight in one transaction
+func (r *sqlVoucherRepository) SetOccupancy(occupancy *models.Occupancy) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(r.rebind(`DELETE FROM occupied_seats WHERE flight_number = ? AND flight_date = ?`),
+		occupancy.FlightNumber, occupancy.FlightDate)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO occupied_seats (flight_number, flight_date, seat, uploaded_at) VALUES (?, ?, ?, ?)`
+	for _, seat := range occupancy.Seats {
+		if _, err := tx.Exec(r.rebind(query), occupancy.FlightNumber, occupancy.FlightDate, seat, occupancy.UploadedAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetOccupancy loads the occupied seats of a flight in seat order
+func (r *sqlVoucherRepository) GetOccupancy(flightNumber, date string) (*models.Occupancy, error) {
+	query := `
+		SELECT seat, uploaded_at FROM occupied_seats
+		WHERE flight_number = ? AND flight_date = ? ORDER BY seat
+	`
+	rows, err := r.db.Query(r.rebind(query), flightNumber, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	occupancy := &models.Occupancy{FlightNumber: flightNumber, FlightDate: date, Seats: []string{}}
+	for rows.Next() {
+		var seat string
+		if err := rows.Scan(&seat, &occupancy.UploadedAt); err != nil {
+			return nil, err
+		}
+		occupancy.Seats = append(occupancy.Seats, seat)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(occupancy.Seats) == 0 {
+		return nil, ErrNotFound
+	}
+	return occupancy, nil
+}
+
+// SetOccupancy stores a copy of the occupied seats of a flight
+func (r *MemoryVoucherRepository) SetOccupancy(occupancy *models.Occupancy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := flightDate{occupancy.FlightNumber, occupancy.FlightDate}
+	if len(occupancy.Seats) == 0 {
+		delete(r.occupancy, key)
+		return nil
+	}
+
+	stored := *occupancy
+	stored.Seats = append([]string{}, occupancy.Seats...)
+	sort.Strings(stored.Seats)
+	r.occupancy[key] = stored
+	return nil
+}
+
+// GetOccupancy returns a copy of the occupied seats of a flight
+func (r *MemoryVoucherRepository) GetOccupancy(flightNumber, date string) (*models.Occupancy, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.occupancy[flightDate{flightNumber, date}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	stored.Seats = append([]string{}, stored.Seats...)
+	return &stored, nil
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// pgUniqueViolation is the PostgreSQL SQLSTATE for unique_violation
+const pgUniqueViolation = "23505"
+
+var _ VoucherRepository = (*PostgresVoucherRepository)(nil)
+
+// PostgresVoucherRepository stores vouchers in the PostgreSQL schema managed
+// by the migrations package. Uniqueness of flight number and date is enforced
+// by a unique index, so several service replicas can share one database.
+type PostgresVoucherRepository struct {
+	sqlVoucherRepository
+}
+
+// NewPostgresVoucherRepository creates a repository backed by an open PostgreSQL database
+func NewPostgresVoucherRepository(db *sql.DB) *PostgresVoucherRepository {
+	return &PostgresVoucherRepository{
+		sqlVoucherRepository{
+			db: db,
+			dialect: dialect{
+				numberedPlaceholders: true,
+				returningID:          true,
+				binaryCollation:      ` COLLATE "C"`,
+				uniqueViolation:      isPostgresUniqueViolation,
+				rowLock:              " FOR UPDATE",
+			},
+		},
+	}
+}
+
+// isPostgresUniqueViolation reports whether err is a PostgreSQL unique_violation
+func isPostgresUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"airline-voucher-backend/models"
+)
+
+var (
+	// ErrNotFound is returned when no voucher matches the lookup
+	ErrNotFound = errors.New("voucher not found")
+	// ErrDuplicate is returned when a voucher for the same flight and date exists
+	ErrDuplicate = errors.New("duplicate voucher")
+	// ErrDuplicateKey is returned when an idempotency key is already stored
+	ErrDuplicateKey = errors.New("duplicate idempotency key")
+	// ErrSeatTaken is returned when a seat is already assigned to a voucher
+	// for the same flight and date
+	ErrSeatTaken = errors.New("seat already assigned")
+	// ErrStatusChanged is returned when a seat no longer has the status a
+	// state change expects
+	ErrStatusChanged = errors.New("seat status changed")
+	// ErrVersionChanged is returned when a voucher no longer has the
+	// version a write expects
+	ErrVersionChanged = errors.New("voucher version changed")
+	// ErrRegenerationLimit is returned when a voucher or one of its seats
+	// was already redrawn as often as the limits allow
+	ErrRegenerationLimit = errors.New("regeneration limit reached")
+)
+
+// DuplicateError reports which voucher of a CreateAll batch collided with an
+// existing voucher. It matches ErrDuplicate with errors.Is.
+type DuplicateError struct {
+	Index int
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("%s at index %d", ErrDuplicate, e.Index)
+}
+
+func (e *DuplicateError) Unwrap() error {
+	return ErrDuplicate
+}
+
+// SeatTakenError reports which voucher of a CreateAll batch holds a seat that
+// is already assigned on its flight. It matches ErrSeatTaken with errors.Is.
+type SeatTakenError struct {
+	Index int
+	Err   error // wraps ErrSeatTaken and names the seat
+}
+
+func (e *SeatTakenError) Error() string {
+	return fmt.Sprintf("%s at index %d", e.Err, e.Index)
+}
+
+func (e *SeatTakenError) Unwrap() error {
+	return e.Err
+}
+
+// RegenerationLimits caps how many regenerate events UpdateSeat accepts for
+// a voucher in total and for one of its seat positions. Zero leaves the
+// corresponding count unlimited.
+type RegenerationLimits struct {
+	PerVoucher int
+	PerSeat    int
+}
+
+// RegenerationLimitError reports which limit an UpdateSeat call reached. It
+// matches ErrRegenerationLimit with errors.Is.
+type RegenerationLimitError struct {
+	// Position is the seat position whose limit was reached, or 0 when the
+	// voucher's limit was
+	Position int
+	Count    int
+	Limit    int
+}
+
+func (e *RegenerationLimitError) Error() string {
+	if e.Position == 0 {
+		return fmt.Sprintf("%s: voucher redrawn %d times (limit %d)", ErrRegenerationLimit, e.Count, e.Limit)
+	}
+	return fmt.Sprintf("%s: seat %d redrawn %d times (limit %d)", ErrRegenerationLimit, e.Position, e.Count, e.Limit)
+}
+
+func (e *RegenerationLimitError) Unwrap() error {
+	return ErrRegenerationLimit
+}
+
+// check fails with a *RegenerationLimitError when the regenerations already
+// counted for a voucher and one of its positions leave no room for another
+func (l RegenerationLimits) check(position, voucherCount, seatCount int) error {
+	if l.PerVoucher > 0 && voucherCount >= l.PerVoucher {
+		return &RegenerationLimitError{Count: voucherCount, Limit: l.PerVoucher}
+	}
+	if l.PerSeat > 0 && seatCount >= l.PerSeat {
+		return &RegenerationLimitError{Position: position, Count: seatCount, Limit: l.PerSeat}
+	}
+	return nil
+}
+
+// unlimited reports whether the limits allow any number of regenerations
+func (l RegenerationLimits) unlimited() bool {
+	return l.PerVoucher == 0 && l.PerSeat == 0
+}
+
+// VoucherRepository stores vouchers and their seats. Implementations must
+// guarantee at most one voucher per flight number and date, and that no seat
+// of a flight and date is assigned twice, even under concurrent calls.
+type VoucherRepository interface {
+	// Create stores a voucher with its seats and events and sets its ID,
+	// Version and SeatStates; every seat starts issued. It returns
+	// ErrDuplicate when the flight and date already have a voucher, and an
+	// error wrapping ErrSeatTaken when one of its seats is already assigned.
+	Create(voucher *models.Voucher) error
+	// CreateAll stores several vouchers atomically: either all are stored and
+	// their IDs set, or none are. A voucher whose flight and date already
+	// exist, in the store or earlier in the batch, fails the whole batch
+	// with a *DuplicateError, and a voucher holding an assigned seat with a
+	// *SeatTakenError.
+	CreateAll(vouchers []*models.Voucher) error
+	// CreateIdempotent stores the voucher like Create and, in the same
+	// transaction, the idempotency key of the request that generated it. The
+	// key's VoucherID is set to the new voucher's ID. A key already stored
+	// in the same scope fails with ErrDuplicateKey and a stored flight and
+	// date with ErrDuplicate.
+	CreateIdempotent(voucher *models.Voucher, key *models.IdempotencyKey) error
+	// GetIdempotencyKey returns the idempotency key stored in a scope, or
+	// ErrNotFound
+	GetIdempotencyKey(scope, key string) (*models.IdempotencyKey, error)
+	// DeleteIdempotencyKeys removes the idempotency keys created before a
+	// timestamp, in every scope, and returns how many were removed
+	DeleteIdempotencyKeys(before string) (int, error)
+	// GetByFlightDate returns the voucher for a flight and date, or ErrNotFound
+	GetByFlightDate(flightNumber, date string) (*models.Voucher, error)
+	// GetByID returns the voucher with the given ID, or ErrNotFound
+	GetByID(id int) (*models.Voucher, error)
+	// UpdateSeat replaces the seat at a 1-based position of a voucher and
+	// records the draw that produced it and the event, if any, in the same
+	// transaction. Only issued seats are replaced. The voucher's regenerate
+	// events are counted against limits in the same transaction, so
+	// concurrent redraws cannot exceed them. A non-zero version must be the
+	// voucher's current version, which the change bumps. It returns
+	// ErrNotFound when the voucher or position does not exist,
+	// ErrVersionChanged when the voucher has another version,
+	// ErrStatusChanged when the seat is no longer issued, a
+	// *RegenerationLimitError when a limit is reached, and an error wrapping
+	// ErrSeatTaken when another position of the flight already has the seat.
+	UpdateSeat(id, version, position int, seat string, draw *models.SeatDraw, event *models.VoucherEvent, limits RegenerationLimits) error
+	// SetSeatState replaces the state of the seat at a 1-based position of a
+	// voucher if the seat's current status is from, and records the event,
+	// if any, in the same transaction. Like UpdateSeat, a non-zero version
+	// must be current and the change bumps it. It returns ErrNotFound when
+	// the voucher or position does not exist, ErrVersionChanged when the
+	// voucher has another version and ErrStatusChanged when the seat has
+	// another status.
+	SetSeatState(id, version, position int, from string, state models.SeatState, event *models.VoucherEvent) error
+	// AssignedSeats returns the seats of a flight and date held by any
+	// voucher, sorted
+	AssignedSeats(flightNumber, date string) ([]string, error)
+	// ListDraws returns the recorded draws of a voucher, oldest first. Draws
+	// are kept when the voucher is deleted, so disputed draws can be replayed.
+	ListDraws(voucherID int) ([]models.SeatDraw, error)
+	// ListEvents returns the recorded events of a voucher, oldest first.
+	// Events are never changed or removed, so they outlive the voucher.
+	ListEvents(voucherID int) ([]models.VoucherEvent, error)
+	// List returns the vouchers matching the options, in their order
+	List(opts ListOptions) ([]models.Voucher, error)
+	// Count returns how many vouchers match the options' filters, ignoring
+	// the cursor and limit
+	Count(opts ListOptions) (int, error)
+	// Delete removes a voucher and its seats and records the event, if any,
+	// in the same transaction. A non-zero version must be the voucher's
+	// current version. It returns ErrNotFound when the voucher does not
+	// exist and ErrVersionChanged when it has another version.
+	Delete(id, version int, event *models.VoucherEvent) error
+	// SetOccupancy replaces the occupied seats of a flight and date; an
+	// empty seat list clears them
+	SetOccupancy(occupancy *models.Occupancy) error
+	// GetOccupancy returns the occupied seats of a flight and date, sorted,
+	// or ErrNotFound when none are stored
+	GetOccupancy(flightNumber, date string) (*models.Occupancy, error)
+}
+
+// NewSQLVoucherRepository creates the repository for an open database of the
+// given database/sql driver
+func NewSQLVoucherRepository(driver string, db *sql.DB) (VoucherRepository, error) {
+	switch driver {
+	case "sqlite3":
+		return NewSQLiteVoucherRepository(db), nil
+	case "postgres":
+		return NewPostgresVoucherRepository(db), nil
+	default:
+		return nil, fmt.Errorf("no voucher repository for database driver %q", driver)
+	}
+}
@@ -0,0 +1,837 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"airline-voucher-backend/config"
+	"airline-voucher-backend/models"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// repositoryFactories lists every implementation that must satisfy the
+// VoucherRepository contract
+var repositoryFactories = map[string]func(t *testing.T) VoucherRepository{
+	"memory": func(t *testing.T) VoucherRepository {
+		return NewMemoryVoucherRepository()
+	},
+	"sqlite": func(t *testing.T) VoucherRepository {
+		db, err := config.InitDB(filepath.Join(t.TempDir(), "vouchers.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		return NewSQLiteVoucherRepository(db)
+	},
+	"postgres": func(t *testing.T) VoucherRepository {
+		dsn := os.Getenv("TEST_POSTGRES_DSN")
+		if dsn == "" {
+			t.Skip("TEST_POSTGRES_DSN not set (see make test-postgres)")
+		}
+		db, err := config.InitDatabase(config.DriverPostgres, postgresTestSchema(t, dsn))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		return NewPostgresVoucherRepository(db)
+	},
+}
+
+// postgresTestSchema creates an empty schema for one test, dropped on
+// cleanup, and returns a DSN that uses it
+func postgresTestSchema(t *testing.T, dsn string) string {
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+
+	admin, err := sql.Open(config.DriverPostgres, dsn)
+	require.NoError(t, err)
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		require.NoError(t, err)
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+	return dsn + " search_path=" + schema
+}
+
+// runContract runs a test against every repository implementation
+func runContract(t *testing.T, test func(t *testing.T, repo VoucherRepository)) {
+	for name, factory := range repositoryFactories {
+		t.Run(name, func(t *testing.T) {
+			test(t, factory(t))
+		})
+	}
+}
+
+func newVoucher(flightNumber, date string, seats ...string) *models.Voucher {
+	return &models.Voucher{
+		CrewName:     "Sarah",
+		CrewID:       "98123",
+		FlightNumber: flightNumber,
+		FlightDate:   date,
+		AircraftType: "Airbus 320",
+		CabinClass:   "economy",
+		CreatedAt:    "2025-07-01 10:00:00",
+		Seats:        seats,
+	}
+}
+
+func TestVoucherRepository_CreateAndGet(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		voucher := newVoucher("GA102", "2025-07-12", "4A", "10C", "21F", "30B")
+		require.NoError(t, repo.Create(voucher))
+		assert.NotZero(t, voucher.ID)
+
+		found, err := repo.GetByFlightDate("GA102", "2025-07-12")
+		require.NoError(t, err)
+		assert.Equal(t, *voucher, *found)
+
+		_, err = repo.GetByFlightDate("GA102", "2025-07-13")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		found, err = repo.GetByID(voucher.ID)
+		require.NoError(t, err)
+		assert.Equal(t, *voucher, *found)
+
+		_, err = repo.GetByID(voucher.ID + 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestVoucherRepository_CreateDuplicate(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		require.NoError(t, repo.Create(newVoucher("GA102", "2025-07-12", "4A")))
+
+		err := repo.Create(newVoucher("GA102", "2025-07-12", "5A"))
+		assert.ErrorIs(t, err, ErrDuplicate)
+
+		// The first voucher is untouched
+		found, err := repo.GetByFlightDate("GA102", "2025-07-12")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"4A"}, found.Seats)
+	})
+}
+
+func TestVoucherRepository_CreateAll(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		first := newVoucher("GA102", "2025-07-12", "4A")
+		second := newVoucher("GA103", "2025-07-12", "5B", "6C")
+		require.NoError(t, repo.CreateAll([]*models.Voucher{first, second}))
+		assert.NotZero(t, first.ID)
+		assert.NotZero(t, second.ID)
+		assert.NotEqual(t, first.ID, second.ID)
+
+		found, err := repo.GetByFlightDate("GA103", "2025-07-12")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"5B", "6C"}, found.Seats)
+
+		// A duplicate of a stored voucher rolls back the whole batch
+		err = repo.CreateAll([]*models.Voucher{
+			newVoucher("GA104", "2025-07-12", "7A"),
+			newVoucher("GA102", "2025-07-12", "8A"),
+		})
+		var dupErr *DuplicateError
+		require.ErrorAs(t, err, &dupErr)
+		assert.Equal(t, 1, dupErr.Index)
+		assert.ErrorIs(t, err, ErrDuplicate)
+
+		_, err = repo.GetByFlightDate("GA104", "2025-07-12")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// So does a duplicate within the batch
+		err = repo.CreateAll([]*models.Voucher{
+			newVoucher("GA105", "2025-07-12", "7A"),
+			newVoucher("GA105", "2025-07-12", "8A"),
+		})
+		require.ErrorAs(t, err, &dupErr)
+		assert.Equal(t, 1, dupErr.Index)
+
+		// And a voucher holding a seat twice
+		err = repo.CreateAll([]*models.Voucher{
+			newVoucher("GA106", "2025-07-12", "7A"),
+			newVoucher("GA107", "2025-07-12", "8A", "8A"),
+		})
+		var seatErr *SeatTakenError
+		require.ErrorAs(t, err, &seatErr)
+		assert.Equal(t, 1, seatErr.Index)
+		assert.ErrorIs(t, err, ErrSeatTaken)
+
+		count, err := repo.Count(ListOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+}
+
+func TestVoucherRepository_IdempotencyKeys(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		_, err := repo.GetIdempotencyKey("crew:98123", "key-1")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		voucher := newVoucher("GA102", "2025-07-12", "4A")
+		key := &models.IdempotencyKey{
+			Scope:       "crew:98123",
+			Key:         "key-1",
+			RequestHash: "abc123",
+			Response:    `{"success":true,"seats":["4A"]}`,
+			CreatedAt:   "2025-07-01 10:00:00",
+		}
+		require.NoError(t, repo.CreateIdempotent(voucher, key))
+		assert.NotZero(t, voucher.ID)
+		assert.Equal(t, voucher.ID, key.VoucherID)
+
+		found, err := repo.GetIdempotencyKey("crew:98123", "key-1")
+		require.NoError(t, err)
+		assert.Equal(t, *key, *found)
+
+		// The flight is checked before the key
+		err = repo.CreateIdempotent(newVoucher("GA102", "2025-07-12", "5A"), &models.IdempotencyKey{Scope: "crew:98123", Key: "key-1"})
+		assert.ErrorIs(t, err, ErrDuplicate)
+
+		// A reused key stores nothing
+		err = repo.CreateIdempotent(newVoucher("GA103", "2025-07-12", "5A"), &models.IdempotencyKey{Scope: "crew:98123", Key: "key-1", CreatedAt: "2025-07-01 10:00:00"})
+		assert.ErrorIs(t, err, ErrDuplicateKey)
+		_, err = repo.GetByFlightDate("GA103", "2025-07-12")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// Another caller has keys of its own
+		_, err = repo.GetIdempotencyKey("crew:77001", "key-1")
+		assert.ErrorIs(t, err, ErrNotFound)
+		other := &models.IdempotencyKey{Scope: "crew:77001", Key: "key-1", RequestHash: "def456", CreatedAt: "2025-07-02 10:00:00"}
+		require.NoError(t, repo.CreateIdempotent(newVoucher("GA104", "2025-07-12", "6A"), other))
+		found, err = repo.GetIdempotencyKey("crew:77001", "key-1")
+		require.NoError(t, err)
+		assert.Equal(t, "def456", found.RequestHash)
+
+		// Deleting the voucher releases its key
+		require.NoError(t, repo.Delete(voucher.ID, 0, nil))
+		_, err = repo.GetIdempotencyKey("crew:98123", "key-1")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestVoucherRepository_DeleteIdempotencyKeys(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		for i, createdAt := range []string{"2025-07-01 10:00:00", "2025-07-02 10:00:00", "2025-07-03 10:00:00"} {
+			key := &models.IdempotencyKey{Scope: "crew:98123", Key: fmt.Sprintf("key-%d", i), CreatedAt: createdAt}
+			require.NoError(t, repo.CreateIdempotent(newVoucher(fmt.Sprintf("GA%d", 100+i), "2025-07-12", "4A"), key))
+		}
+
+		deleted, err := repo.DeleteIdempotencyKeys("2025-07-02 10:00:00")
+		require.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+
+		_, err = repo.GetIdempotencyKey("crew:98123", "key-0")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = repo.GetIdempotencyKey("crew:98123", "key-1")
+		assert.NoError(t, err)
+
+		// The vouchers stay
+		count, err := repo.Count(ListOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+	})
+}
+
+func TestVoucherRepository_Draws(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		voucher := newVoucher("GA102", "2025-07-12", "4A", "10C")
+		voucher.Draws = []*models.SeatDraw{{
+			Algorithm:  "test/v1",
+			Seed:       "00ff",
+			Commitment: "c1",
+			Pool:       []string{"10C", "4A", "5B"},
+			Seats:      []string{"4A", "10C"},
+			CreatedAt:  "2025-07-01 10:00:00",
+		}}
+		require.NoError(t, repo.Create(voucher))
+		initialDraw := voucher.Draws[0]
+		require.NotZero(t, initialDraw.ID)
+		assert.Equal(t, voucher.ID, initialDraw.VoucherID)
+
+		initial := models.SeatCommitment{DrawID: initialDraw.ID, Algorithm: "test/v1", Commitment: "c1"}
+		assert.Equal(t, []models.SeatCommitment{initial, initial}, voucher.Commitments)
+
+		redraw := &models.SeatDraw{
+			Algorithm:  "test/v1",
+			Seed:       "0a0b",
+			Commitment: "c2",
+			Pool:       []string{"5B", "10C"},
+			Seats:      []string{"5B"},
+			CreatedAt:  "2025-07-01 11:00:00",
+		}
+		require.NoError(t, repo.UpdateSeat(voucher.ID, 0, 1, "5B", redraw, nil, RegenerationLimits{}))
+
+		found, err := repo.GetByID(voucher.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"5B", "10C"}, found.Seats)
+		assert.Equal(t, []models.SeatCommitment{
+			{DrawID: redraw.ID, Algorithm: "test/v1", Commitment: "c2"},
+			initial,
+		}, found.Commitments)
+		assert.Nil(t, found.Draws)
+
+		draws, err := repo.ListDraws(voucher.ID)
+		require.NoError(t, err)
+		require.Len(t, draws, 2)
+		assert.Equal(t, *initialDraw, draws[0])
+		assert.Equal(t, *redraw, draws[1])
+
+		// A seat replaced without a draw loses its commitment
+		require.NoError(t, repo.UpdateSeat(voucher.ID, 0, 2, "6C", nil, nil, RegenerationLimits{}))
+		found, err = repo.GetByID(voucher.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.SeatCommitment{}, found.Commitments[1])
+
+		// The draws outlive the voucher
+		require.NoError(t, repo.Delete(voucher.ID, 0, nil))
+		draws, err = repo.ListDraws(voucher.ID)
+		require.NoError(t, err)
+		assert.Len(t, draws, 2)
+	})
+}
+
+func TestVoucherRepository_MultipleDraws(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		// A seat mix draws the window seat and the rest separately
+		voucher := newVoucher("GA102", "2025-07-12", "4A", "10C", "7D")
+		voucher.Draws = []*models.SeatDraw{
+			{Algorithm: "test/v1", Seed: "01", Commitment: "w", Pool: []string{"4A", "9F"}, Seats: []string{"4A"}, CreatedAt: "2025-07-01 10:00:00"},
+			{Algorithm: "test/v1", Seed: "02", Commitment: "r", Pool: []string{"10C", "7D", "9F"}, Seats: []string{"10C", "7D"}, CreatedAt: "2025-07-01 10:00:00"},
+		}
+		require.NoError(t, repo.Create(voucher))
+
+		window := models.SeatCommitment{DrawID: voucher.Draws[0].ID, Algorithm: "test/v1", Commitment: "w"}
+		rest := models.SeatCommitment{DrawID: voucher.Draws[1].ID, Algorithm: "test/v1", Commitment: "r"}
+		assert.NotEqual(t, window.DrawID, rest.DrawID)
+		assert.Equal(t, []models.SeatCommitment{window, rest, rest}, voucher.Commitments)
+
+		found, err := repo.GetByID(voucher.ID)
+		require.NoError(t, err)
+		assert.Equal(t, voucher.Commitments, found.Commitments)
+
+		draws, err := repo.ListDraws(voucher.ID)
+		require.NoError(t, err)
+		assert.Len(t, draws, 2)
+	})
+}
+
+func TestVoucherRepository_Occupancy(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		_, err := repo.GetOccupancy("GA102", "2025-07-12")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		require.NoError(t, repo.SetOccupancy(&models.Occupancy{
+			FlightNumber: "GA102",
+			FlightDate:   "2025-07-12",
+			Seats:        []string{"4A", "12C", "1F"},
+			UploadedAt:   "2025-07-01 10:00:00",
+		}))
+		require.NoError(t, repo.SetOccupancy(&models.Occupancy{
+			FlightNumber: "GA102",
+			FlightDate:   "2025-07-13",
+			Seats:        []string{"2B"},
+			UploadedAt:   "2025-07-01 10:00:00",
+		}))
+
+		occupancy, err := repo.GetOccupancy("GA102", "2025-07-12")
+		require.NoError(t, err)
+		assert.Equal(t, &models.Occupancy{
+			FlightNumber: "GA102",
+			FlightDate:   "2025-07-12",
+			Seats:        []string{"12C", "1F", "4A"},
+			UploadedAt:   "2025-07-01 10:00:00",
+		}, occupancy)
+
+		// A new upload replaces the seats of that flight and date only
+		require.NoError(t, repo.SetOccupancy(&models.Occupancy{
+			FlightNumber: "GA102",
+			FlightDate:   "2025-07-12",
+			Seats:        []string{"5D"},
+			UploadedAt:   "2025-07-02 10:00:00",
+		}))
+		occupancy, err = repo.GetOccupancy("GA102", "2025-07-12")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"5D"}, occupancy.Seats)
+		assert.Equal(t, "2025-07-02 10:00:00", occupancy.UploadedAt)
+
+		require.NoError(t, repo.SetOccupancy(&models.Occupancy{FlightNumber: "GA102", FlightDate: "2025-07-12"}))
+		_, err = repo.GetOccupancy("GA102", "2025-07-12")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		occupancy, err = repo.GetOccupancy("GA102", "2025-07-13")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2B"}, occupancy.Seats)
+	})
+}
+
+func TestVoucherRepository_SeatAssignments(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		voucher := newVoucher("GA102", "2025-07-12", "4A", "10C", "7D")
+		require.NoError(t, repo.Create(voucher))
+		require.NoError(t, repo.Create(newVoucher("GA102", "2025-07-13", "4A")), "the same seat on another date is free")
+
+		seats, err := repo.AssignedSeats("GA102", "2025-07-12")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"10C", "4A", "7D"}, seats)
+
+		seats, err = repo.AssignedSeats("GA999", "2025-07-12")
+		require.NoError(t, err)
+		assert.Empty(t, seats)
+
+		// A seat held by another position can't be assigned again
+		err = repo.UpdateSeat(voucher.ID, 0, 1, "10C", nil, nil, RegenerationLimits{})
+		assert.ErrorIs(t, err, ErrSeatTaken)
+
+		// Redrawing a position onto its own seat is fine
+		require.NoError(t, repo.UpdateSeat(voucher.ID, 0, 2, "10C", nil, nil, RegenerationLimits{}))
+		require.NoError(t, repo.UpdateSeat(voucher.ID, 0, 1, "5B", nil, nil, RegenerationLimits{}))
+
+		found, err := repo.GetByID(voucher.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"5B", "10C", "7D"}, found.Seats)
+
+		// A voucher can't hold the same seat twice
+		err = repo.Create(newVoucher("GA103", "2025-07-12", "4A", "4A"))
+		assert.ErrorIs(t, err, ErrSeatTaken)
+		_, err = repo.GetByFlightDate("GA103", "2025-07-12")
+		assert.ErrorIs(t, err, ErrNotFound, "the failed create is rolled back")
+	})
+}
+
+func TestVoucherRepository_SeatStates(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		voucher := newVoucher("GA102", "2025-07-12", "4A", "10C", "7D")
+		require.NoError(t, repo.Create(voucher))
+		issued := models.SeatState{Status: models.SeatStatusIssued}
+		assert.Equal(t, []models.SeatState{issued, issued, issued}, voucher.SeatStates)
+
+		redeemed := models.SeatState{Status: models.SeatStatusRedeemed, RedeemedBy: "98123", RedeemedAt: "2025-07-12 09:30:00"}
+		require.NoError(t, repo.SetSeatState(voucher.ID, 0, 2, models.SeatStatusIssued, redeemed, nil))
+
+		found, err := repo.GetByID(voucher.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []models.SeatState{issued, redeemed, issued}, found.SeatStates)
+
+		// A second transition from issued loses the race
+		err = repo.SetSeatState(voucher.ID, 0, 2, models.SeatStatusIssued, models.SeatState{Status: models.SeatStatusVoided}, nil)
+		assert.ErrorIs(t, err, ErrStatusChanged)
+
+		// A redeemed seat cannot be redrawn, even by a redraw that read it
+		// while it was still issued
+		err = repo.UpdateSeat(voucher.ID, 0, 2, "5B", nil, &models.VoucherEvent{Type: models.EventRegenerate, CreatedAt: "2025-07-12 09:31:00"}, RegenerationLimits{})
+		assert.ErrorIs(t, err, ErrStatusChanged)
+		found, err = repo.GetByID(voucher.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"4A", "10C", "7D"}, found.Seats)
+		assert.Equal(t, redeemed, found.SeatStates[1])
+		events, err := repo.ListEvents(voucher.ID)
+		require.NoError(t, err)
+		assert.Empty(t, events, "a failed redraw records nothing")
+
+		// Redrawing an issued seat keeps its state
+		require.NoError(t, repo.UpdateSeat(voucher.ID, 0, 3, "5B", nil, nil, RegenerationLimits{}))
+		found, err = repo.GetByID(voucher.ID)
+		require.NoError(t, err)
+		assert.Equal(t, issued, found.SeatStates[2])
+
+		for _, position := range []int{0, 4} {
+			err = repo.SetSeatState(voucher.ID, 0, position, models.SeatStatusIssued, redeemed, nil)
+			assert.ErrorIs(t, err, ErrNotFound, "position %d", position)
+		}
+		err = repo.SetSeatState(voucher.ID+1, 0, 1, models.SeatStatusIssued, redeemed, nil)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestVoucherRepository_Events(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		voucher := newVoucher("GA102", "2025-07-12", "4A", "10C")
+		voucher.Events = []*models.VoucherEvent{
+			{Type: models.EventCreate, ActorID: "98123", NewValue: "4A,10C", RequestID: "req-1", CreatedAt: "2025-07-01 10:00:00"},
+		}
+		require.NoError(t, repo.Create(voucher))
+		assert.Equal(t, voucher.ID, voucher.Events[0].VoucherID)
+		assert.NotZero(t, voucher.Events[0].ID)
+
+		regenerate := &models.VoucherEvent{Type: models.EventRegenerate, Position: 1, OldValue: "4A", NewValue: "5B", Reason: "accessibility", CreatedAt: "2025-07-01 10:01:00"}
+		require.NoError(t, repo.UpdateSeat(voucher.ID, 0, 1, "5B", nil, regenerate, RegenerationLimits{}))
+
+		// A failed change records nothing
+		err := repo.UpdateSeat(voucher.ID, 0, 1, "10C", nil, &models.VoucherEvent{Type: models.EventRegenerate, CreatedAt: "2025-07-01 10:02:00"}, RegenerationLimits{})
+		assert.ErrorIs(t, err, ErrSeatTaken)
+		err = repo.SetSeatState(voucher.ID, 0, 3, models.SeatStatusIssued, models.SeatState{Status: models.SeatStatusVoided},
+			&models.VoucherEvent{Type: models.EventVoid, CreatedAt: "2025-07-01 10:02:00"})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		void := &models.VoucherEvent{Type: models.EventVoid, Position: 2, OldValue: "issued", NewValue: "voided", CreatedAt: "2025-07-01 10:03:00"}
+		require.NoError(t, repo.SetSeatState(voucher.ID, 0, 2, models.SeatStatusIssued, models.SeatState{Status: models.SeatStatusVoided}, void))
+		require.NoError(t, repo.Delete(voucher.ID, 0, &models.VoucherEvent{Type: models.EventDelete, OldValue: "5B,10C", CreatedAt: "2025-07-01 10:04:00"}))
+
+		// The history outlives the voucher
+		events, err := repo.ListEvents(voucher.ID)
+		require.NoError(t, err)
+		require.Len(t, events, 4)
+		var types []string
+		for _, event := range events {
+			assert.Equal(t, voucher.ID, event.VoucherID)
+			types = append(types, event.Type)
+		}
+		assert.Equal(t, []string{models.EventCreate, models.EventRegenerate, models.EventVoid, models.EventDelete}, types)
+		assert.Equal(t, *voucher.Events[0], events[0])
+		assert.Equal(t, *regenerate, events[1])
+
+		events, err = repo.ListEvents(voucher.ID + 1)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+}
+
+func TestVoucherRepository_ConcurrentCreate(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		const workers = 10
+		errs := make([]error, workers)
+
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = repo.Create(newVoucher("GA102", "2025-07-12", fmt.Sprintf("%dA", i+4)))
+			}(i)
+		}
+		wg.Wait()
+
+		created := 0
+		for _, err := range errs {
+			if err == nil {
+				created++
+			} else {
+				assert.ErrorIs(t, err, ErrDuplicate)
+			}
+		}
+		assert.Equal(t, 1, created)
+	})
+}
+
+func TestVoucherRepository_UpdateSeat(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		voucher := newVoucher("GA102", "2025-07-12", "4A", "10C", "21F")
+		require.NoError(t, repo.Create(voucher))
+
+		require.NoError(t, repo.UpdateSeat(voucher.ID, 0, 2, "11D", nil, nil, RegenerationLimits{}))
+
+		found, err := repo.GetByFlightDate("GA102", "2025-07-12")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"4A", "11D", "21F"}, found.Seats)
+
+		assert.ErrorIs(t, repo.UpdateSeat(voucher.ID, 0, 4, "12A", nil, nil, RegenerationLimits{}), ErrNotFound)
+		assert.ErrorIs(t, repo.UpdateSeat(voucher.ID+100, 0, 1, "12A", nil, nil, RegenerationLimits{}), ErrNotFound)
+	})
+}
+
+func TestVoucherRepository_RegenerationLimits(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		voucher := newVoucher("GA102", "2025-07-12", "4A", "10C", "21F")
+		require.NoError(t, repo.Create(voucher))
+		limits := RegenerationLimits{PerVoucher: 3, PerSeat: 2}
+		regenerate := func(position int) *models.VoucherEvent {
+			return &models.VoucherEvent{Type: models.EventRegenerate, Position: position, CreatedAt: "2025-07-01 10:01:00"}
+		}
+
+		// Redraws of other types and vouchers do not count
+		require.NoError(t, repo.UpdateSeat(voucher.ID, 0, 1, "5A", nil, nil, limits))
+		require.NoError(t, repo.UpdateSeat(voucher.ID, 0, 1, "6A", nil, regenerate(1), limits))
+		require.NoError(t, repo.UpdateSeat(voucher.ID, 0, 1, "7A", nil, regenerate(1), limits))
+
+		var limitErr *RegenerationLimitError
+		err := repo.UpdateSeat(voucher.ID, 0, 1, "8A", nil, regenerate(1), limits)
+		require.ErrorAs(t, err, &limitErr)
+		assert.ErrorIs(t, err, ErrRegenerationLimit)
+		assert.Equal(t, RegenerationLimitError{Position: 1, Count: 2, Limit: 2}, *limitErr)
+
+		require.NoError(t, repo.UpdateSeat(voucher.ID, 0, 2, "11C", nil, regenerate(2), limits))
+		err = repo.UpdateSeat(voucher.ID, 0, 3, "22F", nil, regenerate(3), limits)
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, RegenerationLimitError{Count: 3, Limit: 3}, *limitErr)
+
+		found, err := repo.GetByID(voucher.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"7A", "11C", "21F"}, found.Seats)
+		events, err := repo.ListEvents(voucher.ID)
+		require.NoError(t, err)
+		assert.Len(t, events, 3, "refused redraws record nothing")
+
+		assert.ErrorIs(t, repo.UpdateSeat(voucher.ID+100, 0, 1, "12A", nil, regenerate(1), limits), ErrNotFound)
+	})
+}
+
+func TestVoucherRepository_ConcurrentRegenerations(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		voucher := newVoucher("GA102", "2025-07-12", "4A")
+		require.NoError(t, repo.Create(voucher))
+
+		const workers = 10
+		errs := make([]error, workers)
+
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				event := &models.VoucherEvent{Type: models.EventRegenerate, Position: 1, CreatedAt: "2025-07-01 10:01:00"}
+				errs[i] = repo.UpdateSeat(voucher.ID, 0, 1, fmt.Sprintf("%dB", i+5), nil, event, RegenerationLimits{PerSeat: 3})
+			}(i)
+		}
+		wg.Wait()
+
+		updated := 0
+		for _, err := range errs {
+			if err == nil {
+				updated++
+			} else {
+				assert.ErrorIs(t, err, ErrRegenerationLimit)
+			}
+		}
+		assert.Equal(t, 3, updated)
+
+		events, err := repo.ListEvents(voucher.ID)
+		require.NoError(t, err)
+		assert.Len(t, events, 3)
+	})
+}
+
+func TestVoucherRepository_ListAndDelete(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		vouchers, err := repo.List(ListOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, vouchers)
+
+		first := newVoucher("GA102", "2025-07-12", "4A")
+		second := newVoucher("GA103", "2025-07-12", "5B", "6C")
+		require.NoError(t, repo.Create(first))
+		require.NoError(t, repo.Create(second))
+
+		vouchers, err = repo.List(ListOptions{})
+		require.NoError(t, err)
+		require.Len(t, vouchers, 2)
+		assert.Equal(t, *first, vouchers[0])
+		assert.Equal(t, *second, vouchers[1])
+
+		require.NoError(t, repo.Delete(first.ID, 0, nil))
+		assert.ErrorIs(t, repo.Delete(first.ID, 0, nil), ErrNotFound)
+
+		_, err = repo.GetByFlightDate("GA102", "2025-07-12")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// The flight and date can be reused once the voucher is gone
+		require.NoError(t, repo.Create(newVoucher("GA102", "2025-07-12", "7D")))
+	})
+}
+
+func TestVoucherRepository_Versions(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		voucher := newVoucher("GA102", "2025-07-12", "4A", "10C", "7D")
+		require.NoError(t, repo.Create(voucher))
+		assert.Equal(t, 1, voucher.Version)
+
+		// Every change bumps the version, with or without a precondition
+		require.NoError(t, repo.UpdateSeat(voucher.ID, 1, 1, "5B", nil, nil, RegenerationLimits{}))
+		require.NoError(t, repo.SetSeatState(voucher.ID, 0, 2, models.SeatStatusIssued, models.SeatState{Status: models.SeatStatusVoided}, nil))
+		found, err := repo.GetByID(voucher.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, found.Version)
+
+		// A write expecting an older version changes nothing
+		err = repo.UpdateSeat(voucher.ID, 2, 1, "6B", nil, &models.VoucherEvent{Type: models.EventRegenerate, CreatedAt: "2025-07-12 09:30:00"}, RegenerationLimits{})
+		assert.ErrorIs(t, err, ErrVersionChanged)
+		err = repo.SetSeatState(voucher.ID, 1, 3, models.SeatStatusIssued, models.SeatState{Status: models.SeatStatusVoided}, nil)
+		assert.ErrorIs(t, err, ErrVersionChanged)
+		assert.ErrorIs(t, repo.Delete(voucher.ID, 2, nil), ErrVersionChanged)
+
+		found, err = repo.GetByID(voucher.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, found.Version)
+		assert.Equal(t, []string{"5B", "10C", "7D"}, found.Seats)
+		assert.Equal(t, models.SeatStatusIssued, found.SeatStates[2].Status)
+		events, err := repo.ListEvents(voucher.ID)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+
+		// A missing voucher is still reported as missing
+		assert.ErrorIs(t, repo.UpdateSeat(voucher.ID+100, 3, 1, "6B", nil, nil, RegenerationLimits{}), ErrNotFound)
+		assert.ErrorIs(t, repo.Delete(voucher.ID+100, 3, nil), ErrNotFound)
+
+		require.NoError(t, repo.Delete(voucher.ID, 3, nil))
+	})
+}
+
+// seedListVouchers creates vouchers with varied flights, dates, aircraft and crew
+func seedListVouchers(t *testing.T, repo VoucherRepository) []*models.Voucher {
+	vouchers := []*models.Voucher{
+		newVoucher("GA102", "2025-07-12", "4A"),
+		newVoucher("GA103", "2025-07-11", "5B"),
+		newVoucher("QZ200", "2025-07-12", "6C"),
+		newVoucher("GA102", "2025-07-13", "7D"),
+		newVoucher("ga104", "2025-07-12", "8E"),
+	}
+	vouchers[2].AircraftType = "ATR"
+	vouchers[3].CrewID = "11111"
+	for _, voucher := range vouchers {
+		require.NoError(t, repo.Create(voucher))
+	}
+	return vouchers
+}
+
+func flightsOf(vouchers []models.Voucher) []string {
+	flights := make([]string, len(vouchers))
+	for i, voucher := range vouchers {
+		flights[i] = voucher.FlightNumber + "@" + voucher.FlightDate
+	}
+	return flights
+}
+
+func TestVoucherRepository_ListFilters(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		seedListVouchers(t, repo)
+
+		tests := []struct {
+			name string
+			opts ListOptions
+			want []string
+		}{
+			{"all", ListOptions{}, []string{"GA102@2025-07-12", "GA103@2025-07-11", "QZ200@2025-07-12", "GA102@2025-07-13", "ga104@2025-07-12"}},
+			{"date range", ListOptions{DateFrom: "2025-07-12", DateTo: "2025-07-12"}, []string{"GA102@2025-07-12", "QZ200@2025-07-12", "ga104@2025-07-12"}},
+			{"flight prefix is case-sensitive", ListOptions{FlightPrefix: "GA"}, []string{"GA102@2025-07-12", "GA103@2025-07-11", "GA102@2025-07-13"}},
+			{"aircraft", ListOptions{AircraftType: "ATR"}, []string{"QZ200@2025-07-12"}},
+			{"crew", ListOptions{CrewID: "11111"}, []string{"GA102@2025-07-13"}},
+			{"combined", ListOptions{FlightPrefix: "GA10", DateFrom: "2025-07-12"}, []string{"GA102@2025-07-12", "GA102@2025-07-13"}},
+			{"sort by date then id", ListOptions{Sort: SortByFlightDate}, []string{"GA103@2025-07-11", "GA102@2025-07-12", "QZ200@2025-07-12", "ga104@2025-07-12", "GA102@2025-07-13"}},
+			{"sort descending", ListOptions{Sort: SortByFlightNumber, Descending: true}, []string{"ga104@2025-07-12", "QZ200@2025-07-12", "GA103@2025-07-11", "GA102@2025-07-13", "GA102@2025-07-12"}},
+			{"limit", ListOptions{Limit: 2}, []string{"GA102@2025-07-12", "GA103@2025-07-11"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				vouchers, err := repo.List(tt.opts)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, flightsOf(vouchers))
+
+				count, err := repo.Count(tt.opts)
+				require.NoError(t, err)
+				if tt.opts.Limit == 0 {
+					assert.Equal(t, len(tt.want), count)
+				}
+			})
+		}
+	})
+}
+
+func TestVoucherRepository_ListFlightPrefixNonASCII(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		require.NoError(t, repo.Create(newVoucher("GÅ102", "2025-07-12", "4A")))
+		require.NoError(t, repo.Create(newVoucher("GA102", "2025-07-12", "5B")))
+
+		opts := ListOptions{FlightPrefix: "GÅ"}
+		vouchers, err := repo.List(opts)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"GÅ102@2025-07-12"}, flightsOf(vouchers))
+
+		count, err := repo.Count(opts)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
+
+func TestVoucherRepository_ListPaging(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		seedListVouchers(t, repo)
+
+		for _, descending := range []bool{false, true} {
+			opts := ListOptions{Sort: SortByFlightDate, Descending: descending}
+			all, err := repo.List(opts)
+			require.NoError(t, err)
+
+			// Walking pages of two must visit every voucher exactly once, in order
+			var paged []models.Voucher
+			opts.Limit = 2
+			for {
+				page, err := repo.List(opts)
+				require.NoError(t, err)
+				if len(page) == 0 {
+					break
+				}
+				paged = append(paged, page...)
+
+				last := page[len(page)-1]
+				opts.After = &Cursor{Value: SortValue(&last, opts.Sort), ID: last.ID}
+			}
+			assert.Equal(t, flightsOf(all), flightsOf(paged))
+
+			count, err := repo.Count(opts)
+			require.NoError(t, err)
+			assert.Equal(t, 5, count, "Count ignores the cursor and limit")
+		}
+	})
+}
+
+func TestVoucherRepository_ReturnsCopies(t *testing.T) {
+	runContract(t, func(t *testing.T, repo VoucherRepository) {
+		voucher := newVoucher("GA102", "2025-07-12", "4A")
+		require.NoError(t, repo.Create(voucher))
+		voucher.Seats[0] = "99Z"
+
+		found, err := repo.GetByFlightDate("GA102", "2025-07-12")
+		require.NoError(t, err)
+		found.Seats[0] = "98Z"
+
+		again, err := repo.GetByFlightDate("GA102", "2025-07-12")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"4A"}, again.Seats)
+	})
+}
+
+func TestSQLVoucherRepository_Rebind(t *testing.T) {
+	query := `UPDATE voucher_seats SET seat = ? WHERE voucher_id = ? AND position = ?`
+
+	sqlite := NewSQLiteVoucherRepository(nil)
+	assert.Equal(t, query, sqlite.rebind(query))
+
+	postgres := NewPostgresVoucherRepository(nil)
+	assert.Equal(t,
+		`UPDATE voucher_seats SET seat = $1 WHERE voucher_id = $2 AND position = $3`,
+		postgres.rebind(query),
+	)
+}
+
+func TestIsPostgresUniqueViolation(t *testing.T) {
+	assert.True(t, isPostgresUniqueViolation(fmt.Errorf("insert: %w", &pq.Error{Code: "23505"})))
+	assert.False(t, isPostgresUniqueViolation(&pq.Error{Code: "23503"}))
+	assert.False(t, isPostgresUniqueViolation(nil))
+}
+
+func TestNewSQLVoucherRepository(t *testing.T) {
+	repo, err := NewSQLVoucherRepository(config.DriverSQLite, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &SQLiteVoucherRepository{}, repo)
+
+	repo, err = NewSQLVoucherRepository(config.DriverPostgres, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &PostgresVoucherRepository{}, repo)
+
+	_, err = NewSQLVoucherRepository("mysql", nil)
+	assert.Error(t, err)
+}
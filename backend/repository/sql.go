@@ -0,0 +1,459 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"airline-voucher-backend/models"
+)
+
+// dialect captures the differences between the SQL engines the repository
+// supports. Queries are written with ? placeholders and rebound per dialect.
+type dialect struct {
+	// numberedPlaceholders selects $1, $2, ... instead of ?
+	numberedPlaceholders bool
+	// returningID fetches generated IDs with RETURNING instead of LastInsertId
+	returningID bool
+	// binaryCollation is appended to text columns when sorting, so text
+	// orders by bytes as in Go instead of by the database locale
+	binaryCollation string
+	// uniqueViolation reports whether an error is a UNIQUE constraint failure
+	uniqueViolation func(err error) bool
+	// rowLock is appended to a SELECT to lock the rows it reads until the
+	// transaction ends; engines whose transactions already hold the write
+	// lock leave it empty
+	rowLock string
+}
+
+// sqlVoucherRepository implements VoucherRepository on top of database/sql
+// for the schema managed by the migrations package
+type sqlVoucherRepository struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// rebind rewrites ? placeholders for the repository's dialect
+func (r *sqlVoucherRepository) rebind(query string) string {
+	if !r.dialect.numberedPlaceholders {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, ch := range query {
+		if ch == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+// insertID runs an INSERT and returns the generated id
+func (r *sqlVoucherRepository) insertID(tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+	if r.dialect.returningID {
+		var id int64
+		err := tx.QueryRow(r.rebind(query+" RETURNING id"), args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := tx.Exec(r.rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Create inserts the voucher and its seats in one transaction
+func (r *sqlVoucherRepository) Create(voucher *models.Voucher) error {
+	return r.CreateAll([]*models.Voucher{voucher})
+}
+
+// CreateAll inserts the vouchers and their seats in one transaction
+func (r *sqlVoucherRepository) CreateAll(vouchers []*models.Voucher) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, len(vouchers))
+	for i, voucher := range vouchers {
+		id, err := r.insert(tx, voucher)
+		if r.dialect.uniqueViolation(err) {
+			return &DuplicateError{Index: i}
+		}
+		if errors.Is(err, ErrSeatTaken) {
+			return &SeatTakenError{Index: i, Err: err}
+		}
+		if err != nil {
+			return err
+		}
+		ids[i] = id
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for i, voucher := range vouchers {
+		voucher.ID = int(ids[i])
+	}
+	return nil
+}
+
+// insert adds one voucher and its seats inside a transaction and returns its ID
+func (r *sqlVoucherRepository) insert(tx *sql.Tx, voucher *models.Voucher) (int64, error) {
+	query := `
+		INSERT INTO vouchers (crew_name, crew_id, flight_number, flight_date, aircraft_type, cabin_class, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	id, err := r.insertID(
+		tx,
+		query,
+		voucher.CrewName,
+		voucher.CrewID,
+		voucher.FlightNumber,
+		voucher.FlightDate,
+		voucher.AircraftType,
+		voucher.CabinClass,
+		voucher.CreatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.checkSeatsFree(tx, voucher.FlightNumber, voucher.FlightDate, voucher.Seats); err != nil {
+		return 0, err
+	}
+
+	for _, draw := range voucher.Draws {
+		draw.VoucherID = int(id)
+		if _, err := r.insertDraw(tx, draw); err != nil {
+			return 0, err
+		}
+	}
+	commitments := drawCommitments(voucher.Draws)
+
+	seatQuery := `
+		INSERT INTO voucher_seats (voucher_id, position, seat, draw_id, flight_number, flight_date)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	for i, seat := range voucher.Seats {
+		var drawID sql.NullInt64
+		if i < len(commitments) {
+			drawID = sql.NullInt64{Int64: int64(commitments[i].DrawID), Valid: true}
+		}
+		_, err := tx.Exec(r.rebind(seatQuery), id, i+1, seat, drawID, voucher.FlightNumber, voucher.FlightDate)
+		if r.dialect.uniqueViolation(err) {
+			return 0, fmt.Errorf("%w: %s on flight %s on %s", ErrSeatTaken, seat, voucher.FlightNumber, voucher.FlightDate)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for _, event := range voucher.Events {
+		if err := r.insertEvent(tx, int(id), event); err != nil {
+			return 0, err
+		}
+	}
+
+	voucher.Version = 1
+	voucher.Commitments = commitments
+	voucher.SeatStates = issuedStates(len(voucher.Seats))
+	return id, nil
+}
+
+// GetByFlightDate retrieves the voucher for the given flight and date
+func (r *sqlVoucherRepository) GetByFlightDate(flightNumber, date string) (*models.Voucher, error) {
+	return r.getOne(`flight_number = ? AND flight_date = ?`, flightNumber, date)
+}
+
+// GetByID retrieves the voucher with the given ID
+func (r *sqlVoucherRepository) GetByID(id int) (*models.Voucher, error) {
+	return r.getOne(`id = ?`, id)
+}
+
+// getOne retrieves the single voucher matching a WHERE condition, with its seats
+func (r *sqlVoucherRepository) getOne(condition string, args ...interface{}) (*models.Voucher, error) {
+	query := `SELECT id, crew_name, crew_id, flight_number, flight_date, aircraft_type, cabin_class, created_at, version
+			  FROM vouchers WHERE ` + condition
+
+	var voucher models.Voucher
+	err := r.db.QueryRow(r.rebind(query), args...).Scan(
+		&voucher.ID,
+		&voucher.CrewName,
+		&voucher.CrewID,
+		&voucher.FlightNumber,
+		&voucher.FlightDate,
+		&voucher.AircraftType,
+		&voucher.CabinClass,
+		&voucher.CreatedAt,
+		&voucher.Version,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vouchers := []models.Voucher{voucher}
+	if err := r.loadSeats(vouchers); err != nil {
+		return nil, err
+	}
+
+	return &vouchers[0], nil
+}
+
+// UpdateSeat replaces a single issued seat of a voucher and records its draw
+// and event in one transaction. The status check is part of the UPDATE, so a
+// redeem or void that commits first makes the redraw fail.
+func (r *sqlVoucherRepository) UpdateSeat(id, version, position int, seat string, draw *models.SeatDraw, event *models.VoucherEvent, limits RegenerationLimits) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.bumpVersion(tx, id, version); err != nil {
+		return err
+	}
+	if err := r.checkRegenerationLimits(tx, id, position, limits); err != nil {
+		return err
+	}
+
+	// Another position of the same flight may already hold the seat; the
+	// unique index catches a concurrent writer that slips past this check
+	var holders int
+	err = tx.QueryRow(r.rebind(`
+		SELECT COUNT(*) FROM voucher_seats s
+		JOIN voucher_seats t ON t.flight_number = s.flight_number AND t.flight_date = s.flight_date
+		WHERE t.voucher_id = ? AND t.position = ? AND s.seat = ?
+		AND NOT (s.voucher_id = t.voucher_id AND s.position = t.position)
+	`), id, position, seat).Scan(&holders)
+	if err != nil {
+		return err
+	}
+	if holders > 0 {
+		return fmt.Errorf("%w: %s", ErrSeatTaken, seat)
+	}
+
+	result, err := tx.Exec(
+		r.rebind(`UPDATE voucher_seats SET seat = ?, draw_id = NULL WHERE voucher_id = ? AND position = ? AND status = ?`),
+		seat, id, position, models.SeatStatusIssued,
+	)
+	if r.dialect.uniqueViolation(err) {
+		return fmt.Errorf("%w: %s", ErrSeatTaken, seat)
+	}
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return r.unchangedSeat(tx, id, position)
+	}
+
+	if draw != nil {
+		draw.VoucherID = id
+		drawID, err := r.insertDraw(tx, draw)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			r.rebind(`UPDATE voucher_seats SET draw_id = ? WHERE voucher_id = ? AND position = ?`),
+			drawID, id, position,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := r.insertEvent(tx, id, event); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkRegenerationLimits counts the regenerate events of a voucher inside
+// a transaction. The voucher row stays locked until the transaction ends, so
+// a concurrent redraw waits for this one and then counts its event.
+func (r *sqlVoucherRepository) checkRegenerationLimits(tx *sql.Tx, id, position int, limits RegenerationLimits) error {
+	if limits.unlimited() {
+		return nil
+	}
+
+	var locked int
+	err := tx.QueryRow(r.rebind(`SELECT id FROM vouchers WHERE id = ?`+r.dialect.rowLock), id).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var voucherCount, seatCount int
+	err = tx.QueryRow(r.rebind(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN position = ? THEN 1 ELSE 0 END), 0)
+		FROM voucher_events WHERE voucher_id = ? AND event_type = ?
+	`), position, id, models.EventRegenerate).Scan(&voucherCount, &seatCount)
+	if err != nil {
+		return err
+	}
+	return limits.check(position, voucherCount, seatCount)
+}
+
+// List returns the vouchers matching the options
+func (r *sqlVoucherRepository) List(opts ListOptions) ([]models.Voucher, error) {
+	where, args := opts.whereClause(true, r.dialect.binaryCollation)
+	query := `SELECT id, crew_name, crew_id, flight_number, flight_date, aircraft_type, cabin_class, created_at, version
+			  FROM vouchers` + where + opts.orderClause(r.dialect.binaryCollation)
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit)
+	}
+
+	rows, err := r.db.Query(r.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vouchers := []models.Voucher{}
+	for rows.Next() {
+		var voucher models.Voucher
+		err := rows.Scan(
+			&voucher.ID,
+			&voucher.CrewName,
+			&voucher.CrewID,
+			&voucher.FlightNumber,
+			&voucher.FlightDate,
+			&voucher.AircraftType,
+			&voucher.CabinClass,
+			&voucher.CreatedAt,
+			&voucher.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		vouchers = append(vouchers, voucher)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadSeats(vouchers); err != nil {
+		return nil, err
+	}
+
+	return vouchers, nil
+}
+
+// Count returns how many vouchers match the options' filters
+func (r *sqlVoucherRepository) Count(opts ListOptions) (int, error) {
+	where, args := opts.whereClause(false, r.dialect.binaryCollation)
+
+	var count int
+	err := r.db.QueryRow(r.rebind(`SELECT COUNT(*) FROM vouchers`+where), args...).Scan(&count)
+	return count, err
+}
+
+// Delete removes a voucher and records its event in one transaction; its
+// seats are removed by ON DELETE CASCADE, its draws and events are kept. The
+// version check is part of the DELETE, so a change that commits first makes
+// it fail.
+func (r *sqlVoucherRepository) Delete(id, version int, event *models.VoucherEvent) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query, args := versionCondition(`DELETE FROM vouchers WHERE id = ?`, id, version)
+	result, err := tx.Exec(r.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	if err := r.requireVersion(tx, result, id); err != nil {
+		return err
+	}
+
+	if err := r.insertEvent(tx, id, event); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// loadSeats fills in the seats, seat states and draw commitments of the
+// vouchers in position order
+func (r *sqlVoucherRepository) loadSeats(vouchers []models.Voucher) error {
+	if len(vouchers) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(vouchers))
+	args := make([]interface{}, len(vouchers))
+	for i := range vouchers {
+		index[vouchers[i].ID] = i
+		args[i] = vouchers[i].ID
+		vouchers[i].Seats = []string{}
+		vouchers[i].SeatStates = []models.SeatState{}
+		vouchers[i].Commitments = nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(vouchers)), ",")
+	query := fmt.Sprintf(`
+		SELECT s.voucher_id, s.seat, s.status, s.redeemed_by, s.redeemed_at, COALESCE(d.id, 0), COALESCE(d.algorithm, ''), COALESCE(d.commitment, '')
+		FROM voucher_seats s LEFT JOIN seat_draws d ON d.id = s.draw_id
+		WHERE s.voucher_id IN (%s) ORDER BY s.voucher_id, s.position
+	`, placeholders)
+	rows, err := r.db.Query(r.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	commitments := make([][]models.SeatCommitment, len(vouchers))
+	recorded := make([]bool, len(vouchers))
+	for rows.Next() {
+		var (
+			id         int
+			seat       string
+			state      models.SeatState
+			commitment models.SeatCommitment
+		)
+		err := rows.Scan(&id, &seat, &state.Status, &state.RedeemedBy, &state.RedeemedAt,
+			&commitment.DrawID, &commitment.Algorithm, &commitment.Commitment)
+		if err != nil {
+			return err
+		}
+		i := index[id]
+		vouchers[i].Seats = append(vouchers[i].Seats, seat)
+		vouchers[i].SeatStates = append(vouchers[i].SeatStates, state)
+		commitments[i] = append(commitments[i], commitment)
+		recorded[i] = recorded[i] || commitment.DrawID != 0
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Vouchers drawn before draws were recorded have no commitments at all
+	for i := range vouchers {
+		if recorded[i] {
+			vouchers[i].Commitments = commitments[i]
+		}
+	}
+	return nil
+}
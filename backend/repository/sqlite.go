@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var _ VoucherRepository = (*SQLiteVoucherRepository)(nil)
+
+// SQLiteVoucherRepository stores vouchers in the SQLite schema managed by the
+// migrations package
+type SQLiteVoucherRepository struct {
+	sqlVoucherRepository
+}
+
+// NewSQLiteVoucherRepository creates a repository backed by an open SQLite database
+func NewSQLiteVoucherRepository(db *sql.DB) *SQLiteVoucherRepository {
+	return &SQLiteVoucherRepository{
+		sqlVoucherRepository{
+			db: db,
+			dialect: dialect{
+				uniqueViolation: isSQLiteUniqueViolation,
+			},
+		},
+	}
+}
+
+// isSQLiteUniqueViolation reports whether err is a SQLite UNIQUE or PRIMARY
+// KEY constraint failure
+func isSQLiteUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"database/sql"
+
+	"airline-voucher-backend/models"
+)
+
+// SetSeatState updates the state of one seat if its status is still from, and
+// records its event in the same transaction. The status check is part of the
+// UPDATE, so of two concurrent transitions only one succeeds.
+func (r *sqlVoucherRepository) SetSeatState(id, version, position int, from string, state models.SeatState, event *models.VoucherEvent) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.bumpVersion(tx, id, version); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(r.rebind(`
+		UPDATE voucher_seats SET status = ?, redeemed_by = ?, redeemed_at = ?
+		WHERE voucher_id = ? AND position = ? AND status = ?
+	`), state.Status, state.RedeemedBy, state.RedeemedAt, id, position, from)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		if err := r.insertEvent(tx, id, event); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	return r.unchangedSeat(tx, id, position)
+}
+
+// unchangedSeat explains why a guarded UPDATE of one seat matched no rows:
+// ErrNotFound when the voucher or position does not exist, ErrStatusChanged
+// otherwise
+func (r *sqlVoucherRepository) unchangedSeat(tx *sql.Tx, id, position int) error {
+	var seats int
+	err := tx.QueryRow(r.rebind(`SELECT COUNT(*) FROM voucher_seats WHERE voucher_id = ? AND position = ?`), id, position).Scan(&seats)
+	if err != nil {
+		return err
+	}
+	if seats == 0 {
+		return ErrNotFound
+	}
+	return ErrStatusChanged
+}
+
+// bumpVersion advances the version of a voucher inside a transaction. A
+// non-zero version must still be current; the check is part of the UPDATE,
+// which also locks the voucher row, so of two writers expecting the same
+// version only the first succeeds.
+func (r *sqlVoucherRepository) bumpVersion(tx *sql.Tx, id, version int) error {
+	query, args := versionCondition(`UPDATE vouchers SET version = version + 1 WHERE id = ?`, id, version)
+	result, err := tx.Exec(r.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	return r.requireVersion(tx, result, id)
+}
+
+// versionCondition appends the version check to a statement guarded by the
+// voucher ID; a zero version leaves the statement unguarded
+func versionCondition(query string, id, version int) (string, []interface{}) {
+	args := []interface{}{id}
+	if version != 0 {
+		query += ` AND version = ?`
+		args = append(args, version)
+	}
+	return query, args
+}
+
+// requireVersion explains why a statement guarded by versionCondition
+// matched no rows: ErrNotFound when the voucher does not exist,
+// ErrVersionChanged otherwise
+func (r *sqlVoucherRepository) requireVersion(tx *sql.Tx, result sql.Result, id int) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var vouchers int
+	err = tx.QueryRow(r.rebind(`SELECT COUNT(*) FROM vouchers WHERE id = ?`), id).Scan(&vouchers)
+	if err != nil {
+		return err
+	}
+	if vouchers == 0 {
+		return ErrNotFound
+	}
+	return ErrVersionChanged
+}
+
+// SetSeatState updates the state of one seat if its status is still from and
+// records its event
+func (r *MemoryVoucherRepository) SetSeatState(id, version, position int, from string, state models.SeatState, event *models.VoucherEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	voucher, ok := r.vouchers[id]
+	if !ok || position < 1 || position > len(voucher.Seats) {
+		return ErrNotFound
+	}
+	if version != 0 && voucher.Version != version {
+		return ErrVersionChanged
+	}
+	if voucher.SeatStates[position-1].Status != from {
+		return ErrStatusChanged
+	}
+
+	voucher.SeatStates[position-1] = state
+	voucher.Version++
+	r.vouchers[id] = voucher
+	r.recordEvent(id, event)
+	return nil
+}
+
+// issuedStates returns the states of newly created seats
+func issuedStates(count int) []models.SeatState {
+	states := make([]models.SeatState, count)
+	for i := range states {
+		states[i].Status = models.SeatStatusIssued
+	}
+	return states
+}
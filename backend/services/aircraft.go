@@ -0,0 +1,25 @@
+package services
+
+import (
+	"fmt"
+
+	"airline-voucher-backend/utils"
+)
+
+// ReplaceAircraftLayouts validates aircraft layouts in the given format
+// ("json" or "yaml") and makes them the active layouts, returning the aircraft
+// types they define. Invalid layouts leave the active ones untouched. The
+// layouts are kept in memory; a restart loads the layouts file again.
+func (s *VoucherService) ReplaceAircraftLayouts(data []byte, format string) ([]string, error) {
+	if err := s.authorize(actionManageLayouts, ""); err != nil {
+		return nil, err
+	}
+
+	configs, err := utils.ParseAircraftConfigs(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAircraftLayouts, err)
+	}
+
+	utils.SetAircraftConfigs(configs)
+	return utils.AircraftTypes(), nil
+}
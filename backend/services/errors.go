@@ -0,0 +1,72 @@
+package services
+
+import (
+	"errors"
+
+	"airline-voucher-backend/repository"
+	"airline-voucher-backend/utils"
+)
+
+// Errors returned by VoucherService. They are wrapped with request details,
+// so callers should match them with errors.Is rather than comparing messages.
+var (
+	// ErrVoucherExists is returned when the flight and date already have a voucher
+	ErrVoucherExists = errors.New("voucher already exists")
+	// ErrVoucherNotFound is returned when no voucher matches the lookup
+	ErrVoucherNotFound = errors.New("voucher not found")
+	// ErrMissingFields is returned when a generate request lacks a required field
+	ErrMissingFields = errors.New("missing required fields")
+	// ErrInvalidAircraft is returned for aircraft types without a seat layout
+	ErrInvalidAircraft = errors.New("invalid aircraft type")
+	// ErrInvalidDate is returned for dates not in YYYY-MM-DD format
+	ErrInvalidDate = errors.New("invalid date format")
+	// ErrInvalidCabinClass is returned for cabin classes the aircraft doesn't have
+	ErrInvalidCabinClass = errors.New("invalid cabin class")
+	// ErrInvalidSeatCount is returned when the requested number of seats is out of range
+	ErrInvalidSeatCount = errors.New("invalid seat count")
+	// ErrInvalidSeatPosition is returned when a seat position doesn't exist on the voucher
+	ErrInvalidSeatPosition = errors.New("invalid seat position")
+	// ErrInvalidSeatTransition is returned when a seat's status does not
+	// allow the requested change, e.g. redeeming a voided seat
+	ErrInvalidSeatTransition = errors.New("invalid seat status transition")
+	// ErrInvalidReason is returned for seat regenerations with an unknown
+	// reason code
+	ErrInvalidReason = errors.New("invalid regeneration reason")
+	// ErrRegenerationLimitReached is returned when a voucher or one of its
+	// seats was already redrawn as often as allowed
+	ErrRegenerationLimitReached = errors.New("regeneration limit reached")
+	// ErrInvalidQuery is returned for unknown sort fields, malformed cursors
+	// and out-of-range page sizes when listing vouchers
+	ErrInvalidQuery = errors.New("invalid list query")
+	// ErrInvalidBatch is returned for empty or oversized batches and unknown batch modes
+	ErrInvalidBatch = errors.New("invalid batch")
+	// ErrInvalidIdempotencyKey is returned for empty or overlong Idempotency-Key values
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+	// ErrIdempotencyKeyReused is returned when an idempotency key is sent
+	// again with a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key already used")
+	// ErrInvalidSeatPreference is returned for unknown seat positions and seat
+	// mixes that ask for more seats than the voucher holds
+	ErrInvalidSeatPreference = errors.New("invalid seat preference")
+	// ErrSeatPreferenceUnsatisfiable is returned when the cabin has too few
+	// seats at the requested positions
+	ErrSeatPreferenceUnsatisfiable = utils.ErrSeatPreferenceUnsatisfiable
+	// ErrInvalidOccupancy is returned for occupancy uploads with malformed
+	// seats or too many seats
+	ErrInvalidOccupancy = errors.New("invalid occupancy")
+	// ErrInvalidAircraftLayouts is returned for aircraft layout uploads that
+	// cannot be parsed or describe an unusable cabin
+	ErrInvalidAircraftLayouts = errors.New("invalid aircraft layouts")
+	// ErrSeatTaken is returned when a drawn seat was assigned to another
+	// voucher of the flight before the draw could be saved
+	ErrSeatTaken = repository.ErrSeatTaken
+	// ErrNotEnoughSeats is returned when the cabin has too few assignable or
+	// unoccupied seats for the draw
+	ErrNotEnoughSeats = utils.ErrNotEnoughSeats
+	// ErrPreconditionFailed is returned when a voucher no longer has the
+	// version the caller expects because another request changed it
+	ErrPreconditionFailed = errors.New("voucher has changed")
+	// ErrForbidden is returned when the caller's role does not allow the
+	// change, or a crew member generates for a flight they do not work
+	ErrForbidden = errors.New("forbidden")
+)
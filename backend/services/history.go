@@ -0,0 +1,64 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"airline-voucher-backend/models"
+)
+
+// WithRequestID returns a copy of the service that tags the voucher events it
+// records with the ID of the API request being served
+func (s *VoucherService) WithRequestID(requestID string) *VoucherService {
+	scoped := *s
+	scoped.requestID = requestID
+	return &scoped
+}
+
+// newEvent builds a voucher event stamped with the request ID and the time
+func (s *VoucherService) newEvent(eventType string, position int, actorID, oldValue, newValue string) *models.VoucherEvent {
+	return &models.VoucherEvent{
+		Type:      eventType,
+		Position:  position,
+		ActorID:   strings.TrimSpace(actorID),
+		OldValue:  oldValue,
+		NewValue:  newValue,
+		RequestID: s.requestID,
+		CreatedAt: s.timestamp(),
+	}
+}
+
+// principalID returns the ID of the caller, for events that have no crew
+// member named in the request, or "" when there is no principal
+func (s *VoucherService) principalID() string {
+	if s.principal == nil {
+		return ""
+	}
+	return s.principal.ID
+}
+
+// seatList formats seats as an event value
+func seatList(seats []string) string {
+	return strings.Join(seats, ",")
+}
+
+// VoucherHistory returns the recorded events of a voucher, oldest first. The
+// history of a deleted voucher stays available; ErrVoucherNotFound is
+// returned only when the voucher never existed.
+func (s *VoucherService) VoucherHistory(id int) (*models.VoucherHistoryResponse, error) {
+	events, err := s.repo.ListEvents(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get voucher history: %w", err)
+	}
+	if len(events) == 0 {
+		// Vouchers created before events were recorded have no history yet
+		if _, err := s.GetVoucherByID(id); err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.VoucherHistoryResponse{
+		VoucherID: id,
+		Events:    events,
+	}, nil
+}
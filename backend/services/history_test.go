@@ -0,0 +1,76 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"airline-voucher-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoucherService_VoucherHistory(t *testing.T) {
+	service, _ := newMemoryService()
+	service.SetClock(func() time.Time { return time.Date(2025, 7, 12, 9, 30, 0, 0, time.Local) })
+
+	_, err := service.VoucherHistory(1)
+	assert.ErrorIs(t, err, ErrVoucherNotFound)
+
+	response, err := service.WithRequestID("req-1").GenerateVoucher(validRequest())
+	require.NoError(t, err)
+	voucher, err := service.GetVoucher("GA102", "2025-07-12")
+	require.NoError(t, err)
+	original := voucher.Seats[0]
+
+	regenerated, err := service.WithRequestID("req-2").RegenerateSeat(&models.RegenerateSeatRequest{
+		FlightNumber: "GA102",
+		Date:         "2025-07-12",
+		SeatPosition: 1,
+		CrewID:       "77001",
+		Reason:       models.ReasonAccessibility,
+	})
+	require.NoError(t, err)
+	_, err = service.RedeemSeat(voucher.ID, &models.RedeemSeatRequest{SeatPosition: 2, RedeemedBy: "98123"})
+	require.NoError(t, err)
+	_, err = service.VoidSeat(voucher.ID, &models.VoidSeatRequest{SeatPosition: 3, CrewID: "77001"})
+	require.NoError(t, err)
+	admin := service.WithPrincipal(&models.Principal{ID: "55001", Role: models.RoleAdmin})
+	require.NoError(t, admin.DeleteVoucher(voucher.ID, 0))
+
+	history, err := service.VoucherHistory(voucher.ID)
+	require.NoError(t, err)
+	assert.Equal(t, voucher.ID, history.VoucherID)
+	require.Len(t, history.Events, 5)
+
+	created := history.Events[0]
+	assert.Equal(t, models.EventCreate, created.Type)
+	assert.Equal(t, "98123", created.ActorID)
+	assert.Equal(t, strings.Join(response.Seats, ","), created.NewValue)
+	assert.Equal(t, "req-1", created.RequestID)
+	assert.Equal(t, "2025-07-12 09:30:00", created.CreatedAt)
+
+	assert.Equal(t, models.VoucherEvent{
+		ID:        history.Events[1].ID,
+		VoucherID: voucher.ID,
+		Type:      models.EventRegenerate,
+		Position:  1,
+		ActorID:   "77001",
+		OldValue:  original,
+		NewValue:  regenerated.NewSeat,
+		Reason:    models.ReasonAccessibility,
+		RequestID: "req-2",
+		CreatedAt: "2025-07-12 09:30:00",
+	}, history.Events[1])
+
+	assert.Equal(t, models.EventRedeem, history.Events[2].Type)
+	assert.Equal(t, "98123", history.Events[2].ActorID)
+	assert.Equal(t, models.SeatStatusIssued, history.Events[2].OldValue)
+	assert.Equal(t, models.SeatStatusRedeemed, history.Events[2].NewValue)
+	assert.Equal(t, models.EventVoid, history.Events[3].Type)
+	assert.Equal(t, 3, history.Events[3].Position)
+	assert.Equal(t, models.EventDelete, history.Events[4].Type)
+	assert.Equal(t, "55001", history.Events[4].ActorID)
+	assert.Equal(t, strings.Join(regenerated.AllSeats, ","), history.Events[4].OldValue)
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
+)
+
+// MaxIdempotencyKeyLength is the longest Idempotency-Key accepted
+const MaxIdempotencyKeyLength = 255
+
+// SetIdempotencyKeyTTL sets how long an idempotency key is kept. Expired
+// keys are removed before each idempotent request, after which the key may
+// be used again. Zero, the default, keeps keys forever.
+func (s *VoucherService) SetIdempotencyKeyTTL(ttl time.Duration) error {
+	if ttl < 0 {
+		return fmt.Errorf("idempotency key TTL must not be negative, got %s", ttl)
+	}
+	s.idempotencyKeyTTL = ttl
+	return nil
+}
+
+// GenerateVoucherIdempotent is GenerateVoucher for requests that carry an
+// idempotency key. Keys belong to the caller that sent them, so two callers
+// may use the same key independently. The first request with a key saves its
+// response together with the voucher. Sending the same payload with the same
+// key again returns that original response, with replayed set, instead of
+// ErrVoucherExists. Sending a different payload with a used key fails with
+// ErrIdempotencyKeyReused.
+func (s *VoucherService) GenerateVoucherIdempotent(key string, req *models.GenerateVoucherRequest) (response *models.GenerateVoucherResponse, replayed bool, err error) {
+	if key == "" || len(key) > MaxIdempotencyKeyLength {
+		return nil, false, fmt.Errorf("%w: must be 1 to %d characters", ErrInvalidIdempotencyKey, MaxIdempotencyKeyLength)
+	}
+
+	// Only callers who may generate for the flight get to see a replay
+	if err := s.authorize(actionGenerate, req.FlightNumber); err != nil {
+		return nil, false, err
+	}
+
+	// Hash the payload as sent, before defaults are filled in
+	hash, err := requestHash(req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := s.expireIdempotencyKeys(); err != nil {
+		return nil, false, err
+	}
+
+	scope := s.idempotencyScope()
+	if response, err := s.replay(scope, key, hash); response != nil || err != nil {
+		return response, response != nil, err
+	}
+
+	voucher, err := s.prepareVoucher(req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	response = &models.GenerateVoucherResponse{
+		Success: true,
+		Seats:   voucher.Seats,
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	err = s.repo.CreateIdempotent(voucher, &models.IdempotencyKey{
+		Scope:       scope,
+		Key:         key,
+		RequestHash: hash,
+		Response:    string(data),
+		CreatedAt:   s.timestamp(),
+	})
+	if errors.Is(err, repository.ErrDuplicate) || errors.Is(err, repository.ErrDuplicateKey) {
+		// A concurrent request with the same key may have won the race
+		if response, replayErr := s.replay(scope, key, hash); response != nil || replayErr != nil {
+			return response, response != nil, replayErr
+		}
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, false, fmt.Errorf("%w for flight %s on %s", ErrVoucherExists, req.FlightNumber, req.Date)
+		}
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to save voucher: %w", err)
+	}
+
+	return response, false, nil
+}
+
+// idempotencyScope returns the scope of the caller's idempotency keys: the
+// crew ID or service account name of the principal, or the empty scope when
+// authentication is disabled
+func (s *VoucherService) idempotencyScope() string {
+	switch {
+	case s.principal == nil:
+		return ""
+	case s.principal.ServiceAccount:
+		return "service:" + s.principal.ID
+	default:
+		return "crew:" + s.principal.ID
+	}
+}
+
+// expireIdempotencyKeys removes the idempotency keys older than the TTL
+func (s *VoucherService) expireIdempotencyKeys() error {
+	if s.idempotencyKeyTTL == 0 {
+		return nil
+	}
+	cutoff := s.now().Add(-s.idempotencyKeyTTL).Format("2006-01-02 15:04:05")
+	if _, err := s.repo.DeleteIdempotencyKeys(cutoff); err != nil {
+		return fmt.Errorf("failed to expire idempotency keys: %w", err)
+	}
+	return nil
+}
+
+// replay returns the stored response for an idempotency key of a scope, nil
+// if the key is unused, or ErrIdempotencyKeyReused if it was used for
+// another payload
+func (s *VoucherService) replay(scope, key, hash string) (*models.GenerateVoucherResponse, error) {
+	record, err := s.repo.GetIdempotencyKey(scope, key)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up idempotency key: %w", err)
+	}
+
+	if record.RequestHash != hash {
+		return nil, fmt.Errorf("%w: %q was sent with a different request", ErrIdempotencyKeyReused, key)
+	}
+
+	var response models.GenerateVoucherResponse
+	if err := json.Unmarshal([]byte(record.Response), &response); err != nil {
+		return nil, fmt.Errorf("failed to decode stored response: %w", err)
+	}
+	return &response, nil
+}
+
+// requestHash returns the hex SHA-256 of a request's JSON encoding
+func requestHash(req *models.GenerateVoucherRequest) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
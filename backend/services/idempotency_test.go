@@ -0,0 +1,141 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"airline-voucher-backend/config"
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoucherService_GenerateVoucherIdempotent_Replay(t *testing.T) {
+	service, _ := newMemoryService()
+
+	first, replayed, err := service.GenerateVoucherIdempotent("key-1", validRequest())
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Len(t, first.Seats, 3)
+
+	// Redrawing a seat doesn't change what the retry returns
+	_, err = service.RegenerateSeat(regenerateRequest(1))
+	require.NoError(t, err)
+
+	again, replayed, err := service.GenerateVoucherIdempotent("key-1", validRequest())
+	require.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, first, again)
+
+	// Without the key the retry is a conflict as before
+	_, err = service.GenerateVoucher(validRequest())
+	assert.ErrorIs(t, err, ErrVoucherExists)
+}
+
+func TestVoucherService_GenerateVoucherIdempotent_Errors(t *testing.T) {
+	service, repo := newMemoryService()
+
+	_, _, err := service.GenerateVoucherIdempotent("key-1", validRequest())
+	require.NoError(t, err)
+
+	other := validRequest()
+	other.FlightNumber = "GA103"
+	_, _, err = service.GenerateVoucherIdempotent("key-1", other)
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	// The same flight under a fresh key is still a duplicate
+	_, _, err = service.GenerateVoucherIdempotent("key-2", validRequest())
+	assert.ErrorIs(t, err, ErrVoucherExists)
+
+	_, _, err = service.GenerateVoucherIdempotent("", other)
+	assert.ErrorIs(t, err, ErrInvalidIdempotencyKey)
+	_, _, err = service.GenerateVoucherIdempotent(strings.Repeat("k", MaxIdempotencyKeyLength+1), other)
+	assert.ErrorIs(t, err, ErrInvalidIdempotencyKey)
+
+	count, err := repo.Count(repository.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestVoucherService_GenerateVoucherIdempotent_ScopedByCaller(t *testing.T) {
+	service, _ := newMemoryService()
+	sarah := service.WithPrincipal(&models.Principal{ID: "98123", Role: models.RoleCrew, Flights: []string{"GA102", "GA103"}})
+	omar := service.WithPrincipal(&models.Principal{ID: "77001", Role: models.RoleCrew, Flights: []string{"GA102", "GA103"}})
+
+	_, _, err := sarah.GenerateVoucherIdempotent("key-1", validRequest())
+	require.NoError(t, err)
+
+	// Another crew member's key of the same name neither replays Sarah's
+	// response nor counts as reused
+	_, replayed, err := omar.GenerateVoucherIdempotent("key-1", validRequest())
+	assert.ErrorIs(t, err, ErrVoucherExists)
+	assert.False(t, replayed)
+
+	other := validRequest()
+	other.FlightNumber = "GA103"
+	_, _, err = omar.GenerateVoucherIdempotent("key-1", other)
+	require.NoError(t, err)
+
+	_, replayed, err = sarah.GenerateVoucherIdempotent("key-1", validRequest())
+	require.NoError(t, err)
+	assert.True(t, replayed)
+}
+
+func TestVoucherService_GenerateVoucherIdempotent_TTL(t *testing.T) {
+	service, _ := newMemoryService()
+	now := time.Date(2025, 7, 12, 9, 30, 0, 0, time.Local)
+	service.SetClock(func() time.Time { return now })
+	require.Error(t, service.SetIdempotencyKeyTTL(-time.Hour))
+	require.NoError(t, service.SetIdempotencyKeyTTL(24*time.Hour))
+
+	_, _, err := service.GenerateVoucherIdempotent("key-1", validRequest())
+	require.NoError(t, err)
+
+	now = now.Add(23 * time.Hour)
+	_, replayed, err := service.GenerateVoucherIdempotent("key-1", validRequest())
+	require.NoError(t, err)
+	assert.True(t, replayed)
+
+	// Once expired the key is forgotten: a retry is a conflict again, and
+	// the key can be used for another request
+	now = now.Add(2 * time.Hour)
+	_, _, err = service.GenerateVoucherIdempotent("key-1", validRequest())
+	assert.ErrorIs(t, err, ErrVoucherExists)
+
+	other := validRequest()
+	other.FlightNumber = "GA103"
+	_, replayed, err = service.GenerateVoucherIdempotent("key-1", other)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+}
+
+func TestVoucherService_GenerateVoucherIdempotent_ConcurrentRetries(t *testing.T) {
+	db, err := config.InitDB(filepath.Join(t.TempDir(), "vouchers.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	service := NewVoucherService(repository.NewSQLiteVoucherRepository(db))
+
+	const retries = 8
+	responses := make([]*models.GenerateVoucherResponse, retries)
+	var wg sync.WaitGroup
+	for i := 0; i < retries; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			response, _, err := service.GenerateVoucherIdempotent("key-1", validRequest())
+			assert.NoError(t, err)
+			responses[i] = response
+		}(i)
+	}
+	wg.Wait()
+
+	for _, response := range responses {
+		require.NotNil(t, response)
+		assert.Equal(t, responses[0].Seats, response.Seats)
+	}
+}
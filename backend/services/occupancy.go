@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
+	"airline-voucher-backend/utils"
+)
+
+// MaxOccupiedSeats caps the number of seats in one occupancy upload
+const MaxOccupiedSeats = 1000
+
+// SetOccupancy replaces the seats of a flight that are taken by booked
+// passengers. Seats are normalized to upper case, blank entries are skipped
+// and duplicates are dropped; an empty list clears the occupancy.
+func (s *VoucherService) SetOccupancy(flightNumber, date string, seats []string) (*models.Occupancy, error) {
+	if err := s.authorize(actionUploadOccupancy, flightNumber); err != nil {
+		return nil, err
+	}
+	if flightNumber == "" {
+		return nil, fmt.Errorf("%w: flightNumber is required", ErrMissingFields)
+	}
+	if !utils.ValidateDateFormat(date) {
+		return nil, fmt.Errorf("%w: %s (expected YYYY-MM-DD)", ErrInvalidDate, date)
+	}
+	if len(seats) > MaxOccupiedSeats {
+		return nil, fmt.Errorf("%w: %d seats (at most %d)", ErrInvalidOccupancy, len(seats), MaxOccupiedSeats)
+	}
+
+	occupancy := &models.Occupancy{
+		FlightNumber: flightNumber,
+		FlightDate:   date,
+		Seats:        []string{},
+		UploadedAt:   models.GetCurrentTimestamp(),
+	}
+	seen := make(map[string]bool, len(seats))
+	for _, seat := range seats {
+		seat = strings.ToUpper(strings.TrimSpace(seat))
+		if seat == "" || seen[seat] {
+			continue
+		}
+		if _, _, err := utils.ParseSeat(seat); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidOccupancy, err)
+		}
+		seen[seat] = true
+		occupancy.Seats = append(occupancy.Seats, seat)
+	}
+
+	if err := s.repo.SetOccupancy(occupancy); err != nil {
+		return nil, fmt.Errorf("failed to save occupancy: %w", err)
+	}
+
+	if len(occupancy.Seats) == 0 {
+		occupancy.UploadedAt = ""
+	}
+	return occupancy, nil
+}
+
+// GetOccupancy returns the occupied seats of a flight, with an empty seat
+// list when none were uploaded
+func (s *VoucherService) GetOccupancy(flightNumber, date string) (*models.Occupancy, error) {
+	occupancy, err := s.repo.GetOccupancy(flightNumber, date)
+	if errors.Is(err, repository.ErrNotFound) {
+		return &models.Occupancy{FlightNumber: flightNumber, FlightDate: date, Seats: []string{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get occupancy: %w", err)
+	}
+
+	return occupancy, nil
+}
+
+// occupiedSeats returns the seats of a flight that vouchers must not use
+func (s *VoucherService) occupiedSeats(flightNumber, date string) ([]string, error) {
+	occupancy, err := s.GetOccupancy(flightNumber, date)
+	if err != nil {
+		return nil, err
+	}
+	return occupancy.Seats, nil
+}
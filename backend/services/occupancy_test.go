@@ -0,0 +1,123 @@
+package services
+
+import (
+	"testing"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoucherService_SetOccupancy(t *testing.T) {
+	service, _ := newMemoryService()
+
+	occupancy, err := service.SetOccupancy("GA102", "2025-07-12", []string{" 12c", "1A", "", "12C"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"12C", "1A"}, occupancy.Seats)
+	assert.NotEmpty(t, occupancy.UploadedAt)
+
+	stored, err := service.GetOccupancy("GA102", "2025-07-12")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"12C", "1A"}, stored.Seats)
+
+	// Another upload replaces the seats; an empty one clears them
+	_, err = service.SetOccupancy("GA102", "2025-07-12", []string{"3D"})
+	require.NoError(t, err)
+	stored, err = service.GetOccupancy("GA102", "2025-07-12")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3D"}, stored.Seats)
+
+	_, err = service.SetOccupancy("GA102", "2025-07-12", nil)
+	require.NoError(t, err)
+	stored, err = service.GetOccupancy("GA102", "2025-07-12")
+	require.NoError(t, err)
+	assert.Empty(t, stored.Seats)
+	assert.Empty(t, stored.UploadedAt)
+
+	_, err = service.SetOccupancy("GA102", "2025-07-12", []string{"12C", "aisle"})
+	assert.ErrorIs(t, err, ErrInvalidOccupancy)
+	_, err = service.SetOccupancy("GA102", "12-07-2025", []string{"12C"})
+	assert.ErrorIs(t, err, ErrInvalidDate)
+	_, err = service.SetOccupancy("", "2025-07-12", []string{"12C"})
+	assert.ErrorIs(t, err, ErrMissingFields)
+}
+
+// occupyAllBut marks every ATR seat of GA102 on 2025-07-12 occupied except free
+func occupyAllBut(t *testing.T, service *VoucherService, free ...string) {
+	seats, err := utils.GetAllSeats("ATR")
+	require.NoError(t, err)
+
+	var occupied []string
+	for _, seat := range seats {
+		if !containsSeat(free, seat) {
+			occupied = append(occupied, seat)
+		}
+	}
+	_, err = service.SetOccupancy("GA102", "2025-07-12", occupied)
+	require.NoError(t, err)
+}
+
+func atrRequest() *models.GenerateVoucherRequest {
+	req := validRequest()
+	req.Aircraft = "ATR"
+	return req
+}
+
+func TestVoucherService_GenerateVoucher_SkipsOccupiedSeats(t *testing.T) {
+	service, _ := newMemoryService()
+	occupyAllBut(t, service, "2A", "7D", "15F")
+
+	response, err := service.GenerateVoucher(atrRequest())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"2A", "7D", "15F"}, response.Seats)
+
+	// Another date of the same flight is unaffected
+	req := atrRequest()
+	req.Date = "2025-07-13"
+	response, err = service.GenerateVoucher(req)
+	require.NoError(t, err)
+	assert.Len(t, response.Seats, 3)
+}
+
+func TestVoucherService_GenerateVoucher_TooFewFreeSeats(t *testing.T) {
+	service, _ := newMemoryService()
+	occupyAllBut(t, service, "2A", "7D")
+
+	_, err := service.GenerateVoucher(atrRequest())
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrNotEnoughSeats)
+	assert.Contains(t, err.Error(), "have 2 free (70 occupied)")
+
+	// Preferences only count free seats
+	req := atrRequest()
+	req.SeatCount = 2
+	req.SeatPreference = "window"
+	_, err = service.GenerateVoucher(req)
+	require.ErrorIs(t, err, ErrSeatPreferenceUnsatisfiable)
+	assert.Contains(t, err.Error(), "has 1 free window seats")
+}
+
+func TestVoucherService_RegenerateSeat_SkipsOccupiedSeats(t *testing.T) {
+	service, _ := newMemoryService()
+	occupyAllBut(t, service, "2A", "7D", "15F", "18C")
+
+	response, err := service.GenerateVoucher(atrRequest())
+	require.NoError(t, err)
+
+	// The only seat that is neither booked nor on the voucher
+	regenerated, err := service.RegenerateSeat(regenerateRequest(1))
+	require.NoError(t, err)
+	free := []string{"2A", "7D", "15F", "18C"}
+	for _, seat := range response.Seats[1:] {
+		assert.NotEqual(t, seat, regenerated.NewSeat)
+	}
+	assert.Contains(t, free, regenerated.NewSeat)
+
+	// Once the seat itself and every seat off the voucher are booked, there
+	// is nowhere to move it
+	occupyAllBut(t, service, regenerated.AllSeats[0], regenerated.AllSeats[2])
+	_, err = service.RegenerateSeat(regenerateRequest(2))
+	assert.ErrorIs(t, err, ErrNotEnoughSeats)
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"fmt"
+
+	"airline-voucher-backend/models"
+)
+
+// action is a change guarded by the service's access policy
+type action string
+
+// Actions and the least privileged role allowed to perform each
+const (
+	actionGenerate        action = "generate vouchers"
+	actionRedeem          action = "redeem seats"
+	actionRegenerate      action = "redraw seats"
+	actionVoid            action = "void seats"
+	actionUploadOccupancy action = "upload seat occupancy"
+	actionDelete          action = "delete vouchers"
+	actionExport          action = "export vouchers"
+	actionManageLayouts   action = "manage aircraft layouts"
+)
+
+var actionRoles = map[action]string{
+	actionGenerate:        models.RoleCrew,
+	actionRedeem:          models.RoleCrew,
+	actionRegenerate:      models.RoleSupervisor,
+	actionVoid:            models.RoleSupervisor,
+	actionUploadOccupancy: models.RoleSupervisor,
+	actionDelete:          models.RoleAdmin,
+	actionExport:          models.RoleAdmin,
+	actionManageLayouts:   models.RoleAdmin,
+}
+
+// WithPrincipal returns a copy of the service that checks every change
+// against the access policy for the given caller. Without a principal, as
+// when authentication is disabled or for command-line tools, every change is
+// allowed.
+func (s *VoucherService) WithPrincipal(principal *models.Principal) *VoucherService {
+	scoped := *s
+	scoped.principal = principal
+	return &scoped
+}
+
+// authorize checks that the caller may perform an action. Crew members
+// signed in with a token may only generate vouchers for the flights listed in
+// it; service accounts act on behalf of crew and are not limited to flights.
+func (s *VoucherService) authorize(act action, flightNumber string) error {
+	p := s.principal
+	if p == nil {
+		return nil
+	}
+
+	if role := actionRoles[act]; !p.HasRole(role) {
+		return fmt.Errorf("%w: %s %s may not %s (requires the %s role)", ErrForbidden, p.Role, p.ID, act, role)
+	}
+	if act == actionGenerate && !p.ServiceAccount && !p.HasRole(models.RoleSupervisor) && !p.WorksFlight(flightNumber) {
+		return fmt.Errorf("%w: crew member %s does not work flight %s", ErrForbidden, p.ID, flightNumber)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+
+	"airline-voucher-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoucherService_Policy(t *testing.T) {
+	service, _ := newMemoryService()
+	crew := service.WithPrincipal(&models.Principal{ID: "98123", Role: models.RoleCrew, Flights: []string{"GA102"}})
+	supervisor := service.WithPrincipal(&models.Principal{ID: "77001", Role: models.RoleSupervisor})
+	admin := service.WithPrincipal(&models.Principal{ID: "reporting", Role: models.RoleAdmin, ServiceAccount: true})
+
+	// Crew generate only for the flights in their token
+	_, err := crew.GenerateVoucher(validRequest())
+	require.NoError(t, err)
+	other := validRequest()
+	other.FlightNumber = "QZ200"
+	_, err = crew.GenerateVoucher(other)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, _, err = crew.GenerateVoucherIdempotent("key-1", other)
+	assert.ErrorIs(t, err, ErrForbidden)
+	results, err := crew.GenerateBatch([]models.GenerateVoucherRequest{*other}, models.BatchModePerItem)
+	require.NoError(t, err)
+	assert.ErrorIs(t, results[0].Err, ErrForbidden)
+	_, err = supervisor.GenerateVoucher(other)
+	require.NoError(t, err)
+
+	voucher, err := service.GetVoucher("GA102", "2025-07-12")
+	require.NoError(t, err)
+
+	_, err = crew.RedeemSeat(voucher.ID, &models.RedeemSeatRequest{SeatPosition: 1, RedeemedBy: "98123"})
+	require.NoError(t, err)
+	_, err = crew.RegenerateSeat(regenerateRequest(2))
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.Contains(t, err.Error(), "crew 98123 may not redraw seats (requires the supervisor role)")
+	_, err = crew.VoidSeat(voucher.ID, &models.VoidSeatRequest{SeatPosition: 2})
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = crew.SetOccupancy("GA102", "2025-07-12", []string{"1A"})
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = supervisor.RegenerateSeat(regenerateRequest(2))
+	require.NoError(t, err)
+	_, err = supervisor.VoidSeat(voucher.ID, &models.VoidSeatRequest{SeatPosition: 2})
+	require.NoError(t, err)
+	_, err = supervisor.SetOccupancy("GA102", "2025-07-12", []string{"1A"})
+	require.NoError(t, err)
+
+	noop := func(*models.Voucher) error { return nil }
+	assert.ErrorIs(t, supervisor.ExportVouchers(&models.ListVouchersRequest{}, noop), ErrForbidden)
+	assert.ErrorIs(t, supervisor.DeleteVoucher(voucher.ID, 0), ErrForbidden)
+	_, err = supervisor.ReplaceAircraftLayouts([]byte(`{"aircraft": []}`), "json")
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	assert.NoError(t, admin.ExportVouchers(&models.ListVouchersRequest{}, noop))
+	_, err = admin.SetOccupancy("GA102", "2025-07-12", nil)
+	require.NoError(t, err)
+	require.NoError(t, admin.DeleteVoucher(voucher.ID, 0))
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
+)
+
+// SetRegenerationLimits sets how many times the seats of one voucher may be
+// redrawn in total, and how many times one seat position may be redrawn.
+// Zero, the default, leaves the corresponding count unlimited.
+func (s *VoucherService) SetRegenerationLimits(perVoucher, perSeat int) error {
+	if perVoucher < 0 || perSeat < 0 {
+		return fmt.Errorf("regeneration limits must not be negative, got %d per voucher and %d per seat", perVoucher, perSeat)
+	}
+	s.regenerationLimits = repository.RegenerationLimits{PerVoucher: perVoucher, PerSeat: perSeat}
+	return nil
+}
+
+// validateRegeneration checks that a regenerate request names its crew
+// member and a known reason, and normalizes both
+func validateRegeneration(req *models.RegenerateSeatRequest) error {
+	req.CrewID = strings.TrimSpace(req.CrewID)
+	req.Reason = strings.ToLower(strings.TrimSpace(req.Reason))
+	if req.CrewID == "" || req.Reason == "" {
+		return fmt.Errorf("%w: crewId and reason are required to regenerate a seat", ErrMissingFields)
+	}
+
+	for _, reason := range models.RegenerationReasons {
+		if req.Reason == reason {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q (expected %s)", ErrInvalidReason, req.Reason, strings.Join(models.RegenerationReasons, ", "))
+}
+
+// regenerationLimitReached describes the limit a redraw of a voucher reached
+func regenerationLimitReached(voucher *models.Voucher, limit *repository.RegenerationLimitError) error {
+	if limit.Position == 0 {
+		return fmt.Errorf("%w: the seats of flight %s on %s were already redrawn %d times (limit %d)",
+			ErrRegenerationLimitReached, voucher.FlightNumber, voucher.FlightDate, limit.Count, limit.Limit)
+	}
+	return fmt.Errorf("%w: seat %d of flight %s on %s was already redrawn %d times (limit %d)",
+		ErrRegenerationLimitReached, limit.Position, voucher.FlightNumber, voucher.FlightDate, limit.Count, limit.Limit)
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/utils"
+)
+
+// DrawCheck is the outcome of replaying one recorded draw
+type DrawCheck struct {
+	Draw models.SeatDraw
+	// Replayed holds the seats the recorded seed and pool produce now
+	Replayed []string
+	// CommitmentValid reports whether the seed hashes to the published commitment
+	CommitmentValid bool
+	// Reproduced reports whether Replayed equals the recorded seats
+	Reproduced bool
+	// Err explains why the draw could not be replayed, e.g. an unknown algorithm
+	Err error
+}
+
+// OK reports whether the draw checked out
+func (c *DrawCheck) OK() bool {
+	return c.Err == nil && c.CommitmentValid && c.Reproduced
+}
+
+// DrawReplay is the outcome of replaying every recorded draw of a voucher
+type DrawReplay struct {
+	// Voucher is nil when the voucher was deleted; its draws are still
+	// replayed, but there are no seats to check against them
+	Voucher *models.Voucher
+	Draws   []DrawCheck
+	// Problems lists seats of the voucher that don't match the draw they
+	// point to
+	Problems []string
+}
+
+// OK reports whether every draw and seat checked out
+func (r *DrawReplay) OK() bool {
+	for i := range r.Draws {
+		if !r.Draws[i].OK() {
+			return false
+		}
+	}
+	return len(r.Problems) == 0
+}
+
+// ReplayVoucherDraws re-derives every recorded draw of a voucher from its
+// seed and pool, checks each seed against its published commitment, and
+// checks that every seat of the voucher came from the draw it points to
+func (s *VoucherService) ReplayVoucherDraws(id int) (*DrawReplay, error) {
+	voucher, err := s.GetVoucherByID(id)
+	if err != nil && !errors.Is(err, ErrVoucherNotFound) {
+		return nil, err
+	}
+
+	draws, err := s.repo.ListDraws(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load draws: %w", err)
+	}
+	if voucher == nil && len(draws) == 0 {
+		return nil, fmt.Errorf("%w with id %d", ErrVoucherNotFound, id)
+	}
+
+	replay := &DrawReplay{Voucher: voucher, Draws: make([]DrawCheck, len(draws))}
+	byID := make(map[int]models.SeatDraw, len(draws))
+	for i, draw := range draws {
+		replay.Draws[i] = checkDraw(draw)
+		byID[draw.ID] = draw
+	}
+
+	if voucher == nil {
+		return replay, nil
+	}
+	for i, seat := range voucher.Seats {
+		if i >= len(voucher.Commitments) || voucher.Commitments[i].DrawID == 0 {
+			replay.Problems = append(replay.Problems, fmt.Sprintf("seat %d (%s) has no recorded draw", i+1, seat))
+			continue
+		}
+
+		commitment := voucher.Commitments[i]
+		draw, ok := byID[commitment.DrawID]
+		switch {
+		case !ok:
+			replay.Problems = append(replay.Problems, fmt.Sprintf("seat %d (%s) points to missing draw %d", i+1, seat, commitment.DrawID))
+		case draw.Commitment != commitment.Commitment:
+			replay.Problems = append(replay.Problems, fmt.Sprintf("seat %d (%s) shows a different commitment than draw %d", i+1, seat, draw.ID))
+		case !containsSeat(draw.Seats, seat):
+			replay.Problems = append(replay.Problems, fmt.Sprintf("seat %d (%s) is not among the seats of draw %d", i+1, seat, draw.ID))
+		}
+	}
+
+	return replay, nil
+}
+
+// ReplayDraw re-derives the seats of a draw from a disclosed seed, e.g. for
+// a compliance officer checking a draw outside the database
+func ReplayDraw(algorithm, seedHex string, pool []string, count int) ([]string, error) {
+	seed, err := hex.DecodeString(seedHex)
+	if err != nil {
+		return nil, fmt.Errorf("seed must be hex: %w", err)
+	}
+	return utils.ReplayDraw(algorithm, seed, pool, count)
+}
+
+// checkDraw replays a single draw
+func checkDraw(draw models.SeatDraw) DrawCheck {
+	check := DrawCheck{Draw: draw}
+
+	seed, err := hex.DecodeString(draw.Seed)
+	if err != nil {
+		check.Err = fmt.Errorf("seed is not hex: %w", err)
+		return check
+	}
+	check.CommitmentValid = utils.SeedCommitment(seed) == draw.Commitment
+
+	check.Replayed, check.Err = utils.ReplayDraw(draw.Algorithm, seed, draw.Pool, len(draw.Seats))
+	if check.Err == nil {
+		check.Reproduced = equalSeats(check.Replayed, draw.Seats)
+	}
+	return check
+}
+
+func containsSeat(seats []string, seat string) bool {
+	for _, s := range seats {
+		if s == seat {
+			return true
+		}
+	}
+	return false
+}
+
+func equalSeats(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
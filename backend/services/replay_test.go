@@ -0,0 +1,117 @@
+package services
+
+import (
+	"testing"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
+	"airline-voucher-backend/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSeededService(seed int64) (*VoucherService, *models.Voucher, error) {
+	service, repo := newMemoryService()
+	service.SetSeatGenerator(utils.NewSeededSeatGenerator(seed))
+
+	if _, err := service.GenerateVoucher(validRequest()); err != nil {
+		return nil, nil, err
+	}
+	voucher, err := repo.GetByFlightDate("GA102", "2025-07-12")
+	return service, voucher, err
+}
+
+func TestVoucherService_SeededGenerator(t *testing.T) {
+	service, first, err := newSeededService(7)
+	require.NoError(t, err)
+	_, second, err := newSeededService(7)
+	require.NoError(t, err)
+
+	// The same seed yields the same seats and commitments
+	assert.Equal(t, first.Seats, second.Seats)
+	assert.Equal(t, first.Commitments, second.Commitments)
+
+	regenerated, err := service.RegenerateSeat(regenerateRequest(1))
+	require.NoError(t, err)
+
+	other, _, err := newSeededService(7)
+	require.NoError(t, err)
+	again, err := other.RegenerateSeat(regenerateRequest(1))
+	require.NoError(t, err)
+	assert.Equal(t, regenerated.NewSeat, again.NewSeat)
+}
+
+func TestVoucherService_ReplayVoucherDraws(t *testing.T) {
+	service, voucher, err := newSeededService(7)
+	require.NoError(t, err)
+	_, err = service.RegenerateSeat(regenerateRequest(3))
+	require.NoError(t, err)
+
+	replay, err := service.ReplayVoucherDraws(voucher.ID)
+	require.NoError(t, err)
+	assert.True(t, replay.OK(), "problems: %v", replay.Problems)
+	require.Len(t, replay.Draws, 2)
+	for _, check := range replay.Draws {
+		assert.True(t, check.CommitmentValid)
+		assert.True(t, check.Reproduced)
+		assert.Equal(t, check.Draw.Seats, check.Replayed)
+	}
+
+	// A disclosed seed can be replayed without the database
+	draw := replay.Draws[0].Draw
+	seats, err := ReplayDraw(draw.Algorithm, draw.Seed, draw.Pool, len(draw.Seats))
+	require.NoError(t, err)
+	assert.Equal(t, draw.Seats, seats)
+
+	_, err = ReplayDraw(draw.Algorithm, "not hex", draw.Pool, 1)
+	assert.Error(t, err)
+
+	_, err = service.ReplayVoucherDraws(voucher.ID + 1)
+	assert.ErrorIs(t, err, ErrVoucherNotFound)
+}
+
+func TestVoucherService_ReplayVoucherDraws_Deleted(t *testing.T) {
+	service, voucher, err := newSeededService(7)
+	require.NoError(t, err)
+	_, err = service.RegenerateSeat(regenerateRequest(2))
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteVoucher(voucher.ID, 0))
+
+	// A disputed draw can still be reproduced once the voucher is gone
+	replay, err := service.ReplayVoucherDraws(voucher.ID)
+	require.NoError(t, err)
+	assert.Nil(t, replay.Voucher)
+	assert.True(t, replay.OK())
+	require.Len(t, replay.Draws, 2)
+	for _, check := range replay.Draws {
+		assert.True(t, check.Reproduced)
+	}
+}
+
+func TestVoucherService_ReplayVoucherDraws_Tampered(t *testing.T) {
+	service, voucher, err := newSeededService(7)
+	require.NoError(t, err)
+
+	// A seat changed without a draw, and a draw whose seed doesn't match its
+	// published commitment
+	require.NoError(t, service.repo.UpdateSeat(voucher.ID, 0, 1, "1A", nil, nil, repository.RegenerationLimits{}))
+	forged := &models.SeatDraw{
+		Algorithm:  utils.DrawAlgorithm,
+		Seed:       "00",
+		Commitment: "forged",
+		Pool:       []string{"1B"},
+		Seats:      []string{"1B"},
+	}
+	require.NoError(t, service.repo.UpdateSeat(voucher.ID, 0, 2, "1B", forged, nil, repository.RegenerationLimits{}))
+
+	replay, err := service.ReplayVoucherDraws(voucher.ID)
+	require.NoError(t, err)
+	assert.False(t, replay.OK())
+	assert.Equal(t, []string{"seat 1 (1A) has no recorded draw"}, replay.Problems)
+
+	require.Len(t, replay.Draws, 2)
+	assert.True(t, replay.Draws[0].OK())
+	assert.False(t, replay.Draws[1].CommitmentValid)
+	assert.True(t, replay.Draws[1].Reproduced)
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
+)
+
+// MaxBatchSize is the largest number of vouchers GenerateBatch accepts at once
+const MaxBatchSize = 500
+
+// BatchResult is the outcome of one item of GenerateBatch
+type BatchResult struct {
+	Status  string          // One of the models.BatchStatus* constants
+	Voucher *models.Voucher // The created voucher, for created items
+	Err     error           // Why the item was not created, for exists and invalid items
+}
+
+// GenerateBatch generates a voucher for each request, with the same
+// validation and seat draw as GenerateVoucher. It returns one result per
+// request, in order.
+//
+// In per-item mode every valid voucher is saved on its own. In atomic mode
+// the vouchers are saved in one transaction, and only if every request is
+// valid and none of the flights has a voucher yet; otherwise nothing is
+// saved and the valid requests are reported as skipped.
+func (s *VoucherService) GenerateBatch(reqs []models.GenerateVoucherRequest, mode string) ([]BatchResult, error) {
+	if mode == "" {
+		mode = models.BatchModePerItem
+	}
+	if mode != models.BatchModeAtomic && mode != models.BatchModePerItem {
+		return nil, fmt.Errorf("%w: unknown mode %q (use %s or %s)", ErrInvalidBatch, mode, models.BatchModeAtomic, models.BatchModePerItem)
+	}
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("%w: no items", ErrInvalidBatch)
+	}
+	if len(reqs) > MaxBatchSize {
+		return nil, fmt.Errorf("%w: %d items (at most %d)", ErrInvalidBatch, len(reqs), MaxBatchSize)
+	}
+
+	results := make([]BatchResult, len(reqs))
+	firstIndex := make(map[[2]string]int, len(reqs))
+	for i := range reqs {
+		req := &reqs[i]
+		voucher, err := s.prepareVoucher(req)
+		if err != nil {
+			results[i] = BatchResult{Status: models.BatchStatusInvalid, Err: err}
+			continue
+		}
+
+		// A flight listed twice gets one voucher, from its first valid row
+		key := [2]string{req.FlightNumber, req.Date}
+		if first, ok := firstIndex[key]; ok {
+			results[i] = BatchResult{
+				Status: models.BatchStatusExists,
+				Err:    fmt.Errorf("%w for flight %s on %s (duplicate of item %d)", ErrVoucherExists, req.FlightNumber, req.Date, first),
+			}
+			continue
+		}
+		firstIndex[key] = i
+		results[i] = BatchResult{Voucher: voucher}
+	}
+
+	if mode == models.BatchModeAtomic {
+		return results, s.saveBatchAtomic(reqs, results)
+	}
+
+	for i := range results {
+		if results[i].Voucher == nil {
+			continue
+		}
+		err := s.repo.Create(results[i].Voucher)
+		if errors.Is(err, repository.ErrDuplicate) {
+			results[i] = existsResult(&reqs[i])
+			continue
+		}
+		if errors.Is(err, repository.ErrSeatTaken) {
+			results[i] = BatchResult{Status: models.BatchStatusInvalid, Err: err}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to save voucher for flight %s on %s: %w", reqs[i].FlightNumber, reqs[i].Date, err)
+		}
+		results[i].Status = models.BatchStatusCreated
+	}
+	return results, nil
+}
+
+// saveBatchAtomic saves the prepared vouchers of results in one transaction
+// if no item has failed, and otherwise marks the prepared items as skipped
+func (s *VoucherService) saveBatchAtomic(reqs []models.GenerateVoucherRequest, results []BatchResult) error {
+	// Report every flight that already has a voucher, not just the first one
+	// the transaction would trip over
+	var (
+		vouchers []*models.Voucher
+		indexes  []int
+		failed   bool
+	)
+	for i := range results {
+		if results[i].Voucher == nil {
+			failed = true
+			continue
+		}
+		existing, err := s.GetVoucher(reqs[i].FlightNumber, reqs[i].Date)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			results[i] = existsResult(&reqs[i])
+			failed = true
+			continue
+		}
+		vouchers = append(vouchers, results[i].Voucher)
+		indexes = append(indexes, i)
+	}
+
+	if !failed {
+		err := s.repo.CreateAll(vouchers)
+		var (
+			dupErr  *repository.DuplicateError
+			seatErr *repository.SeatTakenError
+		)
+		if errors.As(err, &dupErr) {
+			// Another request created one of the vouchers since the check
+			i := indexes[dupErr.Index]
+			results[i] = existsResult(&reqs[i])
+			failed = true
+		} else if errors.As(err, &seatErr) {
+			// Another voucher on the flight took one of the drawn seats
+			i := indexes[seatErr.Index]
+			results[i] = BatchResult{Status: models.BatchStatusInvalid, Err: seatErr.Err}
+			failed = true
+		} else if err != nil {
+			return fmt.Errorf("failed to save vouchers: %w", err)
+		}
+	}
+
+	for _, i := range indexes {
+		if results[i].Status != "" {
+			continue
+		}
+		if failed {
+			results[i] = BatchResult{Status: models.BatchStatusSkipped}
+		} else {
+			results[i].Status = models.BatchStatusCreated
+		}
+	}
+	return nil
+}
+
+// existsResult reports a batch item whose flight already has a voucher
+func existsResult(req *models.GenerateVoucherRequest) BatchResult {
+	return BatchResult{
+		Status: models.BatchStatusExists,
+		Err:    fmt.Errorf("%w for flight %s on %s", ErrVoucherExists, req.FlightNumber, req.Date),
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchRequests returns a schedule of four items: two new flights, an
+// invalid aircraft and a repeat of the first flight
+func batchRequests() []models.GenerateVoucherRequest {
+	items := make([]models.GenerateVoucherRequest, 4)
+	for i, flight := range []string{"GA102", "GA103", "GA104", "GA102"} {
+		items[i] = *validRequest()
+		items[i].FlightNumber = flight
+	}
+	items[2].Aircraft = "Concorde"
+	return items
+}
+
+func batchStatuses(results []BatchResult) []string {
+	statuses := make([]string, len(results))
+	for i, result := range results {
+		statuses[i] = result.Status
+	}
+	return statuses
+}
+
+func TestVoucherService_GenerateBatch_PerItem(t *testing.T) {
+	service, repo := newMemoryService()
+
+	_, err := service.GenerateVoucher(func() *models.GenerateVoucherRequest {
+		req := validRequest()
+		req.FlightNumber = "GA103"
+		return req
+	}())
+	require.NoError(t, err)
+
+	results, err := service.GenerateBatch(batchRequests(), "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		models.BatchStatusCreated,
+		models.BatchStatusExists,
+		models.BatchStatusInvalid,
+		models.BatchStatusExists,
+	}, batchStatuses(results))
+
+	assert.Len(t, results[0].Voucher.Seats, 3)
+	assert.NotZero(t, results[0].Voucher.ID)
+	assert.ErrorIs(t, results[1].Err, ErrVoucherExists)
+	assert.ErrorIs(t, results[2].Err, ErrInvalidAircraft)
+	assert.ErrorIs(t, results[3].Err, ErrVoucherExists)
+	assert.Contains(t, results[3].Err.Error(), "duplicate of item 0")
+
+	count, err := repo.Count(repository.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestVoucherService_GenerateBatch_Atomic(t *testing.T) {
+	service, repo := newMemoryService()
+
+	// One invalid item rolls back the whole batch
+	results, err := service.GenerateBatch(batchRequests()[:3], models.BatchModeAtomic)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		models.BatchStatusSkipped,
+		models.BatchStatusSkipped,
+		models.BatchStatusInvalid,
+	}, batchStatuses(results))
+
+	count, err := repo.Count(repository.ListOptions{})
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	results, err = service.GenerateBatch(batchRequests()[:2], models.BatchModeAtomic)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.BatchStatusCreated, models.BatchStatusCreated}, batchStatuses(results))
+
+	// Flights that already have vouchers are reported individually
+	results, err = service.GenerateBatch(batchRequests()[:2], models.BatchModeAtomic)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.BatchStatusExists, models.BatchStatusExists}, batchStatuses(results))
+
+	count, err = repo.Count(repository.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+// seatRaceRepo fails CreateAll as if another voucher on the flight had taken
+// a seat of the second voucher since it was drawn
+type seatRaceRepo struct {
+	*repository.MemoryVoucherRepository
+}
+
+func (r *seatRaceRepo) CreateAll(vouchers []*models.Voucher) error {
+	return &repository.SeatTakenError{
+		Index: 1,
+		Err:   fmt.Errorf("%w: %s", repository.ErrSeatTaken, vouchers[1].Seats[0]),
+	}
+}
+
+func TestVoucherService_GenerateBatch_AtomicSeatTaken(t *testing.T) {
+	repo := &seatRaceRepo{MemoryVoucherRepository: repository.NewMemoryVoucherRepository()}
+	service := NewVoucherService(repo)
+
+	// The colliding item is reported and the rest of the batch skipped
+	results, err := service.GenerateBatch(batchRequests()[:2], models.BatchModeAtomic)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.BatchStatusSkipped, models.BatchStatusInvalid}, batchStatuses(results))
+	assert.ErrorIs(t, results[1].Err, ErrSeatTaken)
+	assert.Nil(t, results[0].Voucher)
+
+	count, err := repo.Count(repository.ListOptions{})
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestVoucherService_GenerateBatch_Errors(t *testing.T) {
+	service, _ := newMemoryService()
+
+	tests := []struct {
+		name  string
+		items []models.GenerateVoucherRequest
+		mode  string
+	}{
+		{"no items", nil, ""},
+		{"too many items", make([]models.GenerateVoucherRequest, MaxBatchSize+1), ""},
+		{"unknown mode", batchRequests(), "all-or-some"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.GenerateBatch(tt.items, tt.mode)
+			assert.ErrorIs(t, err, ErrInvalidBatch)
+		})
+	}
+}
+
+func TestVoucherService_GenerateBatch_MissingFields(t *testing.T) {
+	service, _ := newMemoryService()
+
+	item := *validRequest()
+	item.Name = ""
+	results, err := service.GenerateBatch([]models.GenerateVoucherRequest{item}, "")
+	require.NoError(t, err)
+	assert.Equal(t, models.BatchStatusInvalid, results[0].Status)
+	assert.ErrorIs(t, results[0].Err, ErrMissingFields)
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
+	"airline-voucher-backend/utils"
+)
+
+// Page sizes for ListVouchers
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 200
+)
+
+// defaultListSort is used when a list request does not name a sort order
+const defaultListSort = "flightDate"
+
+// listSortFields maps the sort names accepted by the API to repository columns
+var listSortFields = map[string]repository.SortField{
+	"id":           repository.SortByID,
+	"flightDate":   repository.SortByFlightDate,
+	"flightNumber": repository.SortByFlightNumber,
+	"createdAt":    repository.SortByCreatedAt,
+}
+
+// listCursor is the decoded form of an opaque page cursor. It records the
+// sort it was issued for so it cannot be replayed against another ordering.
+type listCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"i"`
+}
+
+// ListVouchers returns one page of vouchers matching the request's filters,
+// with the total number of matches and a cursor for the next page
+func (s *VoucherService) ListVouchers(req *models.ListVouchersRequest) (*models.ListVouchersResponse, error) {
+	if req.Sort == "" {
+		req.Sort = defaultListSort
+	}
+
+	opts, err := listOptions(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Cursor != "" {
+		cursor, err := decodeListCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != req.Sort {
+			return nil, fmt.Errorf("%w: cursor was issued for sort %q, not %q", ErrInvalidQuery, cursor.Sort, req.Sort)
+		}
+		opts.After = &repository.Cursor{Value: cursor.Value, ID: cursor.ID}
+	}
+
+	// Fetch one extra voucher to learn whether another page follows
+	limit := opts.Limit
+	opts.Limit = limit + 1
+
+	vouchers, err := s.repo.List(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vouchers: %w", err)
+	}
+
+	total, err := s.repo.Count(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count vouchers: %w", err)
+	}
+
+	for i := range vouchers {
+		s.applyExpiry(&vouchers[i])
+	}
+
+	response := &models.ListVouchersResponse{
+		Vouchers: vouchers,
+		Total:    total,
+	}
+
+	if len(vouchers) > limit {
+		response.Vouchers = vouchers[:limit]
+		last := response.Vouchers[limit-1]
+		response.NextCursor = encodeListCursor(listCursor{
+			Sort:  req.Sort,
+			Value: repository.SortValue(&last, opts.Sort),
+			ID:    last.ID,
+		})
+	}
+
+	return response, nil
+}
+
+// exportBatchSize is how many vouchers ExportVouchers loads per query
+var exportBatchSize = 500
+
+// ExportVouchers calls fn for every voucher matching the request's filters,
+// in the request's sort order. Vouchers are loaded in batches of keyset
+// pages, so memory use does not depend on how many match. The request's
+// cursor and limit are ignored. An error from fn stops the export.
+func (s *VoucherService) ExportVouchers(req *models.ListVouchersRequest, fn func(voucher *models.Voucher) error) error {
+	if err := s.authorize(actionExport, ""); err != nil {
+		return err
+	}
+	if req.Sort == "" {
+		req.Sort = defaultListSort
+	}
+	req.Cursor = ""
+	req.Limit = 0
+
+	opts, err := listOptions(req)
+	if err != nil {
+		return err
+	}
+	opts.Limit = exportBatchSize
+
+	for {
+		vouchers, err := s.repo.List(opts)
+		if err != nil {
+			return fmt.Errorf("failed to list vouchers: %w", err)
+		}
+
+		for i := range vouchers {
+			s.applyExpiry(&vouchers[i])
+			if err := fn(&vouchers[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(vouchers) < opts.Limit {
+			return nil
+		}
+		last := vouchers[len(vouchers)-1]
+		opts.After = &repository.Cursor{Value: repository.SortValue(&last, opts.Sort), ID: last.ID}
+	}
+}
+
+// listOptions validates a list request and converts its filters, sort and
+// page size to repository options
+func listOptions(req *models.ListVouchersRequest) (repository.ListOptions, error) {
+	opts := repository.ListOptions{
+		DateFrom:     req.DateFrom,
+		DateTo:       req.DateTo,
+		FlightPrefix: strings.TrimSpace(req.FlightNumber),
+		AircraftType: req.Aircraft,
+		CrewID:       req.CrewID,
+		Limit:        req.Limit,
+	}
+
+	for _, date := range []string{req.DateFrom, req.DateTo} {
+		if date != "" && !utils.ValidateDateFormat(date) {
+			return opts, fmt.Errorf("%w: %s (expected YYYY-MM-DD)", ErrInvalidDate, date)
+		}
+	}
+	if req.DateFrom != "" && req.DateTo != "" && req.DateFrom > req.DateTo {
+		return opts, fmt.Errorf("%w: dateFrom %s is after dateTo %s", ErrInvalidQuery, req.DateFrom, req.DateTo)
+	}
+
+	field, ok := listSortFields[strings.TrimPrefix(req.Sort, "-")]
+	if !ok {
+		return opts, fmt.Errorf("%w: unknown sort %q (use id, flightDate, flightNumber or createdAt, optionally prefixed with -)", ErrInvalidQuery, req.Sort)
+	}
+	opts.Sort = field
+	opts.Descending = strings.HasPrefix(req.Sort, "-")
+
+	if opts.Limit == 0 {
+		opts.Limit = DefaultListLimit
+	}
+	if opts.Limit < 1 || opts.Limit > MaxListLimit {
+		return opts, fmt.Errorf("%w: limit %d (must be between 1 and %d)", ErrInvalidQuery, opts.Limit, MaxListLimit)
+	}
+
+	return opts, nil
+}
+
+// encodeListCursor turns a cursor into an opaque URL-safe token
+func encodeListCursor(cursor listCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeListCursor parses a token produced by encodeListCursor
+func decodeListCursor(token string) (listCursor, error) {
+	var cursor listCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = json.Unmarshal(data, &cursor)
+	}
+	if err != nil || cursor.ID < 1 {
+		return cursor, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+
+	return cursor, nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"airline-voucher-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newListService returns a service holding vouchers for three flights on
+// each of three days
+func newListService(t *testing.T) *VoucherService {
+	service, _ := newMemoryService()
+	for _, date := range []string{"2025-07-11", "2025-07-12", "2025-07-13"} {
+		for _, flight := range []string{"GA102", "GA103", "QZ200"} {
+			req := validRequest()
+			req.FlightNumber = flight
+			req.Date = date
+			_, err := service.GenerateVoucher(req)
+			require.NoError(t, err)
+		}
+	}
+	return service
+}
+
+func TestVoucherService_ListVouchers_Pages(t *testing.T) {
+	service := newListService(t)
+
+	req := &models.ListVouchersRequest{FlightNumber: "GA", Sort: "-flightDate", Limit: 4}
+	var flights []string
+	pages := 0
+	for {
+		page, err := service.ListVouchers(req)
+		require.NoError(t, err)
+		assert.Equal(t, 6, page.Total)
+		pages++
+
+		for _, voucher := range page.Vouchers {
+			flights = append(flights, voucher.FlightNumber+"@"+voucher.FlightDate)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		req.Cursor = page.NextCursor
+	}
+
+	assert.Equal(t, 2, pages)
+	assert.Equal(t, []string{
+		"GA103@2025-07-13", "GA102@2025-07-13",
+		"GA103@2025-07-12", "GA102@2025-07-12",
+		"GA103@2025-07-11", "GA102@2025-07-11",
+	}, flights)
+}
+
+func TestVoucherService_ListVouchers_Defaults(t *testing.T) {
+	service := newListService(t)
+
+	page, err := service.ListVouchers(&models.ListVouchersRequest{DateFrom: "2025-07-12", DateTo: "2025-07-12"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, page.Total)
+	assert.Len(t, page.Vouchers, 3)
+	assert.Empty(t, page.NextCursor)
+	for _, voucher := range page.Vouchers {
+		assert.Equal(t, "2025-07-12", voucher.FlightDate)
+		assert.Len(t, voucher.Seats, 3)
+	}
+}
+
+func TestVoucherService_ListVouchers_InvalidQuery(t *testing.T) {
+	service := newListService(t)
+
+	first, err := service.ListVouchers(&models.ListVouchersRequest{Sort: "flightNumber", Limit: 1})
+	require.NoError(t, err)
+	require.NotEmpty(t, first.NextCursor)
+
+	tests := []struct {
+		name    string
+		req     models.ListVouchersRequest
+		wantErr error
+	}{
+		{"bad date", models.ListVouchersRequest{DateFrom: "12/07/2025"}, ErrInvalidDate},
+		{"reversed range", models.ListVouchersRequest{DateFrom: "2025-07-13", DateTo: "2025-07-11"}, ErrInvalidQuery},
+		{"unknown sort", models.ListVouchersRequest{Sort: "seat"}, ErrInvalidQuery},
+		{"limit too large", models.ListVouchersRequest{Limit: MaxListLimit + 1}, ErrInvalidQuery},
+		{"negative limit", models.ListVouchersRequest{Limit: -1}, ErrInvalidQuery},
+		{"malformed cursor", models.ListVouchersRequest{Cursor: "not-a-cursor"}, ErrInvalidQuery},
+		{"cursor for another sort", models.ListVouchersRequest{Sort: "flightDate", Cursor: first.NextCursor}, ErrInvalidQuery},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.ListVouchers(&tt.req)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestVoucherService_ExportVouchers(t *testing.T) {
+	service := newListService(t)
+
+	// Cross several batch boundaries, including an exact multiple
+	defer func(size int) { exportBatchSize = size }(exportBatchSize)
+	for _, size := range []int{2, 3, 500} {
+		exportBatchSize = size
+
+		var flights []string
+		err := service.ExportVouchers(&models.ListVouchersRequest{Sort: "flightNumber", Cursor: "ignored", Limit: 1}, func(voucher *models.Voucher) error {
+			flights = append(flights, voucher.FlightNumber+"@"+voucher.FlightDate)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"GA102@2025-07-11", "GA102@2025-07-12", "GA102@2025-07-13",
+			"GA103@2025-07-11", "GA103@2025-07-12", "GA103@2025-07-13",
+			"QZ200@2025-07-11", "QZ200@2025-07-12", "QZ200@2025-07-13",
+		}, flights, "batch size %d", size)
+	}
+}
+
+func TestVoucherService_ExportVouchers_Errors(t *testing.T) {
+	service := newListService(t)
+
+	err := service.ExportVouchers(&models.ListVouchersRequest{Sort: "seat"}, func(*models.Voucher) error {
+		t.Fatal("no voucher should be exported for an invalid query")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+
+	stop := errors.New("stop")
+	calls := 0
+	err = service.ExportVouchers(&models.ListVouchersRequest{}, func(*models.Voucher) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
@@ -1,137 +1,332 @@
 package services
 
 import (
-	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
 	"airline-voucher-backend/utils"
 )
 
 // VoucherService handles voucher-related business logic
 type VoucherService struct {
-	db models.Database
+	repo      repository.VoucherRepository
+	seatCount int
+	generator *utils.SeatGenerator
+
+	seatExpiry        time.Duration
+	idempotencyKeyTTL time.Duration
+	clock             func() time.Time
+	requestID         string
+	principal         *models.Principal
+
+	regenerationLimits repository.RegenerationLimits
 }
 
 // NewVoucherService creates a new VoucherService instance
-func NewVoucherService(db models.Database) *VoucherService {
+func NewVoucherService(repo repository.VoucherRepository) *VoucherService {
 	return &VoucherService{
-		db: db,
+		repo:      repo,
+		seatCount: utils.DefaultSeatCount,
+		generator: utils.NewSeatGenerator(),
+	}
+}
+
+// SetSeatGenerator replaces the generator that draws seats, e.g. with a
+// seeded generator so tests get exact seats
+func (s *VoucherService) SetSeatGenerator(generator *utils.SeatGenerator) {
+	s.generator = generator
+}
+
+// seatGenerator returns the configured generator, falling back to an
+// entropy-seeded one for a zero-value service
+func (s *VoucherService) seatGenerator() *utils.SeatGenerator {
+	if s.generator == nil {
+		return utils.NewSeatGenerator()
 	}
+	return s.generator
+}
+
+// SetDefaultSeatCount sets the number of seats drawn when a request does not
+// specify a seat count
+func (s *VoucherService) SetDefaultSeatCount(count int) error {
+	if count < 1 || count > utils.MaxSeatCount {
+		return fmt.Errorf("%w: %d (must be between 1 and %d)", ErrInvalidSeatCount, count, utils.MaxSeatCount)
+	}
+	s.seatCount = count
+	return nil
+}
+
+// defaultSeatCount returns the configured seat count, falling back to the
+// package default for a zero-value service
+func (s *VoucherService) defaultSeatCount() int {
+	if s.seatCount == 0 {
+		return utils.DefaultSeatCount
+	}
+	return s.seatCount
 }
 
 // CheckVoucherExists checks if a voucher already exists for the given flight and date
 func (s *VoucherService) CheckVoucherExists(flightNumber, date string) (bool, error) {
-	query := `SELECT COUNT(*) FROM vouchers WHERE flight_number = ? AND flight_date = ?`
-
-	var count int
-	err := s.db.QueryRow(query, flightNumber, date).Scan(&count)
+	voucher, err := s.GetVoucher(flightNumber, date)
 	if err != nil {
 		return false, fmt.Errorf("failed to check voucher existence: %w", err)
 	}
 
-	return count > 0, nil
+	return voucher != nil, nil
 }
 
-// GenerateVoucher generates a new voucher with 3 random seats
+// GenerateVoucher generates a new voucher with the requested number of random seats
 func (s *VoucherService) GenerateVoucher(req *models.GenerateVoucherRequest) (*models.GenerateVoucherResponse, error) {
+	voucher, err := s.prepareVoucher(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Save voucher; the repository rejects a second voucher for the same
+	// flight and date atomically, so concurrent requests cannot both insert
+	err = s.repo.Create(voucher)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, fmt.Errorf("%w for flight %s on %s", ErrVoucherExists, req.FlightNumber, req.Date)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save voucher: %w", err)
+	}
+
+	return &models.GenerateVoucherResponse{
+		Success: true,
+		Seats:   voucher.Seats,
+	}, nil
+}
+
+// prepareVoucher validates a generate request, fills in its defaults and
+// draws the seats of the voucher it describes without saving it
+func (s *VoucherService) prepareVoucher(req *models.GenerateVoucherRequest) (*models.Voucher, error) {
+	// Validate required fields
+	if req.Name == "" || req.ID == "" || req.FlightNumber == "" || req.Date == "" || req.Aircraft == "" {
+		return nil, fmt.Errorf("%w: name, id, flightNumber, date and aircraft are required", ErrMissingFields)
+	}
+	if err := s.authorize(actionGenerate, req.FlightNumber); err != nil {
+		return nil, err
+	}
+
 	// Validate aircraft type
 	if !utils.ValidateAircraftType(req.Aircraft) {
-		return nil, fmt.Errorf("invalid aircraft type: %s", req.Aircraft)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAircraft, req.Aircraft)
 	}
 
 	// Validate date format
 	if !utils.ValidateDateFormat(req.Date) {
-		return nil, fmt.Errorf("invalid date format: %s (expected YYYY-MM-DD)", req.Date)
+		return nil, fmt.Errorf("%w: %s (expected YYYY-MM-DD)", ErrInvalidDate, req.Date)
+	}
+
+	// Validate seat count
+	if req.SeatCount == 0 {
+		req.SeatCount = s.defaultSeatCount()
+	}
+	if req.SeatCount < 1 || req.SeatCount > utils.MaxSeatCount {
+		return nil, fmt.Errorf("%w: %d (must be between 1 and %d)", ErrInvalidSeatCount, req.SeatCount, utils.MaxSeatCount)
+	}
+
+	// Validate cabin class against the aircraft's cabin zones
+	if req.CabinClass != "" {
+		aircraft, err := utils.GetAircraftConfig(req.Aircraft)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAircraft, req.Aircraft)
+		}
+
+		cabinClass, ok := aircraft.LookupCabinClass(req.CabinClass)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCabinClass, req.CabinClass)
+		}
+		req.CabinClass = cabinClass
 	}
 
-	// Check if voucher already exists
-	exists, err := s.CheckVoucherExists(req.FlightNumber, req.Date)
+	// Validate the seat preference or mix
+	constraint, err := seatConstraint(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check voucher existence: %w", err)
+		return nil, err
 	}
 
-	if exists {
-		return nil, fmt.Errorf("voucher already exists for flight %s on %s", req.FlightNumber, req.Date)
+	// Leave out seats taken by booked passengers or by other vouchers
+	constraint.Excluded, err = s.unavailableSeats(req.FlightNumber, req.Date)
+	if err != nil {
+		return nil, err
 	}
 
 	// Generate random seats
-	seats, err := utils.GenerateRandomSeats(req.Aircraft)
+	draws, err := s.seatGenerator().DrawPreferredSeats(req.Aircraft, req.CabinClass, req.SeatCount, constraint)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate seats: %w", err)
 	}
 
-	// Save voucher to database
-	err = s.saveVoucher(req, seats)
-	if err != nil {
-		return nil, fmt.Errorf("failed to save voucher: %w", err)
+	createdAt := models.GetCurrentTimestamp()
+	seatDraws := make([]*models.SeatDraw, len(draws))
+	for i, draw := range draws {
+		seatDraws[i] = seatDraw(draw, createdAt)
 	}
-
-	return &models.GenerateVoucherResponse{
-		Success: true,
-		Seats:   seats,
+	return &models.Voucher{
+		CrewName:     req.Name,
+		CrewID:       req.ID,
+		FlightNumber: req.FlightNumber,
+		FlightDate:   req.Date,
+		AircraftType: req.Aircraft,
+		CabinClass:   req.CabinClass,
+		CreatedAt:    createdAt,
+		Seats:        utils.DrawnSeats(draws),
+		Draws:        seatDraws,
+		Events: []*models.VoucherEvent{
+			s.newEvent(models.EventCreate, 0, req.ID, "", seatList(utils.DrawnSeats(draws))),
+		},
 	}, nil
 }
 
-// saveVoucher saves the voucher to the database
-func (s *VoucherService) saveVoucher(req *models.GenerateVoucherRequest, seats []string) error {
-	query := `
-		INSERT INTO vouchers (crew_name, crew_id, flight_number, flight_date, aircraft_type, seat1, seat2, seat3, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
+// seatConstraint normalizes the seat preference and mix of a generate
+// request and returns them as a constraint
+func seatConstraint(req *models.GenerateVoucherRequest) (utils.SeatConstraint, error) {
+	req.SeatPreference = strings.ToLower(strings.TrimSpace(req.SeatPreference))
 
-	currentTime := models.GetCurrentTimestamp()
+	var minimums map[string]int
+	if len(req.SeatMix) > 0 {
+		minimums = make(map[string]int, len(req.SeatMix))
+		for position, n := range req.SeatMix {
+			minimums[strings.ToLower(strings.TrimSpace(position))] += n
+		}
+		req.SeatMix = minimums
+	}
+
+	constraint := utils.SeatConstraint{Preference: req.SeatPreference, Minimums: minimums}
+	if err := constraint.Validate(req.SeatCount); err != nil {
+		return utils.SeatConstraint{}, fmt.Errorf("%w: %v", ErrInvalidSeatPreference, err)
+	}
+	return constraint, nil
+}
+
+// unavailableSeats returns the seats of a flight a new voucher must not get:
+// seats held by other vouchers and seats taken by booked passengers
+func (s *VoucherService) unavailableSeats(flightNumber, date string) ([]string, error) {
+	assigned, err := s.assignedSeats(flightNumber, date)
+	if err != nil {
+		return nil, err
+	}
+	occupied, err := s.occupiedSeats(flightNumber, date)
+	if err != nil {
+		return nil, err
+	}
+	return append(assigned, occupied...), nil
+}
 
-	_, err := s.db.Exec(
-		query,
-		req.Name,
-		req.ID,
-		req.FlightNumber,
-		req.Date,
-		req.Aircraft,
-		seats[0],
-		seats[1],
-		seats[2],
-		currentTime,
-	)
+// assignedSeats returns the seats of a flight held by any voucher
+func (s *VoucherService) assignedSeats(flightNumber, date string) ([]string, error) {
+	seats, err := s.repo.AssignedSeats(flightNumber, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get assigned seats: %w", err)
+	}
+	return seats, nil
+}
 
-	return err
+// seatDraw converts a draw into the record stored with the voucher
+func seatDraw(draw *utils.Draw, createdAt string) *models.SeatDraw {
+	return &models.SeatDraw{
+		Algorithm:  draw.Algorithm,
+		Seed:       hex.EncodeToString(draw.Seed),
+		Commitment: draw.Commitment(),
+		Pool:       draw.Pool,
+		Seats:      draw.Seats,
+		CreatedAt:  createdAt,
+	}
 }
 
-// GetVoucher retrieves an existing voucher for the given flight and date
+// GetVoucher retrieves an existing voucher for the given flight and date.
+// It returns nil without an error when no voucher exists.
 func (s *VoucherService) GetVoucher(flightNumber, date string) (*models.Voucher, error) {
-	query := `SELECT id, crew_name, crew_id, flight_number, flight_date, aircraft_type, seat1, seat2, seat3, created_at 
-			  FROM vouchers WHERE flight_number = ? AND flight_date = ? LIMIT 1`
-
-	var voucher models.Voucher
-	err := s.db.QueryRow(query, flightNumber, date).Scan(
-		&voucher.ID,
-		&voucher.CrewName,
-		&voucher.CrewID,
-		&voucher.FlightNumber,
-		&voucher.FlightDate,
-		&voucher.AircraftType,
-		&voucher.Seat1,
-		&voucher.Seat2,
-		&voucher.Seat3,
-		&voucher.CreatedAt,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // Voucher not found
-		}
+	voucher, err := s.repo.GetByFlightDate(flightNumber, date)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil // Voucher not found
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get voucher: %w", err)
+	}
+
+	s.applyExpiry(voucher)
+	return voucher, nil
+}
+
+// GetVoucherByID retrieves a voucher by its ID, or returns ErrVoucherNotFound
+func (s *VoucherService) GetVoucherByID(id int) (*models.Voucher, error) {
+	voucher, err := s.repo.GetByID(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w with id %d", ErrVoucherNotFound, id)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to get voucher: %w", err)
 	}
 
-	return &voucher, nil
+	s.applyExpiry(voucher)
+	return voucher, nil
+}
+
+// DeleteVoucher removes a voucher and its seats, freeing the flight and date
+// for a new draw. A non-zero version must be the voucher's current version.
+// The voucher's history is kept.
+func (s *VoucherService) DeleteVoucher(id, version int) error {
+	if err := s.authorize(actionDelete, ""); err != nil {
+		return err
+	}
+
+	voucher, err := s.GetVoucherByID(id)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(voucher, version); err != nil {
+		return err
+	}
+
+	err = s.repo.Delete(id, version, s.newEvent(models.EventDelete, 0, s.principalID(), seatList(voucher.Seats), ""))
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w with id %d", ErrVoucherNotFound, id)
+	}
+	if errors.Is(err, repository.ErrVersionChanged) {
+		return versionChanged(id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete voucher: %w", err)
+	}
+
+	return nil
+}
+
+// checkVersion rejects a voucher that no longer has the version the caller
+// expects. A zero version accepts any. The repository checks the version
+// again inside the write, so a change that lands in between is caught too.
+func checkVersion(voucher *models.Voucher, version int) error {
+	if version != 0 && voucher.Version != version {
+		return fmt.Errorf("%w: voucher %d is at version %d, not %d", ErrPreconditionFailed, voucher.ID, voucher.Version, version)
+	}
+	return nil
+}
+
+// versionChanged reports a write that lost the race with another change to
+// the voucher
+func versionChanged(id int) error {
+	return fmt.Errorf("%w: voucher %d was changed by another request", ErrPreconditionFailed, id)
 }
 
 // RegenerateSeat regenerates a single seat for an existing voucher
 func (s *VoucherService) RegenerateSeat(req *models.RegenerateSeatRequest) (*models.RegenerateSeatResponse, error) {
 	// Validate seat position
-	if req.SeatPosition < 1 || req.SeatPosition > 3 {
-		return nil, fmt.Errorf("invalid seat position: %d (must be 1, 2, or 3)", req.SeatPosition)
+	if req.SeatPosition < 1 {
+		return nil, fmt.Errorf("%w: %d (must be at least 1)", ErrInvalidSeatPosition, req.SeatPosition)
+	}
+	if err := validateRegeneration(req); err != nil {
+		return nil, err
+	}
+	if err := s.authorize(actionRegenerate, req.FlightNumber); err != nil {
+		return nil, err
 	}
 
 	// Get existing voucher
@@ -141,60 +336,85 @@ func (s *VoucherService) RegenerateSeat(req *models.RegenerateSeatRequest) (*mod
 	}
 
 	if voucher == nil {
-		return nil, fmt.Errorf("no voucher found for flight %s on %s", req.FlightNumber, req.Date)
+		return nil, fmt.Errorf("%w for flight %s on %s", ErrVoucherNotFound, req.FlightNumber, req.Date)
+	}
+	if err := checkVersion(voucher, req.Version); err != nil {
+		return nil, err
 	}
 
 	// Get current seats
-	currentSeats := []string{voucher.Seat1, voucher.Seat2, voucher.Seat3}
+	currentSeats := voucher.Seats
+	if req.SeatPosition > len(currentSeats) {
+		return nil, fmt.Errorf("%w: %d (voucher has %d seats)", ErrInvalidSeatPosition, req.SeatPosition, len(currentSeats))
+	}
+	if status := seatStatus(voucher, req.SeatPosition-1); status != models.SeatStatusIssued {
+		return nil, fmt.Errorf("%w: seat %s is %s and cannot be redrawn", ErrInvalidSeatTransition, currentSeats[req.SeatPosition-1], status)
+	}
 
-	// Generate all possible seats for this aircraft
-	allPossibleSeats, err := utils.GetAllSeats(voucher.AircraftType)
+	// Generate all possible seats in the voucher's cabin
+	allPossibleSeats, err := utils.GetCabinSeats(voucher.AircraftType, voucher.CabinClass)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get available seats: %w", err)
 	}
 
-	// Filter out currently assigned seats (except the one we're regenerating)
-	var availableSeats []string
+	// Seats held by any voucher of the flight, except the seat being
+	// replaced, and seats taken by booked passengers are never drawn
+	assigned, err := s.assignedSeats(voucher.FlightNumber, voucher.FlightDate)
+	if err != nil {
+		return nil, err
+	}
+	occupied, err := s.occupiedSeats(voucher.FlightNumber, voucher.FlightDate)
+	if err != nil {
+		return nil, err
+	}
+	isTaken := make(map[string]bool, len(assigned)+len(occupied))
+	for _, seat := range assigned {
+		isTaken[seat] = seat != currentSeats[req.SeatPosition-1]
+	}
+	for i, seat := range currentSeats {
+		if i != req.SeatPosition-1 {
+			isTaken[seat] = true
+		}
+	}
+	for _, seat := range occupied {
+		isTaken[seat] = true
+	}
 
+	var availableSeats []string
 	for _, seat := range allPossibleSeats {
-		isOccupied := false
-		for i, currentSeat := range currentSeats {
-			if seat == currentSeat && i != (req.SeatPosition-1) {
-				isOccupied = true
-				break
-			}
-		}
-		if !isOccupied {
+		if !isTaken[seat] {
 			availableSeats = append(availableSeats, seat)
 		}
 	}
 
 	if len(availableSeats) == 0 {
-		return nil, fmt.Errorf("no available seats to regenerate")
+		return nil, fmt.Errorf("%w to regenerate: every other seat is assigned or occupied", ErrNotEnoughSeats)
 	}
 
 	// Generate a new random seat from available options
-	newSeat, err := utils.GenerateRandomSeat(availableSeats)
+	draw, err := s.seatGenerator().DrawSeat(availableSeats)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate new seat: %w", err)
 	}
-
-	// Update the specific seat in the database
-	var updateQuery string
-	var seatColumn string
-
-	switch req.SeatPosition {
-	case 1:
-		seatColumn = "seat1"
-	case 2:
-		seatColumn = "seat2"
-	case 3:
-		seatColumn = "seat3"
+	newSeat := draw.Seats[0]
+
+	// Update the specific seat in the database, recording the draw
+	event := s.newEvent(models.EventRegenerate, req.SeatPosition, req.CrewID, currentSeats[req.SeatPosition-1], newSeat)
+	event.Reason = req.Reason
+	err = s.repo.UpdateSeat(voucher.ID, req.Version, req.SeatPosition, newSeat, seatDraw(draw, models.GetCurrentTimestamp()), event, s.regenerationLimits)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w for flight %s on %s", ErrVoucherNotFound, req.FlightNumber, req.Date)
+	}
+	if errors.Is(err, repository.ErrVersionChanged) {
+		return nil, versionChanged(voucher.ID)
+	}
+	if errors.Is(err, repository.ErrStatusChanged) {
+		return nil, fmt.Errorf("%w: seat %s is no longer issued and cannot be redrawn", ErrInvalidSeatTransition, currentSeats[req.SeatPosition-1])
+	}
+	var limitErr *repository.RegenerationLimitError
+	if errors.As(err, &limitErr) {
+		return nil, regenerationLimitReached(voucher, limitErr)
 	}
-
-	updateQuery = fmt.Sprintf("UPDATE vouchers SET %s = ? WHERE flight_number = ? AND flight_date = ?", seatColumn)
-
-	_, err = s.db.Exec(updateQuery, newSeat, req.FlightNumber, req.Date)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update seat: %w", err)
 	}
@@ -1,9 +1,19 @@
 package services
 
 import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 
+	"airline-voucher-backend/config"
 	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
+	"airline-voucher-backend/utils"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -23,6 +33,7 @@ func TestVoucherService_ValidationLogic(t *testing.T) {
 		request     *models.GenerateVoucherRequest
 		expectError bool
 		errorMsg    string
+		expectedErr error
 	}{
 		{
 			name: "Invalid aircraft type",
@@ -35,6 +46,7 @@ func TestVoucherService_ValidationLogic(t *testing.T) {
 			},
 			expectError: true,
 			errorMsg:    "invalid aircraft type",
+			expectedErr: ErrInvalidAircraft,
 		},
 		{
 			name: "Invalid date format",
@@ -47,6 +59,7 @@ func TestVoucherService_ValidationLogic(t *testing.T) {
 			},
 			expectError: true,
 			errorMsg:    "invalid date format",
+			expectedErr: ErrInvalidDate,
 		},
 	}
 
@@ -60,9 +73,566 @@ func TestVoucherService_ValidationLogic(t *testing.T) {
 				if tt.errorMsg != "" {
 					assert.Contains(t, err.Error(), tt.errorMsg)
 				}
+				if tt.expectedErr != nil {
+					assert.ErrorIs(t, err, tt.expectedErr)
+				}
 			} else {
 				assert.NoError(t, err)
 			}
 		})
 	}
 }
+
+func TestVoucherService_InvalidCabinClass(t *testing.T) {
+	service := NewVoucherService(nil)
+	_, err := service.GenerateVoucher(&models.GenerateVoucherRequest{
+		Name:         "John Doe",
+		ID:           "12345",
+		FlightNumber: "GA102",
+		Date:         "2025-07-12",
+		Aircraft:     "Airbus 320",
+		CabinClass:   "first",
+	})
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrInvalidCabinClass)
+}
+
+func TestVoucherService_SeatCountValidation(t *testing.T) {
+	service := NewVoucherService(nil)
+
+	assert.Error(t, service.SetDefaultSeatCount(0))
+	assert.Error(t, service.SetDefaultSeatCount(51))
+	assert.NoError(t, service.SetDefaultSeatCount(10))
+
+	_, err := service.GenerateVoucher(&models.GenerateVoucherRequest{
+		Name:         "John Doe",
+		ID:           "12345",
+		FlightNumber: "GA102",
+		Date:         "2025-07-12",
+		Aircraft:     "ATR",
+		SeatCount:    -1,
+	})
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrInvalidSeatCount)
+}
+
+func newTestDB(t *testing.T) *sql.DB {
+	db, err := config.InitDB(filepath.Join(t.TempDir(), "vouchers.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newMemoryService() (*VoucherService, *repository.MemoryVoucherRepository) {
+	repo := repository.NewMemoryVoucherRepository()
+	return NewVoucherService(repo), repo
+}
+
+// regenerateRequest redraws a seat of the GA102 voucher of validRequest
+func regenerateRequest(position int) *models.RegenerateSeatRequest {
+	return &models.RegenerateSeatRequest{
+		FlightNumber: "GA102",
+		Date:         "2025-07-12",
+		SeatPosition: position,
+		CrewID:       "98123",
+		Reason:       models.ReasonPassengerRequest,
+	}
+}
+
+func validRequest() *models.GenerateVoucherRequest {
+	return &models.GenerateVoucherRequest{
+		Name:         "Sarah",
+		ID:           "98123",
+		FlightNumber: "GA102",
+		Date:         "2025-07-12",
+		Aircraft:     "Airbus 320",
+	}
+}
+
+func TestVoucherService_GenerateVoucher(t *testing.T) {
+	service, repo := newMemoryService()
+
+	req := validRequest()
+	req.CabinClass = "Business"
+	req.SeatCount = 5
+
+	response, err := service.GenerateVoucher(req)
+	require.NoError(t, err)
+	assert.True(t, response.Success)
+	assert.Len(t, response.Seats, 5)
+
+	stored, err := repo.GetByFlightDate("GA102", "2025-07-12")
+	require.NoError(t, err)
+	assert.Equal(t, "Sarah", stored.CrewName)
+	assert.Equal(t, "98123", stored.CrewID)
+	assert.Equal(t, "Airbus 320", stored.AircraftType)
+	assert.Equal(t, "business", stored.CabinClass, "cabin class is stored in its configured spelling")
+	assert.Equal(t, response.Seats, stored.Seats)
+	assert.NotEmpty(t, stored.CreatedAt)
+
+	businessSeats, err := utils.GetCabinSeats("Airbus 320", "business")
+	require.NoError(t, err)
+	for _, seat := range stored.Seats {
+		assert.Contains(t, businessSeats, seat)
+	}
+}
+
+func TestVoucherService_GenerateVoucher_DefaultSeatCount(t *testing.T) {
+	service, _ := newMemoryService()
+
+	response, err := service.GenerateVoucher(validRequest())
+	require.NoError(t, err)
+	assert.Len(t, response.Seats, utils.DefaultSeatCount)
+
+	require.NoError(t, service.SetDefaultSeatCount(1))
+	req := validRequest()
+	req.FlightNumber = "GA103"
+
+	response, err = service.GenerateVoucher(req)
+	require.NoError(t, err)
+	assert.Len(t, response.Seats, 1)
+}
+
+func TestVoucherService_GenerateVoucher_Duplicate(t *testing.T) {
+	service, _ := newMemoryService()
+
+	_, err := service.GenerateVoucher(validRequest())
+	require.NoError(t, err)
+
+	_, err = service.GenerateVoucher(validRequest())
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrVoucherExists)
+	assert.Equal(t, "voucher already exists for flight GA102 on 2025-07-12", err.Error())
+}
+
+func TestVoucherService_GenerateVoucher_NotEnoughSeats(t *testing.T) {
+	service, repo := newMemoryService()
+
+	req := validRequest()
+	req.CabinClass = "business"
+	req.SeatCount = 13 // the business cabin has 12 seats
+
+	_, err := service.GenerateVoucher(req)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrNotEnoughSeats)
+
+	vouchers, err := repo.List(repository.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, vouchers)
+}
+
+func TestVoucherService_GenerateVoucher_SeatPreference(t *testing.T) {
+	service, repo := newMemoryService()
+	aircraft, err := utils.GetAircraftConfig("Airbus 320")
+	require.NoError(t, err)
+
+	req := validRequest()
+	req.SeatPreference = "Window"
+	response, err := service.GenerateVoucher(req)
+	require.NoError(t, err)
+	for _, seat := range response.Seats {
+		assert.Equal(t, utils.SeatWindow, aircraft.SeatPosition(seat), "seat %s", seat)
+	}
+
+	req = validRequest()
+	req.FlightNumber = "GA103"
+	req.SeatMix = map[string]int{"aisle": 1, "middle": 1}
+	response, err = service.GenerateVoucher(req)
+	require.NoError(t, err)
+	assert.Equal(t, utils.SeatMiddle, aircraft.SeatPosition(response.Seats[0]))
+	assert.Equal(t, utils.SeatAisle, aircraft.SeatPosition(response.Seats[1]))
+
+	// Each position is its own draw, and every seat points at its draw
+	voucher, err := repo.GetByFlightDate("GA103", "2025-07-12")
+	require.NoError(t, err)
+	draws, err := repo.ListDraws(voucher.ID)
+	require.NoError(t, err)
+	require.Len(t, draws, 3)
+	for i, commitment := range voucher.Commitments {
+		assert.Equal(t, draws[i].ID, commitment.DrawID)
+	}
+	replay, err := service.ReplayVoucherDraws(voucher.ID)
+	require.NoError(t, err)
+	assert.True(t, replay.OK())
+}
+
+func TestVoucherService_GenerateVoucher_SeatPreferenceErrors(t *testing.T) {
+	service, repo := newMemoryService()
+
+	req := validRequest()
+	req.SeatPreference = "exit"
+	_, err := service.GenerateVoucher(req)
+	assert.ErrorIs(t, err, ErrInvalidSeatPreference)
+
+	req = validRequest()
+	req.SeatMix = map[string]int{"window": 4}
+	_, err = service.GenerateVoucher(req)
+	assert.ErrorIs(t, err, ErrInvalidSeatPreference, "the mix asks for more than the default 3 seats")
+
+	req = validRequest()
+	req.CabinClass = "business"
+	req.SeatPreference = "middle"
+	_, err = service.GenerateVoucher(req)
+	assert.ErrorIs(t, err, ErrSeatPreferenceUnsatisfiable)
+
+	vouchers, err := repo.List(repository.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, vouchers)
+}
+
+func TestVoucherService_CheckAndGetVoucher(t *testing.T) {
+	service, _ := newMemoryService()
+
+	exists, err := service.CheckVoucherExists("GA102", "2025-07-12")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	voucher, err := service.GetVoucher("GA102", "2025-07-12")
+	require.NoError(t, err)
+	assert.Nil(t, voucher)
+
+	_, err = service.GenerateVoucher(validRequest())
+	require.NoError(t, err)
+
+	exists, err = service.CheckVoucherExists("GA102", "2025-07-12")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	voucher, err = service.GetVoucher("GA102", "2025-07-12")
+	require.NoError(t, err)
+	require.NotNil(t, voucher)
+	assert.Len(t, voucher.Seats, 3)
+}
+
+func TestVoucherService_GetAndDeleteVoucherByID(t *testing.T) {
+	service, _ := newMemoryService()
+
+	_, err := service.GetVoucherByID(1)
+	assert.ErrorIs(t, err, ErrVoucherNotFound)
+
+	_, err = service.GenerateVoucher(validRequest())
+	require.NoError(t, err)
+
+	voucher, err := service.GetVoucherByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "GA102", voucher.FlightNumber)
+
+	require.NoError(t, service.DeleteVoucher(1, 0))
+	assert.ErrorIs(t, service.DeleteVoucher(1, 0), ErrVoucherNotFound)
+
+	// Deleting frees the flight and date for a new draw
+	_, err = service.GenerateVoucher(validRequest())
+	assert.NoError(t, err)
+}
+
+func TestVoucherService_RegenerateSeat(t *testing.T) {
+	service, repo := newMemoryService()
+
+	req := validRequest()
+	req.CabinClass = "business"
+	req.SeatCount = 11 // leaves exactly one free business seat
+	generated, err := service.GenerateVoucher(req)
+	require.NoError(t, err)
+	require.Len(t, generated.Seats, 11)
+
+	businessSeats, err := utils.GetCabinSeats("Airbus 320", "business")
+	require.NoError(t, err)
+
+	for position := 1; position <= 11; position++ {
+		before, err := repo.GetByFlightDate("GA102", "2025-07-12")
+		require.NoError(t, err)
+
+		response, err := service.RegenerateSeat(regenerateRequest(position))
+		require.NoError(t, err)
+
+		// The new seat stays in the cabin and never duplicates another seat
+		assert.Contains(t, businessSeats, response.NewSeat)
+		for i, seat := range before.Seats {
+			if i != position-1 {
+				assert.NotEqual(t, seat, response.NewSeat)
+			}
+		}
+
+		after, err := repo.GetByFlightDate("GA102", "2025-07-12")
+		require.NoError(t, err)
+		assert.Equal(t, response.AllSeats, after.Seats)
+		assert.Equal(t, response.NewSeat, after.Seats[position-1])
+	}
+}
+
+func TestVoucherService_DrawsAreRecorded(t *testing.T) {
+	service, repo := newMemoryService()
+
+	_, err := service.GenerateVoucher(validRequest())
+	require.NoError(t, err)
+	_, err = service.RegenerateSeat(regenerateRequest(2))
+	require.NoError(t, err)
+
+	voucher, err := repo.GetByFlightDate("GA102", "2025-07-12")
+	require.NoError(t, err)
+	draws, err := repo.ListDraws(voucher.ID)
+	require.NoError(t, err)
+	require.Len(t, draws, 2)
+
+	// Each seat points at the draw that produced it
+	require.Len(t, voucher.Commitments, 3)
+	assert.Equal(t, draws[0].ID, voucher.Commitments[0].DrawID)
+	assert.Equal(t, draws[1].ID, voucher.Commitments[1].DrawID)
+	assert.Equal(t, draws[0].ID, voucher.Commitments[2].DrawID)
+
+	// Every draw can be checked against its commitment and replayed
+	for _, draw := range draws {
+		assert.Equal(t, utils.DrawAlgorithm, draw.Algorithm)
+		seed, err := hex.DecodeString(draw.Seed)
+		require.NoError(t, err)
+		assert.Equal(t, draw.Commitment, utils.SeedCommitment(seed))
+
+		seats, err := utils.ReplayDraw(draw.Algorithm, seed, draw.Pool, len(draw.Seats))
+		require.NoError(t, err)
+		assert.Equal(t, draw.Seats, seats)
+	}
+	assert.NotContains(t, draws[1].Pool, voucher.Seats[0], "a redraw excludes the voucher's other seats")
+}
+
+// campaignRepo simulates a second voucher campaign on the same flight that
+// holds extra seats. While hidden, the held seats are missing from
+// AssignedSeats, as if the other campaign saved them after the lookup.
+type campaignRepo struct {
+	*repository.MemoryVoucherRepository
+	held   []string
+	hidden bool
+}
+
+func (r *campaignRepo) AssignedSeats(flightNumber, date string) ([]string, error) {
+	seats, err := r.MemoryVoucherRepository.AssignedSeats(flightNumber, date)
+	if r.hidden {
+		return seats, err
+	}
+	return append(seats, r.held...), err
+}
+
+func (r *campaignRepo) UpdateSeat(id, version, position int, seat string, draw *models.SeatDraw, event *models.VoucherEvent, limits repository.RegenerationLimits) error {
+	if containsSeat(r.held, seat) {
+		return fmt.Errorf("%w: %s", repository.ErrSeatTaken, seat)
+	}
+	return r.MemoryVoucherRepository.UpdateSeat(id, version, position, seat, draw, event, limits)
+}
+
+func TestVoucherService_SeatsHeldByOtherVouchers(t *testing.T) {
+	repo := &campaignRepo{MemoryVoucherRepository: repository.NewMemoryVoucherRepository()}
+	service := NewVoucherService(repo)
+
+	seats, err := utils.GetAllSeats("ATR")
+	require.NoError(t, err)
+	free := []string{"2A", "7D", "15F", "18C"}
+	for _, seat := range seats {
+		if !containsSeat(free, seat) {
+			repo.held = append(repo.held, seat)
+		}
+	}
+
+	req := validRequest()
+	req.Aircraft = "ATR"
+	response, err := service.GenerateVoucher(req)
+	require.NoError(t, err)
+	for _, seat := range response.Seats {
+		assert.Contains(t, free, seat)
+	}
+
+	regenerate := regenerateRequest(1)
+	regenerated, err := service.RegenerateSeat(regenerate)
+	require.NoError(t, err)
+	assert.Contains(t, free, regenerated.NewSeat)
+
+	// Every seat but the voucher's own is held, so a draw that missed the
+	// other campaign collides when it is saved. The position may also be
+	// redrawn onto its own seat, which stays free.
+	voucher, err := repo.GetByFlightDate("GA102", "2025-07-12")
+	require.NoError(t, err)
+	repo.held = nil
+	for _, seat := range seats {
+		if !containsSeat(voucher.Seats, seat) {
+			repo.held = append(repo.held, seat)
+		}
+	}
+	repo.hidden = true
+	for attempt := 0; attempt < 50; attempt++ {
+		var redrawn *models.RegenerateSeatResponse
+		redrawn, err = service.RegenerateSeat(regenerate)
+		if err != nil {
+			break
+		}
+		assert.Equal(t, voucher.Seats[0], redrawn.NewSeat)
+	}
+	assert.ErrorIs(t, err, ErrSeatTaken)
+}
+
+// interleavedRepo runs another change right after a voucher is read, as if a
+// concurrent request wrote the voucher between the read and the write
+type interleavedRepo struct {
+	*repository.MemoryVoucherRepository
+	change func()
+}
+
+func (r *interleavedRepo) GetByID(id int) (*models.Voucher, error) {
+	voucher, err := r.MemoryVoucherRepository.GetByID(id)
+	r.interleave()
+	return voucher, err
+}
+
+func (r *interleavedRepo) GetByFlightDate(flightNumber, date string) (*models.Voucher, error) {
+	voucher, err := r.MemoryVoucherRepository.GetByFlightDate(flightNumber, date)
+	r.interleave()
+	return voucher, err
+}
+
+func (r *interleavedRepo) interleave() {
+	if change := r.change; change != nil {
+		r.change = nil
+		change()
+	}
+}
+
+func TestVoucherService_ExpectedVersion(t *testing.T) {
+	repo := &interleavedRepo{MemoryVoucherRepository: repository.NewMemoryVoucherRepository()}
+	service := NewVoucherService(repo)
+	id := generateForStatus(t, service)
+	setState := func(position int, status string) func() {
+		return func() {
+			require.NoError(t, repo.MemoryVoucherRepository.SetSeatState(id, 0, position, models.SeatStatusIssued, models.SeatState{Status: status}, nil))
+		}
+	}
+
+	// A stale version is rejected up front
+	_, err := service.VoidSeat(id, &models.VoidSeatRequest{SeatPosition: 1, Version: 2})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	// So is a change that lands between the read and the write
+	repo.change = setState(3, models.SeatStatusVoided)
+	_, err = service.RedeemSeat(id, &models.RedeemSeatRequest{SeatPosition: 1, RedeemedBy: "98123", Version: 1})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	repo.change = setState(2, models.SeatStatusVoided)
+	regenerate := regenerateRequest(1)
+	regenerate.Version = 2
+	_, err = service.RegenerateSeat(regenerate)
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	repo.change = setState(1, models.SeatStatusRedeemed)
+	assert.ErrorIs(t, service.DeleteVoucher(id, 3), ErrPreconditionFailed)
+
+	voucher, err := service.GetVoucherByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, 4, voucher.Version)
+	assert.Equal(t, models.SeatStatusRedeemed, voucher.SeatStates[0].Status)
+	events, err := repo.ListEvents(id)
+	require.NoError(t, err)
+	assert.Len(t, events, 1, "only the generate event is recorded")
+
+	require.NoError(t, service.DeleteVoucher(id, 4))
+}
+
+func TestVoucherService_RegenerateSeat_Errors(t *testing.T) {
+	service, _ := newMemoryService()
+
+	_, err := service.RegenerateSeat(regenerateRequest(1))
+	assert.ErrorIs(t, err, ErrVoucherNotFound)
+
+	_, err = service.GenerateVoucher(validRequest())
+	require.NoError(t, err)
+
+	for _, position := range []int{0, 4} {
+		_, err = service.RegenerateSeat(regenerateRequest(position))
+		assert.ErrorIs(t, err, ErrInvalidSeatPosition, "position %d", position)
+	}
+
+	req := regenerateRequest(1)
+	req.CrewID = " "
+	_, err = service.RegenerateSeat(req)
+	assert.ErrorIs(t, err, ErrMissingFields)
+
+	req = regenerateRequest(1)
+	req.Reason = "friend"
+	_, err = service.RegenerateSeat(req)
+	assert.ErrorIs(t, err, ErrInvalidReason)
+}
+
+func TestVoucherService_RegenerationLimits(t *testing.T) {
+	service, _ := newMemoryService()
+	require.Error(t, service.SetRegenerationLimits(-1, 0))
+	require.NoError(t, service.SetRegenerationLimits(3, 2))
+
+	_, err := service.GenerateVoucher(validRequest())
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = service.RegenerateSeat(regenerateRequest(1))
+		require.NoError(t, err)
+	}
+	_, err = service.RegenerateSeat(regenerateRequest(1))
+	assert.ErrorIs(t, err, ErrRegenerationLimitReached, "seat 1 was redrawn twice")
+
+	req := regenerateRequest(2)
+	req.Reason = " Seat_Unavailable "
+	_, err = service.RegenerateSeat(req)
+	require.NoError(t, err)
+	_, err = service.RegenerateSeat(regenerateRequest(3))
+	assert.ErrorIs(t, err, ErrRegenerationLimitReached, "the voucher was redrawn three times")
+
+	voucher, err := service.GetVoucher("GA102", "2025-07-12")
+	require.NoError(t, err)
+	history, err := service.VoucherHistory(voucher.ID)
+	require.NoError(t, err)
+	require.Len(t, history.Events, 4)
+	assert.Equal(t, models.ReasonSeatUnavailable, history.Events[3].Reason)
+	assert.Equal(t, "98123", history.Events[3].ActorID)
+}
+
+func TestVoucherService_GenerateVoucher_ConcurrentRequests(t *testing.T) {
+	db := newTestDB(t)
+	service := NewVoucherService(repository.NewSQLiteVoucherRepository(db))
+
+	const workers = 20
+	var (
+		wg        sync.WaitGroup
+		start     = make(chan struct{})
+		successes int32
+		conflicts int32
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+
+			_, err := service.GenerateVoucher(&models.GenerateVoucherRequest{
+				Name:         "Crew",
+				ID:           fmt.Sprintf("crew-%d", i),
+				FlightNumber: "GA102",
+				Date:         "2025-07-12",
+				Aircraft:     "ATR",
+			})
+			switch {
+			case err == nil:
+				atomic.AddInt32(&successes, 1)
+			case errors.Is(err, ErrVoucherExists):
+				atomic.AddInt32(&conflicts, 1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), successes)
+	assert.Equal(t, int32(workers-1), conflicts)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM vouchers`).Scan(&count))
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"airline-voucher-backend/models"
+	"airline-voucher-backend/repository"
+)
+
+// seatTransitions lists the statuses each seat status may move to. Seats
+// move from issued only; the other statuses are final.
+var seatTransitions = map[string][]string{
+	models.SeatStatusIssued: {models.SeatStatusRedeemed, models.SeatStatusExpired, models.SeatStatusVoided},
+}
+
+// seatEvents maps the statuses a seat can be moved to over the API to the
+// events recording the change
+var seatEvents = map[string]string{
+	models.SeatStatusRedeemed: models.EventRedeem,
+	models.SeatStatusVoided:   models.EventVoid,
+}
+
+// canTransition reports whether a seat may move from one status to another
+func canTransition(from, to string) bool {
+	for _, allowed := range seatTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSeatExpiry sets how long after the end of its flight date a seat that
+// is still issued expires. Zero, the default, never expires seats.
+func (s *VoucherService) SetSeatExpiry(expiry time.Duration) error {
+	if expiry < 0 {
+		return fmt.Errorf("seat expiry must not be negative, got %s", expiry)
+	}
+	s.seatExpiry = expiry
+	return nil
+}
+
+// SetClock replaces the clock used for seat expiry and redemption times,
+// e.g. with a fixed time in tests
+func (s *VoucherService) SetClock(now func() time.Time) {
+	s.clock = now
+}
+
+// now returns the current time of the configured clock
+func (s *VoucherService) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock()
+}
+
+// timestamp returns the current time of the configured clock in the format
+// stored with vouchers
+func (s *VoucherService) timestamp() string {
+	return s.now().Format("2006-01-02 15:04:05")
+}
+
+// applyExpiry shows the issued seats of a voucher whose flight is past the
+// seat expiry as expired. Expiry follows from the clock, so it is not stored.
+func (s *VoucherService) applyExpiry(voucher *models.Voucher) {
+	if s.seatExpiry == 0 {
+		return
+	}
+	flightDate, err := time.ParseInLocation("2006-01-02", voucher.FlightDate, time.Local)
+	if err != nil {
+		return
+	}
+	if s.now().Before(flightDate.AddDate(0, 0, 1).Add(s.seatExpiry)) {
+		return
+	}
+
+	for i := range voucher.SeatStates {
+		if voucher.SeatStates[i].Status == models.SeatStatusIssued {
+			voucher.SeatStates[i] = models.SeatState{Status: models.SeatStatusExpired}
+		}
+	}
+}
+
+// RedeemSeat records that an issued seat of a voucher was given to its
+// passenger and returns the updated voucher
+func (s *VoucherService) RedeemSeat(id int, req *models.RedeemSeatRequest) (*models.Voucher, error) {
+	redeemedBy := strings.TrimSpace(req.RedeemedBy)
+	if redeemedBy == "" {
+		return nil, fmt.Errorf("%w: redeemedBy is required", ErrMissingFields)
+	}
+	if err := s.authorize(actionRedeem, ""); err != nil {
+		return nil, err
+	}
+
+	return s.transitionSeat(id, req.Version, req.SeatPosition, models.SeatState{
+		Status:     models.SeatStatusRedeemed,
+		RedeemedBy: redeemedBy,
+		RedeemedAt: s.timestamp(),
+	}, redeemedBy)
+}
+
+// VoidSeat withdraws an issued seat of a voucher and returns the updated
+// voucher
+func (s *VoucherService) VoidSeat(id int, req *models.VoidSeatRequest) (*models.Voucher, error) {
+	if err := s.authorize(actionVoid, ""); err != nil {
+		return nil, err
+	}
+	return s.transitionSeat(id, req.Version, req.SeatPosition, models.SeatState{Status: models.SeatStatusVoided}, req.CrewID)
+}
+
+// transitionSeat moves the seat at a 1-based position to a new state if its
+// current status allows it, recording the change for the crew member actorID.
+// A non-zero version must be the voucher's current version.
+func (s *VoucherService) transitionSeat(id, version, position int, state models.SeatState, actorID string) (*models.Voucher, error) {
+	voucher, err := s.GetVoucherByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(voucher, version); err != nil {
+		return nil, err
+	}
+	if position < 1 || position > len(voucher.Seats) {
+		return nil, fmt.Errorf("%w: %d (voucher has %d seats)", ErrInvalidSeatPosition, position, len(voucher.Seats))
+	}
+
+	from := seatStatus(voucher, position-1)
+	if !canTransition(from, state.Status) {
+		return nil, fmt.Errorf("%w: seat %s is %s and cannot be %s", ErrInvalidSeatTransition, voucher.Seats[position-1], from, state.Status)
+	}
+
+	event := s.newEvent(seatEvents[state.Status], position, actorID, from, state.Status)
+	err = s.repo.SetSeatState(id, version, position, from, state, event)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("%w with id %d", ErrVoucherNotFound, id)
+	}
+	if errors.Is(err, repository.ErrVersionChanged) {
+		return nil, versionChanged(id)
+	}
+	if errors.Is(err, repository.ErrStatusChanged) {
+		return nil, fmt.Errorf("%w: seat %s is no longer %s", ErrInvalidSeatTransition, voucher.Seats[position-1], from)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update seat status: %w", err)
+	}
+
+	return s.GetVoucherByID(id)
+}
+
+// seatStatus returns the status of the seat at a 0-based index
+func seatStatus(voucher *models.Voucher, i int) string {
+	if i < len(voucher.SeatStates) {
+		return voucher.SeatStates[i].Status
+	}
+	return models.SeatStatusIssued
+}
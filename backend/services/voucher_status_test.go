@@ -0,0 +1,100 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"airline-voucher-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// generateForStatus creates the GA102 voucher of validRequest and returns its ID
+func generateForStatus(t *testing.T, service *VoucherService) int {
+	_, err := service.GenerateVoucher(validRequest())
+	require.NoError(t, err)
+	voucher, err := service.GetVoucher("GA102", "2025-07-12")
+	require.NoError(t, err)
+	return voucher.ID
+}
+
+func TestVoucherService_RedeemAndVoidSeats(t *testing.T) {
+	service, _ := newMemoryService()
+	service.SetClock(func() time.Time { return time.Date(2025, 7, 12, 9, 30, 0, 0, time.Local) })
+	id := generateForStatus(t, service)
+
+	voucher, err := service.GetVoucherByID(id)
+	require.NoError(t, err)
+	for _, state := range voucher.SeatStates {
+		assert.Equal(t, models.SeatStatusIssued, state.Status)
+	}
+
+	voucher, err = service.RedeemSeat(id, &models.RedeemSeatRequest{SeatPosition: 1, RedeemedBy: " 98123 "})
+	require.NoError(t, err)
+	assert.Equal(t, models.SeatState{
+		Status:     models.SeatStatusRedeemed,
+		RedeemedBy: "98123",
+		RedeemedAt: "2025-07-12 09:30:00",
+	}, voucher.SeatStates[0])
+
+	voucher, err = service.VoidSeat(id, &models.VoidSeatRequest{SeatPosition: 2})
+	require.NoError(t, err)
+	assert.Equal(t, models.SeatState{Status: models.SeatStatusVoided}, voucher.SeatStates[1])
+	assert.Equal(t, models.SeatStatusIssued, voucher.SeatStates[2].Status)
+
+	// Redeemed and voided seats are final
+	_, err = service.VoidSeat(id, &models.VoidSeatRequest{SeatPosition: 1})
+	assert.ErrorIs(t, err, ErrInvalidSeatTransition)
+	_, err = service.RedeemSeat(id, &models.RedeemSeatRequest{SeatPosition: 2, RedeemedBy: "98123"})
+	assert.ErrorIs(t, err, ErrInvalidSeatTransition)
+	_, err = service.RegenerateSeat(regenerateRequest(1))
+	assert.ErrorIs(t, err, ErrInvalidSeatTransition)
+
+	// An issued seat can still be redrawn
+	_, err = service.RegenerateSeat(regenerateRequest(3))
+	assert.NoError(t, err)
+}
+
+func TestVoucherService_SeatStatus_Errors(t *testing.T) {
+	service, _ := newMemoryService()
+
+	_, err := service.VoidSeat(1, &models.VoidSeatRequest{SeatPosition: 1})
+	assert.ErrorIs(t, err, ErrVoucherNotFound)
+
+	id := generateForStatus(t, service)
+	for _, position := range []int{0, 4} {
+		_, err = service.VoidSeat(id, &models.VoidSeatRequest{SeatPosition: position})
+		assert.ErrorIs(t, err, ErrInvalidSeatPosition, "position %d", position)
+	}
+	_, err = service.RedeemSeat(id, &models.RedeemSeatRequest{SeatPosition: 1, RedeemedBy: " "})
+	assert.ErrorIs(t, err, ErrMissingFields)
+}
+
+func TestVoucherService_SeatExpiry(t *testing.T) {
+	service, _ := newMemoryService()
+	require.Error(t, service.SetSeatExpiry(-time.Hour))
+	require.NoError(t, service.SetSeatExpiry(12*time.Hour))
+
+	now := time.Date(2025, 7, 13, 11, 59, 0, 0, time.Local)
+	service.SetClock(func() time.Time { return now })
+	id := generateForStatus(t, service)
+	_, err := service.RedeemSeat(id, &models.RedeemSeatRequest{SeatPosition: 1, RedeemedBy: "98123"})
+	require.NoError(t, err)
+
+	// Seats expire 12 hours after the end of the flight date
+	now = time.Date(2025, 7, 13, 12, 0, 0, 0, time.Local)
+	voucher, err := service.GetVoucherByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, models.SeatStatusRedeemed, voucher.SeatStates[0].Status, "redeemed seats do not expire")
+	assert.Equal(t, models.SeatStatusExpired, voucher.SeatStates[1].Status)
+	assert.Equal(t, models.SeatStatusExpired, voucher.SeatStates[2].Status)
+
+	_, err = service.RedeemSeat(id, &models.RedeemSeatRequest{SeatPosition: 2, RedeemedBy: "98123"})
+	assert.ErrorIs(t, err, ErrInvalidSeatTransition)
+
+	list, err := service.ListVouchers(&models.ListVouchersRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.Vouchers, 1)
+	assert.Equal(t, models.SeatStatusExpired, list.Vouchers[0].SeatStates[1].Status)
+}
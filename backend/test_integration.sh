@@ -10,6 +10,8 @@ echo "============================================="
 
 BASE_URL="http://localhost:8080"
 API_URL="$BASE_URL/api"
+# Service account key for servers that require authentication
+AUTH_HEADER="X-API-Key: ${API_KEY:-}"
 
 # Colors for output
 RED='\033[0;31m'
@@ -29,10 +31,11 @@ test_api() {
     echo -n "Testing: $test_name... "
     
     if [ "$method" = "GET" ]; then
-        response=$(curl -s -w "HTTPSTATUS:%{http_code}" "$endpoint")
+        response=$(curl -s -w "HTTPSTATUS:%{http_code}" -H "$AUTH_HEADER" "$endpoint")
     else
         response=$(curl -s -w "HTTPSTATUS:%{http_code}" -X "$method" \
             -H "Content-Type: application/json" \
+            -H "$AUTH_HEADER" \
             -d "$data" \
             "$endpoint")
     fi
@@ -71,7 +74,8 @@ for i in {1..10}; do
     fi
     if [ $i -eq 10 ]; then
         echo -e "${RED}✗ Server is not responding${NC}"
-        echo "Please start the server with: go run main.go"
+        echo "Please start the server with: AUTH_DISABLED=true go run main.go"
+        echo "(or with API_KEYS=test:<key> and run this script with API_KEY=<key>)"
         exit 1
     fi
     echo "Waiting for server... ($i/10)"
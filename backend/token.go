@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"airline-voucher-backend/auth"
+	"airline-voucher-backend/config"
+	"airline-voucher-backend/models"
+)
+
+// runToken implements the "token" subcommand, which signs a crew bearer
+// token with the configured JWT secret, e.g. for a crew tablet or for testing
+func runToken(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("token", flag.ContinueOnError)
+	crewID := fs.String("crew-id", "", "crew ID, the token subject")
+	name := fs.String("name", "", "crew member's name")
+	role := fs.String("role", models.RoleCrew, "role: crew, supervisor or admin")
+	flights := fs.String("flights", "", "comma-separated flight numbers the crew member works")
+	ttl := fs.Duration("ttl", 12*time.Hour, "token lifetime")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cfg.JWTSecret == "" {
+		return fmt.Errorf("JWT_SECRET must be set to sign tokens")
+	}
+	authenticator, err := auth.NewAuthenticator(cfg.JWTSecret, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	crew := &models.Principal{ID: *crewID, Name: *name, Role: *role}
+	for _, flight := range strings.Split(*flights, ",") {
+		if flight = strings.TrimSpace(flight); flight != "" {
+			crew.Flights = append(crew.Flights, flight)
+		}
+	}
+
+	token, err := authenticator.IssueToken(crew, *ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
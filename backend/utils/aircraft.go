@@ -0,0 +1,389 @@
+package utils
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AircraftConfig represents the configuration for an aircraft type
+type AircraftConfig struct {
+	Type  string   `json:"type" yaml:"type"`
+	Rows  int      `json:"rows" yaml:"rows"`
+	Seats []string `json:"seats" yaml:"seats"`
+
+	// ExcludedRows lists rows that never receive voucher seats, such as a
+	// non-existent row 13 or exit rows
+	ExcludedRows []int `json:"excludedRows,omitempty" yaml:"excludedRows,omitempty"`
+	// ExcludedSeats lists individual seats (e.g. "1B") that never receive vouchers
+	ExcludedSeats []string `json:"excludedSeats,omitempty" yaml:"excludedSeats,omitempty"`
+	// RowSeats overrides the seat letters of specific rows, e.g. rows shortened
+	// by a galley or lavatory
+	RowSeats map[int][]string `json:"rowSeats,omitempty" yaml:"rowSeats,omitempty"`
+	// Cabins splits the rows into cabin class zones. Rows outside every
+	// zone have no cabin class.
+	Cabins []CabinZone `json:"cabins,omitempty" yaml:"cabins,omitempty"`
+	// Positions tags seat letters as window, middle or aisle seats. Letters
+	// without a tag never satisfy a seat preference.
+	Positions map[string]string `json:"positions,omitempty" yaml:"positions,omitempty"`
+}
+
+// CabinZone describes a contiguous block of rows sharing a cabin class
+type CabinZone struct {
+	Class    string `json:"class" yaml:"class"`
+	FirstRow int    `json:"firstRow" yaml:"firstRow"`
+	LastRow  int    `json:"lastRow" yaml:"lastRow"`
+	// Seats lists the seat letters used in this zone. When empty, the
+	// aircraft's default seat letters apply.
+	Seats []string `json:"seats,omitempty" yaml:"seats,omitempty"`
+	// Positions overrides the aircraft's position tags in this zone, e.g. a
+	// business cabin where D is a window seat
+	Positions map[string]string `json:"positions,omitempty" yaml:"positions,omitempty"`
+}
+
+// AircraftFleet is the layout file format: a list of aircraft configurations
+type AircraftFleet struct {
+	Aircraft []AircraftConfig `json:"aircraft" yaml:"aircraft"`
+}
+
+// defaultAircraftFile holds the layouts used until a layout file is loaded
+//
+//go:embed aircraft.json
+var defaultAircraftFile []byte
+
+var (
+	aircraftMu      sync.RWMutex
+	aircraftConfigs map[string]AircraftConfig
+)
+
+func init() {
+	configs, err := ParseAircraftConfigs(defaultAircraftFile, "json")
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in aircraft layouts: %v", err))
+	}
+	aircraftConfigs = configs
+}
+
+// LoadAircraftConfigs reads aircraft layouts from a JSON or YAML file and makes
+// them the active layouts. The file format is chosen by its extension.
+func LoadAircraftConfigs(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read aircraft layouts: %w", err)
+	}
+
+	configs, err := ParseAircraftConfigs(data, strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return fmt.Errorf("invalid aircraft layouts in %s: %w", path, err)
+	}
+
+	SetAircraftConfigs(configs)
+	return nil
+}
+
+// SetAircraftConfigs makes validated layouts, as returned by
+// ParseAircraftConfigs, the active layouts. Draws in progress keep the
+// layouts they started with.
+func SetAircraftConfigs(configs map[string]AircraftConfig) {
+	aircraftMu.Lock()
+	aircraftConfigs = configs
+	aircraftMu.Unlock()
+}
+
+// ParseAircraftConfigs decodes and validates aircraft layouts in the given
+// format ("json", "yaml" or "yml")
+func ParseAircraftConfigs(data []byte, format string) (map[string]AircraftConfig, error) {
+	var fleet AircraftFleet
+
+	switch strings.ToLower(format) {
+	case "json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&fleet); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+	case "yaml", "yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&fleet); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported layout format: %q (expected json or yaml)", format)
+	}
+
+	if len(fleet.Aircraft) == 0 {
+		return nil, fmt.Errorf("no aircraft defined")
+	}
+
+	configs := make(map[string]AircraftConfig, len(fleet.Aircraft))
+	for _, config := range fleet.Aircraft {
+		if err := config.Validate(); err != nil {
+			return nil, err
+		}
+		if _, exists := configs[config.Type]; exists {
+			return nil, fmt.Errorf("duplicate aircraft type: %s", config.Type)
+		}
+		configs[config.Type] = config
+	}
+
+	return configs, nil
+}
+
+// Validate checks that the aircraft configuration describes a usable cabin
+func (c *AircraftConfig) Validate() error {
+	if strings.TrimSpace(c.Type) == "" {
+		return fmt.Errorf("aircraft type is required")
+	}
+
+	if c.Rows <= 0 {
+		return fmt.Errorf("aircraft %s: rows must be positive", c.Type)
+	}
+
+	if len(c.Seats) == 0 {
+		return fmt.Errorf("aircraft %s: at least one seat letter is required", c.Type)
+	}
+
+	if err := validateSeatLetters(c.Seats); err != nil {
+		return fmt.Errorf("aircraft %s: %w", c.Type, err)
+	}
+
+	for _, row := range c.ExcludedRows {
+		if row < 1 || row > c.Rows {
+			return fmt.Errorf("aircraft %s: excluded row %d is out of range 1-%d", c.Type, row, c.Rows)
+		}
+	}
+
+	for i, zone := range c.Cabins {
+		if strings.TrimSpace(zone.Class) == "" {
+			return fmt.Errorf("aircraft %s: cabin zone %d has no class", c.Type, i+1)
+		}
+		if zone.FirstRow < 1 || zone.LastRow > c.Rows || zone.FirstRow > zone.LastRow {
+			return fmt.Errorf("aircraft %s: cabin %s rows %d-%d are out of range 1-%d", c.Type, zone.Class, zone.FirstRow, zone.LastRow, c.Rows)
+		}
+		if err := validateSeatLetters(zone.Seats); err != nil {
+			return fmt.Errorf("aircraft %s: cabin %s: %w", c.Type, zone.Class, err)
+		}
+		if err := validatePositions(zone.Positions); err != nil {
+			return fmt.Errorf("aircraft %s: cabin %s: %w", c.Type, zone.Class, err)
+		}
+		for _, other := range c.Cabins[:i] {
+			if zone.FirstRow <= other.LastRow && other.FirstRow <= zone.LastRow {
+				return fmt.Errorf("aircraft %s: cabin %s overlaps cabin %s", c.Type, zone.Class, other.Class)
+			}
+		}
+	}
+
+	if err := validatePositions(c.Positions); err != nil {
+		return fmt.Errorf("aircraft %s: %w", c.Type, err)
+	}
+
+	for row, letters := range c.RowSeats {
+		if row < 1 || row > c.Rows {
+			return fmt.Errorf("aircraft %s: row override %d is out of range 1-%d", c.Type, row, c.Rows)
+		}
+		if err := validateSeatLetters(letters); err != nil {
+			return fmt.Errorf("aircraft %s: row %d: %w", c.Type, row, err)
+		}
+	}
+
+	for _, seat := range c.ExcludedSeats {
+		row, letter, err := ParseSeat(seat)
+		if err != nil {
+			return fmt.Errorf("aircraft %s: excluded seat: %w", c.Type, err)
+		}
+		if row > c.Rows || !containsString(c.RowSeatLetters(row), letter) {
+			return fmt.Errorf("aircraft %s: excluded seat %s does not exist", c.Type, seat)
+		}
+	}
+
+	if len(c.AvailableSeats()) == 0 {
+		return fmt.Errorf("aircraft %s: every seat is excluded", c.Type)
+	}
+
+	return nil
+}
+
+// RowSeatLetters returns the seat letters installed in the given row,
+// taking row overrides into account
+func (c *AircraftConfig) RowSeatLetters(row int) []string {
+	if letters, ok := c.RowSeats[row]; ok {
+		return letters
+	}
+	if zone := c.cabinZone(row); zone != nil && len(zone.Seats) > 0 {
+		return zone.Seats
+	}
+	return c.Seats
+}
+
+// CabinClass returns the cabin class of the given row, or an empty string
+// when the row is not part of any cabin zone
+func (c *AircraftConfig) CabinClass(row int) string {
+	if zone := c.cabinZone(row); zone != nil {
+		return zone.Class
+	}
+	return ""
+}
+
+// CabinClasses returns the distinct cabin classes in row order
+func (c *AircraftConfig) CabinClasses() []string {
+	var classes []string
+	for _, zone := range c.Cabins {
+		if !containsString(classes, zone.Class) {
+			classes = append(classes, zone.Class)
+		}
+	}
+	return classes
+}
+
+// LookupCabinClass returns the configured spelling of a cabin class, matching
+// case-insensitively
+func (c *AircraftConfig) LookupCabinClass(class string) (string, bool) {
+	for _, zone := range c.Cabins {
+		if strings.EqualFold(zone.Class, class) {
+			return zone.Class, true
+		}
+	}
+	return "", false
+}
+
+// AvailableCabinSeats returns the assignable seats of one cabin class. An
+// empty class returns the seats of the whole aircraft.
+func (c *AircraftConfig) AvailableCabinSeats(class string) []string {
+	seats := c.AvailableSeats()
+	if class == "" {
+		return seats
+	}
+
+	var cabinSeats []string
+	for _, seat := range seats {
+		row, _, _ := ParseSeat(seat)
+		if c.CabinClass(row) == class {
+			cabinSeats = append(cabinSeats, seat)
+		}
+	}
+	return cabinSeats
+}
+
+func (c *AircraftConfig) cabinZone(row int) *CabinZone {
+	for i := range c.Cabins {
+		if row >= c.Cabins[i].FirstRow && row <= c.Cabins[i].LastRow {
+			return &c.Cabins[i]
+		}
+	}
+	return nil
+}
+
+// AvailableSeats returns every seat that may be assigned to a voucher, in
+// row order, with excluded rows and seats removed
+func (c *AircraftConfig) AvailableSeats() []string {
+	excludedRows := make(map[int]bool, len(c.ExcludedRows))
+	for _, row := range c.ExcludedRows {
+		excludedRows[row] = true
+	}
+
+	excludedSeats := make(map[string]bool, len(c.ExcludedSeats))
+	for _, seat := range c.ExcludedSeats {
+		excludedSeats[seat] = true
+	}
+
+	var seats []string
+	for row := 1; row <= c.Rows; row++ {
+		if excludedRows[row] {
+			continue
+		}
+		for _, letter := range c.RowSeatLetters(row) {
+			seat := fmt.Sprintf("%d%s", row, letter)
+			if !excludedSeats[seat] {
+				seats = append(seats, seat)
+			}
+		}
+	}
+
+	return seats
+}
+
+// ParseSeat splits a seat such as "12C" into its row number and letter
+func ParseSeat(seat string) (int, string, error) {
+	if len(seat) < 2 {
+		return 0, "", fmt.Errorf("invalid seat %q", seat)
+	}
+
+	letter := seat[len(seat)-1:]
+	row, err := strconv.Atoi(seat[:len(seat)-1])
+	if err != nil || row < 1 || letter[0] < 'A' || letter[0] > 'Z' {
+		return 0, "", fmt.Errorf("invalid seat %q", seat)
+	}
+
+	return row, letter, nil
+}
+
+// validateSeatLetters checks that every entry is a single unique letter A-Z
+func validateSeatLetters(letters []string) error {
+	seen := make(map[string]bool, len(letters))
+	for _, letter := range letters {
+		if len(letter) != 1 || letter[0] < 'A' || letter[0] > 'Z' {
+			return fmt.Errorf("invalid seat letter %q", letter)
+		}
+		if seen[letter] {
+			return fmt.Errorf("duplicate seat letter %s", letter)
+		}
+		seen[letter] = true
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AircraftTypes returns the names of all configured aircraft types in sorted order
+func AircraftTypes() []string {
+	aircraftMu.RLock()
+	defer aircraftMu.RUnlock()
+
+	types := make([]string, 0, len(aircraftConfigs))
+	for aircraftType := range aircraftConfigs {
+		types = append(types, aircraftType)
+	}
+	sort.Strings(types)
+
+	return types
+}
+
+// clone returns a deep copy so callers cannot modify the active layouts
+func (c AircraftConfig) clone() *AircraftConfig {
+	c.Seats = append([]string(nil), c.Seats...)
+	c.ExcludedRows = append([]int(nil), c.ExcludedRows...)
+	c.ExcludedSeats = append([]string(nil), c.ExcludedSeats...)
+	if c.RowSeats != nil {
+		rowSeats := make(map[int][]string, len(c.RowSeats))
+		for row, letters := range c.RowSeats {
+			rowSeats[row] = append([]string(nil), letters...)
+		}
+		c.RowSeats = rowSeats
+	}
+	c.Positions = clonePositions(c.Positions)
+	cabins := make([]CabinZone, len(c.Cabins))
+	for i, zone := range c.Cabins {
+		zone.Seats = append([]string(nil), zone.Seats...)
+		zone.Positions = clonePositions(zone.Positions)
+		cabins[i] = zone
+	}
+	c.Cabins = cabins
+	return &c
+}
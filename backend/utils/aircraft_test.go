@@ -0,0 +1,371 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// restoreAircraftConfigs puts the active layouts back after a test replaces them
+func restoreAircraftConfigs(t *testing.T) {
+	aircraftMu.RLock()
+	previous := aircraftConfigs
+	aircraftMu.RUnlock()
+
+	t.Cleanup(func() {
+		aircraftMu.Lock()
+		aircraftConfigs = previous
+		aircraftMu.Unlock()
+	})
+}
+
+func writeLayoutFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadAircraftConfigs_JSON(t *testing.T) {
+	restoreAircraftConfigs(t)
+
+	path := writeLayoutFile(t, "aircraft.json", `{
+		"aircraft": [
+			{"type": "ATR 42-600", "rows": 12, "seats": ["A", "C", "D", "F"]}
+		]
+	}`)
+
+	require.NoError(t, LoadAircraftConfigs(path))
+
+	config, err := GetAircraftConfig("ATR 42-600")
+	require.NoError(t, err)
+	assert.Equal(t, 12, config.Rows)
+	assert.Equal(t, []string{"A", "C", "D", "F"}, config.Seats)
+
+	// The loaded file replaces the built-in layouts for both lookups
+	assert.True(t, ValidateAircraftType("ATR 42-600"))
+	assert.False(t, ValidateAircraftType("ATR"))
+	assert.Equal(t, []string{"ATR 42-600"}, AircraftTypes())
+}
+
+func TestLoadAircraftConfigs_YAML(t *testing.T) {
+	restoreAircraftConfigs(t)
+
+	path := writeLayoutFile(t, "aircraft.yaml", `
+aircraft:
+  - type: Airbus 321neo
+    rows: 40
+    seats: [A, B, C, D, E, F]
+`)
+
+	require.NoError(t, LoadAircraftConfigs(path))
+
+	seats, err := GenerateRandomSeats("Airbus 321neo", "", DefaultSeatCount, SeatConstraint{})
+	require.NoError(t, err)
+	assert.Len(t, seats, 3)
+}
+
+func TestLoadAircraftConfigs_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		errorMsg string
+	}{
+		{
+			name:     "Unsupported extension",
+			file:     "aircraft.txt",
+			content:  "",
+			errorMsg: "unsupported layout format",
+		},
+		{
+			name:     "Malformed JSON",
+			file:     "aircraft.json",
+			content:  `{"aircraft": [`,
+			errorMsg: "failed to parse JSON",
+		},
+		{
+			name:     "Unknown field",
+			file:     "aircraft.json",
+			content:  `{"aircraft": [{"type": "ATR", "rows": 18, "seats": ["A"], "colums": 4}]}`,
+			errorMsg: "unknown field",
+		},
+		{
+			name:     "No aircraft",
+			file:     "aircraft.json",
+			content:  `{"aircraft": []}`,
+			errorMsg: "no aircraft defined",
+		},
+		{
+			name:     "Missing type",
+			file:     "aircraft.json",
+			content:  `{"aircraft": [{"rows": 18, "seats": ["A"]}]}`,
+			errorMsg: "aircraft type is required",
+		},
+		{
+			name:     "Non-positive rows",
+			file:     "aircraft.json",
+			content:  `{"aircraft": [{"type": "ATR", "rows": 0, "seats": ["A"]}]}`,
+			errorMsg: "rows must be positive",
+		},
+		{
+			name:     "No seat letters",
+			file:     "aircraft.json",
+			content:  `{"aircraft": [{"type": "ATR", "rows": 18, "seats": []}]}`,
+			errorMsg: "at least one seat letter",
+		},
+		{
+			name:     "Invalid seat letter",
+			file:     "aircraft.json",
+			content:  `{"aircraft": [{"type": "ATR", "rows": 18, "seats": ["AB"]}]}`,
+			errorMsg: "invalid seat letter",
+		},
+		{
+			name:     "Duplicate seat letter",
+			file:     "aircraft.json",
+			content:  `{"aircraft": [{"type": "ATR", "rows": 18, "seats": ["A", "A"]}]}`,
+			errorMsg: "duplicate seat letter",
+		},
+		{
+			name: "Duplicate aircraft type",
+			file: "aircraft.json",
+			content: `{"aircraft": [
+				{"type": "ATR", "rows": 18, "seats": ["A"]},
+				{"type": "ATR", "rows": 20, "seats": ["A"]}
+			]}`,
+			errorMsg: "duplicate aircraft type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restoreAircraftConfigs(t)
+
+			path := writeLayoutFile(t, tt.file, tt.content)
+			err := LoadAircraftConfigs(path)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+
+			// A rejected file leaves the active layouts untouched
+			assert.True(t, ValidateAircraftType("ATR"))
+		})
+	}
+}
+
+func TestLoadAircraftConfigs_MissingFile(t *testing.T) {
+	err := LoadAircraftConfigs(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestAircraftConfig_AvailableSeats(t *testing.T) {
+	config := AircraftConfig{
+		Type:          "Test",
+		Rows:          4,
+		Seats:         []string{"A", "C", "D", "F"},
+		ExcludedRows:  []int{2},
+		ExcludedSeats: []string{"1C", "4F"},
+		RowSeats:      map[int][]string{4: {"A", "F"}},
+	}
+
+	require.NoError(t, config.Validate())
+	assert.Equal(t, []string{
+		"1A", "1D", "1F",
+		"3A", "3C", "3D", "3F",
+		"4A",
+	}, config.AvailableSeats())
+}
+
+func TestAircraftConfig_ValidateExclusions(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   AircraftConfig
+		errorMsg string
+	}{
+		{
+			name:     "Excluded row out of range",
+			config:   AircraftConfig{Type: "Test", Rows: 10, Seats: []string{"A"}, ExcludedRows: []int{13}},
+			errorMsg: "excluded row 13 is out of range",
+		},
+		{
+			name:     "Row override out of range",
+			config:   AircraftConfig{Type: "Test", Rows: 10, Seats: []string{"A"}, RowSeats: map[int][]string{11: {"A"}}},
+			errorMsg: "row override 11 is out of range",
+		},
+		{
+			name:     "Row override with invalid letter",
+			config:   AircraftConfig{Type: "Test", Rows: 10, Seats: []string{"A"}, RowSeats: map[int][]string{1: {"a"}}},
+			errorMsg: "invalid seat letter",
+		},
+		{
+			name:     "Malformed excluded seat",
+			config:   AircraftConfig{Type: "Test", Rows: 10, Seats: []string{"A"}, ExcludedSeats: []string{"A1"}},
+			errorMsg: "invalid seat",
+		},
+		{
+			name:     "Excluded seat not in layout",
+			config:   AircraftConfig{Type: "Test", Rows: 10, Seats: []string{"A", "C"}, ExcludedSeats: []string{"3B"}},
+			errorMsg: "excluded seat 3B does not exist",
+		},
+		{
+			name:     "Everything excluded",
+			config:   AircraftConfig{Type: "Test", Rows: 2, Seats: []string{"A"}, ExcludedRows: []int{1, 2}},
+			errorMsg: "every seat is excluded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestGenerateRandomSeats_NeverPicksExcludedSeats(t *testing.T) {
+	restoreAircraftConfigs(t)
+
+	path := writeLayoutFile(t, "aircraft.yaml", `
+aircraft:
+  - type: Small
+    rows: 3
+    seats: [A, B, C]
+    excludedRows: [2]
+    excludedSeats: [1B]
+    rowSeats:
+      3: [A, C]
+`)
+	require.NoError(t, LoadAircraftConfigs(path))
+
+	allowed := map[string]bool{"1A": true, "1C": true, "3A": true, "3C": true}
+
+	for i := 0; i < 100; i++ {
+		seats, err := GenerateRandomSeats("Small", "", DefaultSeatCount, SeatConstraint{})
+		require.NoError(t, err)
+		for _, seat := range seats {
+			assert.True(t, allowed[seat], "seat %s should not be assigned", seat)
+		}
+	}
+}
+
+func TestGenerateRandomSeats_NotEnoughSeats(t *testing.T) {
+	restoreAircraftConfigs(t)
+
+	path := writeLayoutFile(t, "aircraft.json", `{"aircraft": [
+		{"type": "Tiny", "rows": 2, "seats": ["A"], "excludedSeats": ["2A"]}
+	]}`)
+	require.NoError(t, LoadAircraftConfigs(path))
+
+	_, err := GenerateRandomSeats("Tiny", "", DefaultSeatCount, SeatConstraint{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not enough seats")
+}
+
+func TestParseSeat(t *testing.T) {
+	row, letter, err := ParseSeat("12C")
+	require.NoError(t, err)
+	assert.Equal(t, 12, row)
+	assert.Equal(t, "C", letter)
+
+	for _, seat := range []string{"", "C", "12", "0A", "C12", "1c"} {
+		_, _, err := ParseSeat(seat)
+		assert.Error(t, err, "seat %q should be rejected", seat)
+	}
+}
+
+func TestAircraftConfig_Cabins(t *testing.T) {
+	config := AircraftConfig{
+		Type:  "Test",
+		Rows:  5,
+		Seats: []string{"A", "B", "C", "D"},
+		Cabins: []CabinZone{
+			{Class: "business", FirstRow: 1, LastRow: 2, Seats: []string{"A", "D"}},
+			{Class: "economy", FirstRow: 3, LastRow: 5},
+		},
+		ExcludedSeats: []string{"2D"},
+	}
+
+	require.NoError(t, config.Validate())
+	assert.Equal(t, []string{"business", "economy"}, config.CabinClasses())
+	assert.Equal(t, "business", config.CabinClass(2))
+	assert.Equal(t, "economy", config.CabinClass(3))
+	assert.Equal(t, []string{"1A", "1D", "2A"}, config.AvailableCabinSeats("business"))
+	assert.Len(t, config.AvailableCabinSeats("economy"), 12)
+	assert.Len(t, config.AvailableCabinSeats(""), 15)
+
+	class, ok := config.LookupCabinClass("Business")
+	assert.True(t, ok)
+	assert.Equal(t, "business", class)
+
+	_, ok = config.LookupCabinClass("first")
+	assert.False(t, ok)
+}
+
+func TestAircraftConfig_ValidateCabins(t *testing.T) {
+	tests := []struct {
+		name     string
+		cabins   []CabinZone
+		errorMsg string
+	}{
+		{
+			name:     "Missing class",
+			cabins:   []CabinZone{{FirstRow: 1, LastRow: 2}},
+			errorMsg: "has no class",
+		},
+		{
+			name:     "Rows out of range",
+			cabins:   []CabinZone{{Class: "economy", FirstRow: 1, LastRow: 40}},
+			errorMsg: "out of range",
+		},
+		{
+			name:     "Reversed rows",
+			cabins:   []CabinZone{{Class: "economy", FirstRow: 5, LastRow: 2}},
+			errorMsg: "out of range",
+		},
+		{
+			name: "Overlapping zones",
+			cabins: []CabinZone{
+				{Class: "business", FirstRow: 1, LastRow: 4},
+				{Class: "economy", FirstRow: 4, LastRow: 10},
+			},
+			errorMsg: "overlaps",
+		},
+		{
+			name:     "Invalid zone letters",
+			cabins:   []CabinZone{{Class: "business", FirstRow: 1, LastRow: 2, Seats: []string{"A", "A"}}},
+			errorMsg: "duplicate seat letter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := AircraftConfig{Type: "Test", Rows: 10, Seats: []string{"A", "B"}, Cabins: tt.cabins}
+			err := config.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestGenerateRandomSeats_CabinClass(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		seats, err := GenerateRandomSeats("Airbus 320", "business", DefaultSeatCount, SeatConstraint{})
+		require.NoError(t, err)
+		for _, seat := range seats {
+			row, letter, err := ParseSeat(seat)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, row, 3, "seat %s should be in the business cabin", seat)
+			assert.Contains(t, []string{"A", "C", "D", "F"}, letter)
+		}
+	}
+
+	_, err := GenerateRandomSeats("Airbus 320", "first", DefaultSeatCount, SeatConstraint{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown cabin class")
+
+	_, err = GenerateRandomSeats("ATR", "economy", DefaultSeatCount, SeatConstraint{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+)
+
+// DrawAlgorithm names the procedure that turns a seed into seats. It is
+// recorded with every draw, so draws made before the procedure changes can
+// still be reproduced. Version 1:
+//
+//  1. A 32-byte seed keys HMAC-SHA256. Block i of the random stream is the
+//     MAC of i as a big-endian uint64, for i = 0, 1, 2, ...
+//  2. Random numbers are consecutive 8-byte big-endian words of the stream.
+//     A number below n is drawn by rejection sampling: words at or above the
+//     largest multiple of n are discarded, and the rest are reduced modulo n.
+//  3. The candidate seats are shuffled with Fisher-Yates, from the last index
+//     down to 1, swapping index i with a number below i+1. The first seats of
+//     the shuffled list are the draw.
+const DrawAlgorithm = "hmac-sha256-fisher-yates/v1"
+
+// SeedSize is the length in bytes of a draw seed
+const SeedSize = 32
+
+var (
+	entropyMu sync.Mutex
+	entropy   io.Reader = rand.Reader
+)
+
+// SetEntropySource replaces the source of draw seeds, which is crypto/rand
+// by default. A nil reader restores the default.
+func SetEntropySource(r io.Reader) {
+	entropyMu.Lock()
+	defer entropyMu.Unlock()
+
+	if r == nil {
+		r = rand.Reader
+	}
+	entropy = r
+}
+
+// NewSeed reads a fresh draw seed from the entropy source
+func NewSeed() ([]byte, error) {
+	entropyMu.Lock()
+	defer entropyMu.Unlock()
+
+	seed := make([]byte, SeedSize)
+	if _, err := io.ReadFull(entropy, seed); err != nil {
+		return nil, fmt.Errorf("failed to read draw seed: %w", err)
+	}
+	return seed, nil
+}
+
+// Draw is the outcome of a random seat draw together with what is needed to
+// verify it. The seed must stay private until the draw is audited; the
+// commitment can be published right away.
+type Draw struct {
+	Algorithm string
+	Seed      []byte
+	Pool      []string // candidate seats, in the order they were shuffled
+	Seats     []string
+}
+
+// Commitment returns the hex SHA-256 of the draw's seed
+func (d *Draw) Commitment() string {
+	return SeedCommitment(d.Seed)
+}
+
+// SeedCommitment returns the hex SHA-256 of a seed
+func SeedCommitment(seed []byte) string {
+	sum := sha256.Sum256(seed)
+	return hex.EncodeToString(sum[:])
+}
+
+// DrawSeats draws count unique seats from the pool with a fresh seed from
+// the entropy source
+func DrawSeats(pool []string, count int) (*Draw, error) {
+	return defaultGenerator.DrawSeats(pool, count)
+}
+
+// ReplayDraw repeats a draw: given the same algorithm, seed, pool and count it
+// returns the same seats
+func ReplayDraw(algorithm string, seed []byte, pool []string, count int) ([]string, error) {
+	if algorithm != DrawAlgorithm {
+		return nil, fmt.Errorf("unsupported draw algorithm %q", algorithm)
+	}
+	if count < 1 || count > len(pool) {
+		return nil, fmt.Errorf("%w: need %d, have %d", ErrNotEnoughSeats, count, len(pool))
+	}
+
+	return shuffleSeats(newHMACStream(seed), pool)[:count], nil
+}
+
+// shuffleSeats returns a Fisher-Yates shuffled copy of the seats
+func shuffleSeats(stream *hmacStream, seats []string) []string {
+	shuffled := append([]string{}, seats...)
+	for i := len(shuffled) - 1; i > 0; i-- {
+		j := stream.intn(uint64(i + 1))
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	}
+	return shuffled
+}
+
+// hmacStream is the deterministic random stream of DrawAlgorithm
+type hmacStream struct {
+	mac     hash.Hash
+	counter uint64
+	block   []byte
+}
+
+func newHMACStream(seed []byte) *hmacStream {
+	return &hmacStream{mac: hmac.New(sha256.New, seed)}
+}
+
+// Uint64 returns the next 8 bytes of the stream as a big-endian number
+func (s *hmacStream) Uint64() uint64 {
+	if len(s.block) == 0 {
+		var counter [8]byte
+		binary.BigEndian.PutUint64(counter[:], s.counter)
+		s.counter++
+
+		s.mac.Reset()
+		s.mac.Write(counter[:])
+		s.block = s.mac.Sum(nil)
+	}
+
+	n := binary.BigEndian.Uint64(s.block[:8])
+	s.block = s.block[8:]
+	return n
+}
+
+// intn returns a uniformly distributed number in [0, n)
+func (s *hmacStream) intn(n uint64) int {
+	// limit is the largest multiple of n below 2^64, i.e. 2^64 - (2^64 mod n).
+	// It wraps to 0 when n divides 2^64, in which case no word is rejected.
+	limit := -(-n % n)
+	for {
+		x := s.Uint64()
+		if limit == 0 || x < limit {
+			return int(x % n)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSeed returns the seed 00 01 02 ... 1f
+func testSeed() []byte {
+	seed := make([]byte, SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	return seed
+}
+
+var testPool = []string{"1A", "1B", "1C", "1D", "1E", "1F", "2A", "2B", "2C", "2D", "2E", "2F"}
+
+func TestReplayDraw_KnownAnswer(t *testing.T) {
+	// Pinned output of DrawAlgorithm v1, cross-checked against an independent
+	// implementation. If this changes, recorded draws can no longer be replayed.
+	seats, err := ReplayDraw(DrawAlgorithm, testSeed(), testPool, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2F", "1C", "2A", "1A"}, seats)
+
+	assert.Equal(t, "630dcd2966c4336691125448bbb25b4ff412a49c732db2c8abc1b8581bd710dd", SeedCommitment(testSeed()))
+}
+
+func TestReplayDraw_Errors(t *testing.T) {
+	_, err := ReplayDraw("mt19937/v1", testSeed(), testPool, 1)
+	assert.Error(t, err)
+
+	_, err = ReplayDraw(DrawAlgorithm, testSeed(), testPool, len(testPool)+1)
+	assert.ErrorIs(t, err, ErrNotEnoughSeats)
+
+	_, err = ReplayDraw(DrawAlgorithm, testSeed(), testPool, 0)
+	assert.ErrorIs(t, err, ErrNotEnoughSeats)
+}
+
+func TestDrawSeats_Reproducible(t *testing.T) {
+	draw, err := DrawSeats(testPool, 5)
+	require.NoError(t, err)
+	assert.Equal(t, DrawAlgorithm, draw.Algorithm)
+	assert.Len(t, draw.Seed, SeedSize)
+	assert.Equal(t, testPool, draw.Pool)
+	assert.Len(t, draw.Seats, 5)
+	assert.Equal(t, SeedCommitment(draw.Seed), draw.Commitment())
+
+	replayed, err := ReplayDraw(draw.Algorithm, draw.Seed, draw.Pool, len(draw.Seats))
+	require.NoError(t, err)
+	assert.Equal(t, draw.Seats, replayed)
+}
+
+func TestSetEntropySource(t *testing.T) {
+	t.Cleanup(func() { SetEntropySource(nil) })
+
+	SetEntropySource(bytes.NewReader(testSeed()))
+	draw, err := DrawSeats(testPool, 4)
+	require.NoError(t, err)
+	assert.Equal(t, testSeed(), draw.Seed)
+	assert.Equal(t, []string{"2F", "1C", "2A", "1A"}, draw.Seats)
+
+	// The reader is exhausted, so the next draw fails instead of reusing a seed
+	_, err = DrawSeats(testPool, 4)
+	assert.Error(t, err)
+
+	SetEntropySource(nil)
+	first, err := NewSeed()
+	require.NoError(t, err)
+	second, err := NewSeed()
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+}
+
+func TestDrawSeats_Uniform(t *testing.T) {
+	// Every seat should win a single-seat draw about equally often
+	const draws = 12000
+	counts := make(map[string]int)
+	for i := 0; i < draws; i++ {
+		draw, err := DrawSeat(testPool)
+		require.NoError(t, err)
+		counts[draw.Seats[0]]++
+	}
+
+	expected := draws / len(testPool)
+	for _, seat := range testPool {
+		assert.InDelta(t, expected, counts[seat], float64(expected)/4, "seat %s", seat)
+	}
+}
+
+func TestDrawSeat_Empty(t *testing.T) {
+	_, err := DrawSeat(nil)
+	assert.True(t, errors.Is(err, ErrNotEnoughSeats))
+}
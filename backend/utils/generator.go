@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// SeatGenerator draws seats with DrawAlgorithm. Every draw gets its own seed,
+// which the generator takes from the entropy source by default. A generator
+// built on a math/rand Source takes the seeds from that source instead, so a
+// whole sequence of draws can be reproduced from one number, e.g. in tests.
+//
+// The zero value uses the entropy source. A SeatGenerator is safe for
+// concurrent use.
+type SeatGenerator struct {
+	mu     sync.Mutex
+	source *rand.Rand // nil: seeds come from the entropy source
+}
+
+// defaultGenerator backs the package-level draw functions
+var defaultGenerator = NewSeatGenerator()
+
+// NewSeatGenerator returns a generator seeding every draw from the entropy
+// source (crypto/rand unless replaced with SetEntropySource)
+func NewSeatGenerator() *SeatGenerator {
+	return &SeatGenerator{}
+}
+
+// NewSeatGeneratorFromSource returns a generator deriving draw seeds from src.
+// Such draws are predictable by anyone who knows src, so this is meant for
+// tests and replays, not for production draws.
+func NewSeatGeneratorFromSource(src rand.Source) *SeatGenerator {
+	return &SeatGenerator{source: rand.New(src)}
+}
+
+// NewSeededSeatGenerator returns a generator whose draws are fully determined
+// by seed
+func NewSeededSeatGenerator(seed int64) *SeatGenerator {
+	return NewSeatGeneratorFromSource(rand.NewSource(seed))
+}
+
+// newSeed returns the seed for the next draw
+func (g *SeatGenerator) newSeed() ([]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.source == nil {
+		return NewSeed()
+	}
+
+	seed := make([]byte, SeedSize)
+	g.source.Read(seed)
+	return seed, nil
+}
+
+// DrawSeats draws count unique seats from the pool
+func (g *SeatGenerator) DrawSeats(pool []string, count int) (*Draw, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("invalid seat count: %d", count)
+	}
+	if len(pool) < count {
+		return nil, fmt.Errorf("%w: need %d, have %d", ErrNotEnoughSeats, count, len(pool))
+	}
+
+	seed, err := g.newSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	seats, err := ReplayDraw(DrawAlgorithm, seed, pool, count)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Draw{
+		Algorithm: DrawAlgorithm,
+		Seed:      seed,
+		Pool:      append([]string{}, pool...),
+		Seats:     seats,
+	}, nil
+}
+
+// DrawCabinSeats draws count unique assignable seats of an aircraft type. A
+// non-empty cabin class limits the draw to that cabin zone.
+func (g *SeatGenerator) DrawCabinSeats(aircraftType, cabinClass string, count int) (*Draw, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("invalid seat count: %d", count)
+	}
+
+	allSeats, err := GetCabinSeats(aircraftType, cabinClass)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(allSeats) < count {
+		return nil, fmt.Errorf("%w on %s: need %d, have %d", ErrNotEnoughSeats, aircraftType, count, len(allSeats))
+	}
+
+	return g.DrawSeats(allSeats, count)
+}
+
+// DrawSeat draws a single seat from the available seats
+func (g *SeatGenerator) DrawSeat(availableSeats []string) (*Draw, error) {
+	if len(availableSeats) == 0 {
+		return nil, fmt.Errorf("%w: no available seats", ErrNotEnoughSeats)
+	}
+
+	return g.DrawSeats(availableSeats, 1)
+}
+
+// DrawPreferredSeats draws count unique assignable seats of an aircraft type
+// that satisfy the constraint. Excluded seats are removed first. Seats
+// required at a position are drawn first, each position from its own pool,
+// and the remaining seats from whatever is left of the cabin, so every
+// returned draw is an ordinary draw that replays on its own. The seats of the
+// voucher are the seats of the draws in order.
+func (g *SeatGenerator) DrawPreferredSeats(aircraftType, cabinClass string, count int, constraint SeatConstraint) ([]*Draw, error) {
+	if constraint.IsZero() {
+		draw, err := g.DrawCabinSeats(aircraftType, cabinClass, count)
+		if err != nil {
+			return nil, err
+		}
+		return []*Draw{draw}, nil
+	}
+
+	if count < 1 {
+		return nil, fmt.Errorf("invalid seat count: %d", count)
+	}
+	if err := constraint.Validate(count); err != nil {
+		return nil, err
+	}
+
+	config, err := GetAircraftConfig(aircraftType)
+	if err != nil {
+		return nil, err
+	}
+	cabinSeats, err := GetCabinSeats(aircraftType, cabinClass)
+	if err != nil {
+		return nil, err
+	}
+	if len(cabinSeats) < count {
+		return nil, fmt.Errorf("%w on %s: need %d, have %d", ErrNotEnoughSeats, aircraftType, count, len(cabinSeats))
+	}
+
+	allSeats := excludeSeats(cabinSeats, constraint.Excluded)
+	if len(allSeats) < count {
+		return nil, fmt.Errorf("%w on %s: need %d, have %d free (%d occupied)",
+			ErrNotEnoughSeats, aircraftType, count, len(allSeats), len(cabinSeats)-len(allSeats))
+	}
+
+	cabin := ""
+	if cabinClass != "" {
+		cabin = " in " + cabinClass
+	}
+
+	var draws []*Draw
+	drawn := make(map[string]bool, count)
+	for _, required := range constraint.required(count) {
+		pool := config.SeatsAt(allSeats, required.position)
+		if len(pool) < required.count {
+			free := ""
+			if len(constraint.Excluded) > 0 {
+				free = " free"
+			}
+			return nil, fmt.Errorf("%w: %s has %d%s %s seats%s, %s needs %d",
+				ErrSeatPreferenceUnsatisfiable, aircraftType, len(pool), free, required.position, cabin, constraint, required.count)
+		}
+
+		draw, err := g.DrawSeats(pool, required.count)
+		if err != nil {
+			return nil, err
+		}
+		for _, seat := range draw.Seats {
+			drawn[seat] = true
+		}
+		draws = append(draws, draw)
+	}
+
+	if rest := count - len(drawn); rest > 0 {
+		var pool []string
+		for _, seat := range allSeats {
+			if !drawn[seat] {
+				pool = append(pool, seat)
+			}
+		}
+
+		draw, err := g.DrawSeats(pool, rest)
+		if err != nil {
+			return nil, err
+		}
+		draws = append(draws, draw)
+	}
+
+	return draws, nil
+}
+
+// DrawnSeats returns the seats of a sequence of draws in order
+func DrawnSeats(draws []*Draw) []string {
+	var seats []string
+	for _, draw := range draws {
+		seats = append(seats, draw.Seats...)
+	}
+	return seats
+}
+
+// excludeSeats returns the seats not in excluded, keeping their order
+func excludeSeats(seats, excluded []string) []string {
+	if len(excluded) == 0 {
+		return seats
+	}
+
+	skip := make(map[string]bool, len(excluded))
+	for _, seat := range excluded {
+		skip[seat] = true
+	}
+
+	var remaining []string
+	for _, seat := range seats {
+		if !skip[seat] {
+			remaining = append(remaining, seat)
+		}
+	}
+	return remaining
+}
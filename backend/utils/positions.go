@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Seat positions a layout can tag its seat letters with
+const (
+	SeatWindow = "window"
+	SeatMiddle = "middle"
+	SeatAisle  = "aisle"
+)
+
+// SeatPositions lists the seat positions in the order constraints are drawn
+var SeatPositions = []string{SeatWindow, SeatMiddle, SeatAisle}
+
+// ErrSeatPreferenceUnsatisfiable is returned when a layout has too few seats
+// at the requested positions
+var ErrSeatPreferenceUnsatisfiable = errors.New("seat preference cannot be satisfied")
+
+// IsSeatPosition reports whether position is one of SeatPositions
+func IsSeatPosition(position string) bool {
+	return containsString(SeatPositions, position)
+}
+
+// SeatPosition returns the position tag of a seat, or an empty string when
+// its letter is untagged. Cabin zone tags take precedence over the aircraft's.
+func (c *AircraftConfig) SeatPosition(seat string) string {
+	row, letter, err := ParseSeat(seat)
+	if err != nil {
+		return ""
+	}
+	if zone := c.cabinZone(row); zone != nil {
+		if position, ok := zone.Positions[letter]; ok {
+			return position
+		}
+	}
+	return c.Positions[letter]
+}
+
+// SeatsAt returns the seats at the given position, keeping their order
+func (c *AircraftConfig) SeatsAt(seats []string, position string) []string {
+	var matching []string
+	for _, seat := range seats {
+		if c.SeatPosition(seat) == position {
+			matching = append(matching, seat)
+		}
+	}
+	return matching
+}
+
+// SeatConstraint restricts which seats may be drawn. The zero value allows
+// any seat.
+type SeatConstraint struct {
+	// Excluded lists seats that must not be drawn, e.g. seats of booked
+	// passengers
+	Excluded []string
+	// Preference requires every seat to be at this position
+	Preference string
+	// Minimums requires at least this many seats at each position; the rest
+	// of the seats may be at any position
+	Minimums map[string]int
+}
+
+// IsZero reports whether the constraint allows any seat
+func (c SeatConstraint) IsZero() bool {
+	if c.Preference != "" || len(c.Excluded) > 0 {
+		return false
+	}
+	for _, n := range c.Minimums {
+		if n > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks that the constraint names known positions and fits in a
+// draw of count seats
+func (c SeatConstraint) Validate(count int) error {
+	if c.Preference != "" && len(c.Minimums) > 0 {
+		return fmt.Errorf("a seat preference and a seat mix cannot be combined")
+	}
+	if c.Preference != "" && !IsSeatPosition(c.Preference) {
+		return fmt.Errorf("unknown seat position %q (expected %s)", c.Preference, strings.Join(SeatPositions, ", "))
+	}
+
+	total := 0
+	for position, n := range c.Minimums {
+		if !IsSeatPosition(position) {
+			return fmt.Errorf("unknown seat position %q (expected %s)", position, strings.Join(SeatPositions, ", "))
+		}
+		if n < 0 {
+			return fmt.Errorf("seat mix for %s must not be negative", position)
+		}
+		total += n
+	}
+	if total > count {
+		return fmt.Errorf("seat mix asks for %d seats, but only %d are drawn", total, count)
+	}
+
+	return nil
+}
+
+// String describes the position requirement of the constraint, e.g.
+// "window seats only" or "at least 1 window, 1 aisle"
+func (c SeatConstraint) String() string {
+	if c.Preference != "" {
+		return c.Preference + " seats only"
+	}
+
+	var parts []string
+	for _, position := range SeatPositions {
+		if n := c.Minimums[position]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, position))
+		}
+	}
+	if len(parts) == 0 {
+		return "any seats"
+	}
+	return "at least " + strings.Join(parts, ", ")
+}
+
+// required returns how many seats of each position a draw of count seats
+// must include, in SeatPositions order
+func (c SeatConstraint) required(count int) []positionCount {
+	if c.Preference != "" {
+		return []positionCount{{c.Preference, count}}
+	}
+
+	var required []positionCount
+	for _, position := range SeatPositions {
+		if n := c.Minimums[position]; n > 0 {
+			required = append(required, positionCount{position, n})
+		}
+	}
+	return required
+}
+
+type positionCount struct {
+	position string
+	count    int
+}
+
+// validatePositions checks that position tags use valid letters and positions
+func validatePositions(positions map[string]string) error {
+	for letter, position := range positions {
+		if len(letter) != 1 || letter[0] < 'A' || letter[0] > 'Z' {
+			return fmt.Errorf("invalid seat letter %q in positions", letter)
+		}
+		if !IsSeatPosition(position) {
+			return fmt.Errorf("seat %s has unknown position %q (expected %s)", letter, position, strings.Join(SeatPositions, ", "))
+		}
+	}
+	return nil
+}
+
+func clonePositions(positions map[string]string) map[string]string {
+	if positions == nil {
+		return nil
+	}
+	clone := make(map[string]string, len(positions))
+	for letter, position := range positions {
+		clone[letter] = position
+	}
+	return clone
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAircraftConfig_SeatPosition(t *testing.T) {
+	config := AircraftConfig{
+		Type:      "Test",
+		Rows:      4,
+		Seats:     []string{"A", "B", "C", "D"},
+		Positions: map[string]string{"A": SeatWindow, "B": SeatAisle, "C": SeatAisle, "D": SeatWindow},
+		Cabins: []CabinZone{
+			// A 1-1 cabin where B and C are window seats
+			{Class: "business", FirstRow: 1, LastRow: 1, Seats: []string{"B", "C"}, Positions: map[string]string{"B": SeatWindow, "C": SeatWindow}},
+			{Class: "economy", FirstRow: 2, LastRow: 4},
+		},
+	}
+	require.NoError(t, config.Validate())
+
+	assert.Equal(t, SeatWindow, config.SeatPosition("1B"))
+	assert.Equal(t, SeatAisle, config.SeatPosition("2B"))
+	assert.Equal(t, SeatWindow, config.SeatPosition("4D"))
+	assert.Equal(t, "", config.SeatPosition("4E"))
+	assert.Equal(t, []string{"1B", "1C", "2A", "2D"}, config.SeatsAt([]string{"1B", "1C", "2A", "2B", "2D"}, SeatWindow))
+
+	// Position tags are copied, not shared with the active layouts
+	clone := config.clone()
+	clone.Positions["A"] = SeatMiddle
+	clone.Cabins[0].Positions["B"] = SeatMiddle
+	assert.Equal(t, SeatWindow, config.Positions["A"])
+	assert.Equal(t, SeatWindow, config.Cabins[0].Positions["B"])
+}
+
+func TestAircraftConfig_ValidatePositions(t *testing.T) {
+	config := AircraftConfig{Type: "Test", Rows: 2, Seats: []string{"A", "B"}, Positions: map[string]string{"A": "galley"}}
+	err := config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown position "galley"`)
+
+	config.Positions = map[string]string{"a": SeatWindow}
+	err = config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid seat letter")
+
+	config.Positions = nil
+	config.Cabins = []CabinZone{{Class: "economy", FirstRow: 1, LastRow: 2, Positions: map[string]string{"B": "wing"}}}
+	err = config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cabin economy")
+}
+
+func TestSeatConstraint_Validate(t *testing.T) {
+	assert.True(t, SeatConstraint{}.IsZero())
+	assert.True(t, SeatConstraint{Minimums: map[string]int{SeatWindow: 0}}.IsZero())
+	assert.NoError(t, SeatConstraint{Preference: SeatAisle}.Validate(3))
+	assert.NoError(t, SeatConstraint{Minimums: map[string]int{SeatWindow: 1, SeatAisle: 2}}.Validate(3))
+
+	tests := []struct {
+		name       string
+		constraint SeatConstraint
+		errorMsg   string
+	}{
+		{"Unknown preference", SeatConstraint{Preference: "exit"}, "unknown seat position"},
+		{"Unknown mix position", SeatConstraint{Minimums: map[string]int{"exit": 1}}, "unknown seat position"},
+		{"Negative mix", SeatConstraint{Minimums: map[string]int{SeatWindow: -1}}, "must not be negative"},
+		{"Mix larger than the draw", SeatConstraint{Minimums: map[string]int{SeatWindow: 2, SeatAisle: 2}}, "asks for 4 seats"},
+		{"Both", SeatConstraint{Preference: SeatWindow, Minimums: map[string]int{SeatAisle: 1}}, "cannot be combined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.constraint.Validate(3)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+
+	assert.Equal(t, "window seats only", SeatConstraint{Preference: SeatWindow}.String())
+	assert.Equal(t, "at least 1 window, 2 aisle", SeatConstraint{Minimums: map[string]int{SeatAisle: 2, SeatWindow: 1}}.String())
+}
+
+func TestGenerateRandomSeats_Preference(t *testing.T) {
+	config, err := GetAircraftConfig("Airbus 320")
+	require.NoError(t, err)
+
+	for i := 0; i < 50; i++ {
+		seats, err := GenerateRandomSeats("Airbus 320", "economy", 4, SeatConstraint{Preference: SeatMiddle})
+		require.NoError(t, err)
+		require.Len(t, seats, 4)
+		for _, seat := range seats {
+			assert.Equal(t, SeatMiddle, config.SeatPosition(seat), "seat %s", seat)
+		}
+	}
+
+	// The business cabin has no middle seats
+	_, err = GenerateRandomSeats("Airbus 320", "business", 1, SeatConstraint{Preference: SeatMiddle})
+	assert.ErrorIs(t, err, ErrSeatPreferenceUnsatisfiable)
+	assert.Contains(t, err.Error(), "has 0 middle seats in business")
+}
+
+func TestGenerateRandomSeats_Mix(t *testing.T) {
+	config, err := GetAircraftConfig("ATR")
+	require.NoError(t, err)
+
+	mix := SeatConstraint{Minimums: map[string]int{SeatWindow: 1, SeatAisle: 1}}
+	for i := 0; i < 50; i++ {
+		seats, err := GenerateRandomSeats("ATR", "", 3, mix)
+		require.NoError(t, err)
+		require.Len(t, seats, 3)
+		assert.Equal(t, SeatWindow, config.SeatPosition(seats[0]))
+		assert.Equal(t, SeatAisle, config.SeatPosition(seats[1]))
+		assert.NotEqual(t, seats[0], seats[2])
+		assert.NotEqual(t, seats[1], seats[2])
+	}
+
+	// The ATR has 36 window seats
+	_, err = GenerateRandomSeats("ATR", "", 40, SeatConstraint{Minimums: map[string]int{SeatWindow: 37}})
+	assert.ErrorIs(t, err, ErrSeatPreferenceUnsatisfiable)
+}
+
+func TestDrawPreferredSeats_Replayable(t *testing.T) {
+	generator := NewSeededSeatGenerator(7)
+	draws, err := generator.DrawPreferredSeats("Airbus 320", "", 5, SeatConstraint{Minimums: map[string]int{SeatWindow: 2}})
+	require.NoError(t, err)
+	require.Len(t, draws, 2)
+	assert.Len(t, draws[0].Seats, 2)
+	assert.Len(t, draws[1].Seats, 3)
+	assert.Len(t, DrawnSeats(draws), 5)
+
+	for _, seat := range draws[0].Seats {
+		assert.NotContains(t, draws[1].Pool, seat, "the rest is drawn without the window seats")
+	}
+	for _, draw := range draws {
+		replayed, err := ReplayDraw(draw.Algorithm, draw.Seed, draw.Pool, len(draw.Seats))
+		require.NoError(t, err)
+		assert.Equal(t, draw.Seats, replayed)
+	}
+}
+
+func TestDrawPreferredSeats_UntaggedLayout(t *testing.T) {
+	restoreAircraftConfigs(t)
+	configs, err := ParseAircraftConfigs([]byte(`{"aircraft": [{"type": "Plain", "rows": 5, "seats": ["A", "B"]}]}`), "json")
+	require.NoError(t, err)
+	aircraftMu.Lock()
+	aircraftConfigs = configs
+	aircraftMu.Unlock()
+
+	_, err = DrawPreferredSeats("Plain", "", 1, SeatConstraint{Preference: SeatWindow})
+	assert.ErrorIs(t, err, ErrSeatPreferenceUnsatisfiable)
+
+	draws, err := DrawPreferredSeats("Plain", "", 2, SeatConstraint{})
+	require.NoError(t, err)
+	assert.Len(t, draws, 1)
+}
@@ -1,83 +1,66 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
-	"math/rand"
 	"time"
 )
 
-// AircraftConfig represents the configuration for an aircraft type
-type AircraftConfig struct {
-	Rows  int
-	Seats []string
-}
+const (
+	// DefaultSeatCount is the number of seats drawn per voucher when a request
+	// does not ask for a specific number
+	DefaultSeatCount = 3
+	// MaxSeatCount caps the number of seats a single voucher can hold
+	MaxSeatCount = 50
+)
+
+// ErrNotEnoughSeats is returned when a draw asks for more seats than are available
+var ErrNotEnoughSeats = errors.New("not enough seats")
 
 // GetAircraftConfig returns the seat configuration for a given aircraft type
 func GetAircraftConfig(aircraftType string) (*AircraftConfig, error) {
-	configs := map[string]AircraftConfig{
-		"ATR": {
-			Rows:  18,
-			Seats: []string{"A", "C", "D", "F"},
-		},
-		"Airbus 320": {
-			Rows:  32,
-			Seats: []string{"A", "B", "C", "D", "E", "F"},
-		},
-		"Boeing 737 Max": {
-			Rows:  32,
-			Seats: []string{"A", "B", "C", "D", "E", "F"},
-		},
-	}
+	aircraftMu.RLock()
+	config, exists := aircraftConfigs[aircraftType]
+	aircraftMu.RUnlock()
 
-	config, exists := configs[aircraftType]
 	if !exists {
 		return nil, fmt.Errorf("unknown aircraft type: %s", aircraftType)
 	}
 
-	return &config, nil
+	return config.clone(), nil
 }
 
-// GenerateRandomSeats generates 3 unique random seats for the given aircraft type
-func GenerateRandomSeats(aircraftType string) ([]string, error) {
-	config, err := GetAircraftConfig(aircraftType)
+// GenerateRandomSeats generates count unique random seats for the given
+// aircraft type. A non-empty cabin class limits the draw to that cabin zone,
+// and a non-zero constraint to seats at the requested positions; when the
+// layout can't satisfy it the error wraps ErrSeatPreferenceUnsatisfiable.
+func GenerateRandomSeats(aircraftType, cabinClass string, count int, constraint SeatConstraint) ([]string, error) {
+	draws, err := DrawPreferredSeats(aircraftType, cabinClass, count, constraint)
 	if err != nil {
 		return nil, err
 	}
+	return DrawnSeats(draws), nil
+}
 
-	// Generate all possible seats
-	var allSeats []string
-	for row := 1; row <= config.Rows; row++ {
-		for _, seat := range config.Seats {
-			allSeats = append(allSeats, fmt.Sprintf("%d%s", row, seat))
-		}
-	}
-
-	// Use current time as seed for randomness
-	rand.Seed(time.Now().UnixNano())
-
-	// Shuffle the seats and take the first 3
-	shuffled := make([]string, len(allSeats))
-	copy(shuffled, allSeats)
-
-	// Fisher-Yates shuffle
-	for i := len(shuffled) - 1; i > 0; i-- {
-		j := rand.Intn(i + 1)
-		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
-	}
+// DrawCabinSeats is GenerateRandomSeats returning the whole Draw, so the
+// seed and commitment can be recorded
+func DrawCabinSeats(aircraftType, cabinClass string, count int) (*Draw, error) {
+	return defaultGenerator.DrawCabinSeats(aircraftType, cabinClass, count)
+}
 
-	// Return the first 3 seats
-	return shuffled[:3], nil
+// DrawPreferredSeats is GenerateRandomSeats returning the draws behind the
+// seats
+func DrawPreferredSeats(aircraftType, cabinClass string, count int, constraint SeatConstraint) ([]*Draw, error) {
+	return defaultGenerator.DrawPreferredSeats(aircraftType, cabinClass, count, constraint)
 }
 
 // ValidateAircraftType checks if the aircraft type is valid
 func ValidateAircraftType(aircraftType string) bool {
-	validTypes := []string{"ATR", "Airbus 320", "Boeing 737 Max"}
-	for _, validType := range validTypes {
-		if aircraftType == validType {
-			return true
-		}
-	}
-	return false
+	aircraftMu.RLock()
+	defer aircraftMu.RUnlock()
+
+	_, exists := aircraftConfigs[aircraftType]
+	return exists
 }
 
 // ValidateDateFormat validates the date format (YYYY-MM-DD)
@@ -86,33 +69,47 @@ func ValidateDateFormat(date string) bool {
 	return err == nil
 }
 
-// GetAllSeats returns all possible seats for a given aircraft type
+// GetAllSeats returns all seats of a given aircraft type that may be assigned
+// to a voucher, leaving out excluded rows and seats
 func GetAllSeats(aircraftType string) ([]string, error) {
 	config, err := GetAircraftConfig(aircraftType)
 	if err != nil {
 		return nil, err
 	}
 
-	var allSeats []string
-	for row := 1; row <= config.Rows; row++ {
-		for _, seat := range config.Seats {
-			allSeats = append(allSeats, fmt.Sprintf("%d%s", row, seat))
-		}
+	return config.AvailableSeats(), nil
+}
+
+// GetCabinSeats returns the assignable seats of one cabin class of an
+// aircraft type. An empty cabin class returns every assignable seat.
+func GetCabinSeats(aircraftType, cabinClass string) ([]string, error) {
+	config, err := GetAircraftConfig(aircraftType)
+	if err != nil {
+		return nil, err
 	}
 
-	return allSeats, nil
+	if cabinClass == "" {
+		return config.AvailableSeats(), nil
+	}
+
+	class, ok := config.LookupCabinClass(cabinClass)
+	if !ok {
+		return nil, fmt.Errorf("unknown cabin class %s for aircraft %s", cabinClass, aircraftType)
+	}
+
+	return config.AvailableCabinSeats(class), nil
 }
 
 // GenerateRandomSeat generates a single random seat from the available seats
 func GenerateRandomSeat(availableSeats []string) (string, error) {
-	if len(availableSeats) == 0 {
-		return "", fmt.Errorf("no available seats")
+	draw, err := DrawSeat(availableSeats)
+	if err != nil {
+		return "", err
 	}
+	return draw.Seats[0], nil
+}
 
-	// Use current time as seed for randomness
-	rand.Seed(time.Now().UnixNano())
-
-	// Pick a random seat from available ones
-	randomIndex := rand.Intn(len(availableSeats))
-	return availableSeats[randomIndex], nil
+// DrawSeat is GenerateRandomSeat returning the whole Draw
+func DrawSeat(availableSeats []string) (*Draw, error) {
+	return defaultGenerator.DrawSeat(availableSeats)
 }
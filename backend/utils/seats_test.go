@@ -91,7 +91,7 @@ func TestGenerateRandomSeats(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			seats, err := GenerateRandomSeats(tt.aircraftType)
+			seats, err := GenerateRandomSeats(tt.aircraftType, "", DefaultSeatCount, SeatConstraint{})
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -249,3 +249,73 @@ func TestValidateDateFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateRandomSeats_SeatCount(t *testing.T) {
+	for _, count := range []int{1, 5, 10} {
+		seats, err := GenerateRandomSeats("Airbus 320", "", count, SeatConstraint{})
+		require.NoError(t, err)
+		assert.Len(t, seats, count)
+
+		unique := make(map[string]bool)
+		for _, seat := range seats {
+			unique[seat] = true
+		}
+		assert.Len(t, unique, count)
+	}
+
+	_, err := GenerateRandomSeats("ATR", "", 0, SeatConstraint{})
+	assert.Error(t, err)
+
+	// ATR has 72 seats in total
+	_, err = GenerateRandomSeats("ATR", "", 73, SeatConstraint{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not enough seats")
+}
+
+func TestSeatGenerator_Seeded(t *testing.T) {
+	// A seeded generator gives exact, repeatable seats
+	generator := NewSeededSeatGenerator(42)
+
+	first, err := generator.DrawCabinSeats("ATR", "", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"6F", "1C", "5A"}, first.Seats)
+
+	second, err := generator.DrawCabinSeats("ATR", "", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"9D", "10F", "15F"}, second.Seats)
+
+	// The same seed replays the same sequence of draws
+	again := NewSeededSeatGenerator(42)
+	for _, expected := range []*Draw{first, second} {
+		draw, err := again.DrawCabinSeats("ATR", "", 3)
+		require.NoError(t, err)
+		assert.Equal(t, expected, draw)
+	}
+
+	// Each draw can also be replayed on its own from its recorded seed
+	seats, err := ReplayDraw(second.Algorithm, second.Seed, second.Pool, 3)
+	require.NoError(t, err)
+	assert.Equal(t, second.Seats, seats)
+}
+
+func TestSeatGenerator_Errors(t *testing.T) {
+	generator := NewSeededSeatGenerator(1)
+
+	_, err := generator.DrawCabinSeats("ATR", "", 0)
+	assert.Error(t, err)
+
+	_, err = generator.DrawCabinSeats("Unknown", "", 1)
+	assert.Error(t, err)
+
+	_, err = generator.DrawCabinSeats("ATR", "", MaxSeatCount*10)
+	assert.ErrorIs(t, err, ErrNotEnoughSeats)
+
+	_, err = generator.DrawSeat(nil)
+	assert.ErrorIs(t, err, ErrNotEnoughSeats)
+
+	// The zero value draws from the entropy source
+	var zero SeatGenerator
+	draw, err := zero.DrawSeat([]string{"1A", "1B"})
+	require.NoError(t, err)
+	assert.Len(t, draw.Seed, SeedSize)
+}
@@ -1,9 +1,11 @@
+import { CrewSignIn } from './components/CrewSignIn'
 import { VoucherForm } from './components/VoucherForm'
 import './App.css'
 
 function App() {
   return (
     <div className="App">
+      <CrewSignIn />
       <VoucherForm />
     </div>
   )
@@ -0,0 +1,49 @@
+// Each crew member signs in with their own bearer token, issued by the crew
+// sign-in system. It is kept for the browser session only and never built
+// into the bundle, so the backend sees who is acting.
+const TOKEN_KEY = 'crewToken'
+
+export interface TokenClaims {
+  sub?: string
+  name?: string
+  role?: string
+  exp?: number
+}
+
+export const getApiToken = (): string | null => sessionStorage.getItem(TOKEN_KEY)
+
+export const setApiToken = (token: string | null) => {
+  if (token) {
+    sessionStorage.setItem(TOKEN_KEY, token)
+  } else {
+    sessionStorage.removeItem(TOKEN_KEY)
+  }
+}
+
+// Roles, from least to most privileged, as in the backend
+const ROLES = ['crew', 'supervisor', 'admin']
+
+// Reads the claims of a token for display; the backend verifies the signature
+export const decodeToken = (token: string): TokenClaims | null => {
+  const payload = token.split('.')[1]
+  if (!payload) {
+    return null
+  }
+  try {
+    const json = atob(payload.replace(/-/g, '+').replace(/_/g, '/'))
+    return JSON.parse(json) as TokenClaims
+  } catch {
+    return null
+  }
+}
+
+// Reports whether the holder of a token has role or a more privileged one.
+// Crew tokens may leave the role out. Without a token the backend decides,
+// as when it runs with authentication disabled.
+export const tokenHasRole = (token: string | null, role: string): boolean => {
+  if (!token) {
+    return true
+  }
+  const held = decodeToken(token)?.role || 'crew'
+  return ROLES.indexOf(held) >= ROLES.indexOf(role)
+}
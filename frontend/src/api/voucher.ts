@@ -1,4 +1,5 @@
 import axios from 'axios'
+import { getApiToken } from './auth'
 import type { CheckVoucherRequest, CheckVoucherResponse, GenerateVoucherRequest, GenerateVoucherResponse, GetVoucherRequest, GetVoucherResponse, RegenerateSeatRequest, RegenerateSeatResponse } from '../types'
 
 const API_BASE_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080'
@@ -10,6 +11,15 @@ const api = axios.create({
   },
 })
 
+// Send the signed-in crew member's token with every request
+api.interceptors.request.use((config) => {
+  const token = getApiToken()
+  if (token) {
+    config.headers.Authorization = `Bearer ${token}`
+  }
+  return config
+})
+
 export const checkVoucher = async (data: CheckVoucherRequest): Promise<CheckVoucherResponse> => {
   const response = await api.post<CheckVoucherResponse>('/api/check', data)
   return response.data
@@ -0,0 +1,65 @@
+import React, { useState } from 'react'
+import { useAtom } from 'jotai'
+import { decodeToken, setApiToken } from '../api/auth'
+import { apiTokenAtom } from '../store/atoms'
+import './VoucherForm.css'
+
+// Signs a crew member in with their personal bearer token. Without one the
+// form still works against a backend started with AUTH_DISABLED.
+export const CrewSignIn: React.FC = () => {
+  const [token, setToken] = useAtom(apiTokenAtom)
+  const [input, setInput] = useState('')
+
+  const handleSignIn = (e: React.FormEvent) => {
+    e.preventDefault()
+    const value = input.trim()
+    if (!value) {
+      return
+    }
+    setApiToken(value)
+    setToken(value)
+    setInput('')
+  }
+
+  const handleSignOut = () => {
+    setApiToken(null)
+    setToken(null)
+  }
+
+  if (token) {
+    const claims = decodeToken(token)
+    const who = claims?.name || claims?.sub || 'crew member'
+    return (
+      <div className="voucher-form-container crew-sign-in">
+        <p>
+          Signed in as <strong>{who}</strong>
+          {claims?.role ? ` (${claims.role})` : ''}
+        </p>
+        <button type="button" className="sign-out-button" onClick={handleSignOut}>
+          Sign out
+        </button>
+      </div>
+    )
+  }
+
+  return (
+    <div className="voucher-form-container crew-sign-in">
+      <form onSubmit={handleSignIn} className="voucher-form">
+        <div className="form-group">
+          <label htmlFor="crewToken">Crew Token</label>
+          <input
+            type="password"
+            id="crewToken"
+            value={input}
+            onChange={(e) => setInput(e.target.value)}
+            placeholder="Paste the token from the crew sign-in system"
+            autoComplete="off"
+          />
+        </div>
+        <button type="submit" className="submit-button" disabled={!input.trim()}>
+          Sign In
+        </button>
+      </form>
+    </div>
+  )
+}
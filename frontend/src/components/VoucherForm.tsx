@@ -1,6 +1,7 @@
 import React, { useState, useEffect } from 'react'
-import { useAtom } from 'jotai'
-import { voucherFormSchema, fieldSchemas, type VoucherFormData, AircraftType } from '../types'
+import { useAtom, useAtomValue } from 'jotai'
+import axios from 'axios'
+import { voucherFormSchema, fieldSchemas, type VoucherFormData, AircraftType, type RegenerationReason, regenerationReasonLabels } from '../types'
 import { 
   formDataAtom, 
   isLoadingAtom, 
@@ -8,8 +9,10 @@ import {
   errorMessageAtom, 
   successMessageAtom,
   currentVoucherAtom,
-  isRegeneratingAtom
+  isRegeneratingAtom,
+  apiTokenAtom
 } from '../store/atoms'
+import { tokenHasRole } from '../api/auth'
 import { checkVoucher, generateVoucher, getVoucher, regenerateSeat } from '../api/voucher'
 import { formatDateForAPI, formatDateInput, formatFlightNumberInput } from '../utils/date'
 import { ZodError } from 'zod'
@@ -24,6 +27,11 @@ export const VoucherForm: React.FC = () => {
   const [currentVoucher, setCurrentVoucher] = useAtom(currentVoucherAtom)
   const [isRegenerating, setIsRegenerating] = useAtom(isRegeneratingAtom)
   const [validationErrors, setValidationErrors] = useState<Record<string, string>>({})
+  // Every redraw must be justified, so the reason is chosen anew each time
+  const [regenerationReason, setRegenerationReason] = useState<RegenerationReason | ''>('')
+  // Only supervisors may redraw seats; crew see the seats without the controls
+  const canRegenerate = tokenHasRole(useAtomValue(apiTokenAtom), 'supervisor')
+  const showRegenerate = Boolean(currentVoucher?.exists) && canRegenerate
 
   // Check for existing voucher when flight details change
   useEffect(() => {
@@ -39,11 +47,11 @@ export const VoucherForm: React.FC = () => {
           if (result.exists && result.voucher) {
             setCurrentVoucher({
               exists: true,
-              seats: [result.voucher.seat1, result.voucher.seat2, result.voucher.seat3],
+              seats: result.voucher.seats,
               flightNumber: formData.flightNumber,
               date: formattedDate,
             })
-            setGeneratedSeats([result.voucher.seat1, result.voucher.seat2, result.voucher.seat3])
+            setGeneratedSeats(result.voucher.seats)
           } else {
             setCurrentVoucher(null)
             setGeneratedSeats([])
@@ -108,6 +116,10 @@ export const VoucherForm: React.FC = () => {
     if (!currentVoucher) return
 
     clearMessages()
+    if (!regenerationReason) {
+      setErrorMessage('Please select a reason for regenerating the seat.')
+      return
+    }
     setIsRegenerating(true)
 
     try {
@@ -115,9 +127,12 @@ export const VoucherForm: React.FC = () => {
         flightNumber: currentVoucher.flightNumber,
         date: currentVoucher.date,
         seatPosition: seatPosition,
+        crewId: formData.crewId,
+        reason: regenerationReason,
       })
 
       if (result.success) {
+        setRegenerationReason('')
         // Update the seats display
         setGeneratedSeats(result.allSeats)
         setCurrentVoucher({
@@ -130,7 +145,11 @@ export const VoucherForm: React.FC = () => {
       }
     } catch (error) {
       console.error('Error regenerating seat:', error)
-      setErrorMessage('An error occurred while regenerating the seat. Please try again.')
+      if (axios.isAxiosError(error) && error.response?.status === 403) {
+        setErrorMessage('Regenerating seats requires a supervisor. Ask a supervisor to sign in and regenerate the seat.')
+      } else {
+        setErrorMessage('An error occurred while regenerating the seat. Please try again.')
+      }
     } finally {
       setIsRegenerating(false)
     }
@@ -143,7 +162,9 @@ export const VoucherForm: React.FC = () => {
     
     // If vouchers already exist, show message instead of generating new ones
     if (currentVoucher?.exists) {
-      setErrorMessage('Vouchers have already been generated for this flight on the selected date. Use the regenerate buttons to change individual seats.')
+      setErrorMessage(canRegenerate
+        ? 'Vouchers have already been generated for this flight on the selected date. Use the regenerate buttons to change individual seats.'
+        : 'Vouchers have already been generated for this flight on the selected date.')
       return
     }
     
@@ -347,16 +368,34 @@ export const VoucherForm: React.FC = () => {
           <h2>
             {currentVoucher?.exists ? 'Current Seat Assignments' : 'Generated Seat Numbers'}
           </h2>
+          {showRegenerate && (
+            <div className="form-group">
+              <label htmlFor="regenerationReason">
+                Reason for Regenerating<span className="required-asterisk">*</span>
+              </label>
+              <select
+                id="regenerationReason"
+                value={regenerationReason}
+                onChange={(e) => setRegenerationReason(e.target.value as RegenerationReason | '')}
+                disabled={isRegenerating || isLoading}
+              >
+                <option value="">Select a reason</option>
+                {Object.entries(regenerationReasonLabels).map(([reason, label]) => (
+                  <option key={reason} value={reason}>{label}</option>
+                ))}
+              </select>
+            </div>
+          )}
           <div className="seats-list">
             {generatedSeats.map((seat, index) => (
               <div key={index} className="seat-item">
                 <span className="seat-number">{seat}</span>
-                {currentVoucher?.exists && (
+                {showRegenerate && (
                   <button
                     type="button"
                     className="regenerate-btn"
                     onClick={() => handleRegenerateSeat(index + 1)}
-                    disabled={isRegenerating || isLoading}
+                    disabled={isRegenerating || isLoading || !regenerationReason}
                     title={`Regenerate seat ${index + 1}`}
                   >
                     {isRegenerating ? '...' : '🔄'}
@@ -365,9 +404,14 @@ export const VoucherForm: React.FC = () => {
               </div>
             ))}
           </div>
-          {currentVoucher?.exists && (
+          {showRegenerate && (
+            <p className="regeneration-info">
+              Select a reason, then click the 🔄 button next to any seat to generate a new random seat assignment.
+            </p>
+          )}
+          {currentVoucher?.exists && !canRegenerate && (
             <p className="regeneration-info">
-              Click the 🔄 button next to any seat to generate a new random seat assignment.
+              Regenerating seats requires a supervisor. Ask a supervisor to sign in to change a seat.
             </p>
           )}
         </div>
@@ -1,4 +1,5 @@
 import { atom } from 'jotai'
+import { getApiToken } from '../api/auth'
 import type { VoucherFormData } from '../types'
 import { AircraftType } from '../types'
 
@@ -33,3 +34,6 @@ export const currentVoucherAtom = atom<{
 
 // Regeneration loading state
 export const isRegeneratingAtom = atom(false)
+
+// Bearer token of the signed-in crew member, also kept in session storage
+export const apiTokenAtom = atom<string | null>(getApiToken())
@@ -0,0 +1,32 @@
+import { describe, it, expect, beforeEach } from 'vitest'
+import { render, screen } from '@testing-library/react'
+import userEvent from '@testing-library/user-event'
+import { CrewSignIn } from '../components/CrewSignIn'
+import { getApiToken } from '../api/auth'
+
+// An unsigned token for crew member 98123; only its claims are read
+const token = `e30.${btoa(JSON.stringify({ sub: '98123', name: 'Sarah', role: 'crew' }))}.sig`
+
+describe('CrewSignIn', () => {
+  beforeEach(() => {
+    sessionStorage.clear()
+  })
+
+  it('keeps the token for the session and shows who is signed in', async () => {
+    const user = userEvent.setup()
+    render(<CrewSignIn />)
+
+    expect(screen.getByRole('button', { name: /sign in/i })).toBeDisabled()
+
+    await user.type(screen.getByLabelText(/crew token/i), token)
+    await user.click(screen.getByRole('button', { name: /sign in/i }))
+
+    expect(getApiToken()).toBe(token)
+    expect(screen.getByText('Sarah')).toBeInTheDocument()
+
+    await user.click(screen.getByRole('button', { name: /sign out/i }))
+
+    expect(getApiToken()).toBeNull()
+    expect(screen.getByLabelText(/crew token/i)).toBeInTheDocument()
+  })
+})
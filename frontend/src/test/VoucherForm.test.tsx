@@ -1,19 +1,25 @@
 import { describe, it, expect, vi, beforeEach } from 'vitest'
 import { render, screen, waitFor } from '@testing-library/react'
 import userEvent from '@testing-library/user-event'
+import { Provider, createStore } from 'jotai'
 import { VoucherForm } from '../components/VoucherForm'
+import { apiTokenAtom } from '../store/atoms'
 import { AircraftType } from '../types'
 
 // Mock the API module
 vi.mock('../api/voucher', () => ({
   checkVoucher: vi.fn(),
   generateVoucher: vi.fn(),
+  getVoucher: vi.fn(),
+  regenerateSeat: vi.fn(),
 }))
 
-import { checkVoucher, generateVoucher } from '../api/voucher'
+import { checkVoucher, generateVoucher, getVoucher, regenerateSeat } from '../api/voucher'
 
 const mockCheckVoucher = vi.mocked(checkVoucher)
 const mockGenerateVoucher = vi.mocked(generateVoucher)
+const mockGetVoucher = vi.mocked(getVoucher)
+const mockRegenerateSeat = vi.mocked(regenerateSeat)
 
 describe('VoucherForm', () => {
   beforeEach(() => {
@@ -113,4 +119,90 @@ describe('VoucherForm', () => {
     
     expect(mockGenerateVoucher).not.toHaveBeenCalled()
   })
+
+  it('requires a reason before regenerating a seat', async () => {
+    const user = userEvent.setup()
+
+    mockGetVoucher.mockResolvedValue({
+      exists: true,
+      voucher: { seats: ['3B', '7C', '14D'] },
+    } as Awaited<ReturnType<typeof getVoucher>>)
+    mockRegenerateSeat.mockResolvedValue({
+      success: true,
+      newSeat: '9F',
+      allSeats: ['9F', '7C', '14D'],
+    })
+
+    // A fresh store, so the form starts empty whatever earlier tests typed
+    render(<Provider><VoucherForm /></Provider>)
+
+    await user.type(screen.getByLabelText(/crew id/i), '12345')
+    await user.type(screen.getByLabelText(/flight number/i), 'GA102')
+    await user.type(screen.getByLabelText(/flight date/i), '09-07-25')
+
+    const regenerateButton = await screen.findByTitle('Regenerate seat 1')
+    expect(regenerateButton).toBeDisabled()
+
+    await user.selectOptions(screen.getByLabelText(/reason for regenerating/i), 'accessibility')
+    await user.click(regenerateButton)
+
+    await waitFor(() => {
+      expect(screen.getByText(/new seat: 9F/i)).toBeInTheDocument()
+    })
+
+    expect(mockRegenerateSeat).toHaveBeenCalledWith({
+      flightNumber: 'GA102',
+      date: '2025-07-09',
+      seatPosition: 1,
+      crewId: '12345',
+      reason: 'accessibility',
+    })
+    // The next redraw needs its own reason
+    expect(screen.getByLabelText(/reason for regenerating/i)).toHaveValue('')
+  })
+
+  it('hides the regenerate controls from crew', async () => {
+    const user = userEvent.setup()
+
+    mockGetVoucher.mockResolvedValue({
+      exists: true,
+      voucher: { seats: ['3B', '7C', '14D'] },
+    } as Awaited<ReturnType<typeof getVoucher>>)
+
+    // Signed in with a crew token; only its claims are read
+    const store = createStore()
+    store.set(apiTokenAtom, `e30.${btoa(JSON.stringify({ sub: '12345', role: 'crew' }))}.sig`)
+    render(<Provider store={store}><VoucherForm /></Provider>)
+
+    await user.type(screen.getByLabelText(/flight number/i), 'GA102')
+    await user.type(screen.getByLabelText(/flight date/i), '09-07-25')
+
+    expect(await screen.findByText('14D')).toBeInTheDocument()
+    expect(screen.getByText(/requires a supervisor/i)).toBeInTheDocument()
+    expect(screen.queryByTitle('Regenerate seat 1')).not.toBeInTheDocument()
+    expect(screen.queryByLabelText(/reason for regenerating/i)).not.toBeInTheDocument()
+  })
+
+  it('explains a forbidden regeneration', async () => {
+    const user = userEvent.setup()
+
+    mockGetVoucher.mockResolvedValue({
+      exists: true,
+      voucher: { seats: ['3B', '7C', '14D'] },
+    } as Awaited<ReturnType<typeof getVoucher>>)
+    mockRegenerateSeat.mockRejectedValue({ isAxiosError: true, response: { status: 403 } })
+
+    render(<Provider><VoucherForm /></Provider>)
+
+    await user.type(screen.getByLabelText(/flight number/i), 'GA102')
+    await user.type(screen.getByLabelText(/flight date/i), '09-07-25')
+
+    const regenerateButton = await screen.findByTitle('Regenerate seat 1')
+    await user.selectOptions(screen.getByLabelText(/reason for regenerating/i), 'accessibility')
+    await user.click(regenerateButton)
+
+    await waitFor(() => {
+      expect(screen.getByText(/regenerating seats requires a supervisor/i)).toBeInTheDocument()
+    })
+  })
 })
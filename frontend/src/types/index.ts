@@ -85,10 +85,9 @@ export interface Voucher {
   flight_number: string
   flight_date: string
   aircraft_type: string
-  seat1: string
-  seat2: string
-  seat3: string
+  cabin_class: string
   created_at: string
+  seats: string[]
 }
 
 export interface GetVoucherResponse {
@@ -100,7 +99,19 @@ export interface GetVoucherResponse {
 export interface RegenerateSeatRequest {
   flightNumber: string
   date: string
-  seatPosition: number // 1, 2, or 3
+  seatPosition: number // 1-based position within the voucher's seats
+  crewId: string // Crew member asking for the redraw
+  reason: RegenerationReason
+}
+
+export type RegenerationReason = 'passenger_request' | 'seat_unavailable' | 'accessibility' | 'operational'
+
+// Labels of the reason codes a redraw can be justified with, in display order
+export const regenerationReasonLabels: Record<RegenerationReason, string> = {
+  passenger_request: 'Passenger request',
+  seat_unavailable: 'Seat unavailable',
+  accessibility: 'Accessibility',
+  operational: 'Operational',
 }
 
 export interface RegenerateSeatResponse {
@@ -121,4 +132,6 @@ export interface GenerateVoucherRequest {
   flightNumber: string
   date: string
   aircraft: AircraftType
+  cabinClass?: string
+  seatCount?: number
 }